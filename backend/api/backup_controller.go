@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"net/http"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupController 全站导出控制器
+type BackupController struct {
+	backupService service.BackupService
+}
+
+// NewBackupController 创建全站导出控制器
+func NewBackupController() *BackupController {
+	return &BackupController{
+		backupService: service.BackupService{},
+	}
+}
+
+// ExportSite 以 JSON Lines 归档流式导出全站数据，使用 cmd/restore 恢复
+func (c *BackupController) ExportSite(ctx *gin.Context) {
+	var req models.ExportSiteRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return
+	}
+
+	filename := fmt.Sprintf("keep-learning-blog-%s.jsonl", time.Now().Format("20060102-150405"))
+	ctx.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Header("Cache-Control", "no-store")
+
+	if err := c.backupService.ExportSite(ctx.Request.Context(), ctx.Writer, req.IncludePasswordHashes); err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": ctx.GetUint("user_id"),
+		})).Error("Failed to export site")
+
+		// 已开始输出时无法再修改状态码，此时归档缺少结尾记录，恢复时会被拒绝
+		if !ctx.Writer.Written() {
+			ctx.Header("Content-Disposition", "")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export site"})
+		}
+		return
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":         ctx.GetUint("user_id"),
+		"password_hashes": req.IncludePasswordHashes,
+	})).Info("Site exported")
+}
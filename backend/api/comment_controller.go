@@ -1,26 +1,33 @@
 package api
 
 import (
+	"keep_learning_blog/config"
+	"keep_learning_blog/middleware"
 	"keep_learning_blog/models"
 	"keep_learning_blog/service"
 	"net/http"
 	"strconv"
 
-	"keep_learning_blog/middleware"
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/spam"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 审核评论的权限编码，拥有该权限的用户可以查看待审核评论，且发表的评论无需审核
+const moderateCommentsPermission = "comments:select:pending"
+
 // CommentController 评论控制器
 type CommentController struct {
+	config         *config.Config
 	commentService service.CommentService
 }
 
 // NewCommentController 创建评论控制器
-func NewCommentController() *CommentController {
+func NewCommentController(config *config.Config) *CommentController {
 	return &CommentController{
-		commentService: service.CommentService{},
+		config:         config,
+		commentService: service.CommentService{SpamFilter: spam.NewFilterFromConfig(&config.Spam, &service.SpamTokenStore{})},
 	}
 }
 
@@ -36,10 +43,16 @@ func (c *CommentController) CreateComment(ctx *gin.Context) {
 		return
 	}
 
-	req.Content = middleware.SanitizeHTML(req.Content)
+	// 内容以 Markdown 源文本保存，渲染后的 HTML 由业务层过滤
 	userID := ctx.GetUint("user_id")
 
-	comment, err := c.commentService.CreateComment(req.Content, req.PostID, userID)
+	// 审核员发表的评论无需审核
+	moderationMode := c.config.Moderation.Mode
+	if middleware.HasPermission(ctx, c.config, moderateCommentsPermission) {
+		moderationMode = config.ModerationModeOff
+	}
+
+	comment, err := c.commentService.CreateComment(req.Content, req.PostID, userID, req.ParentID, moderationMode, req.Website)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields(map[string]interface{}{
 			"error":   err.Error(),
@@ -57,8 +70,13 @@ func (c *CommentController) CreateComment(ctx *gin.Context) {
 		"post_id":    req.PostID,
 	})).Info("Comment created successfully")
 
+	message := "comment created successfully"
+	if comment.Status == models.CommentStatusPending {
+		message = "comment submitted and awaiting moderation"
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "comment created successfully",
+		"message": message,
 		"comment": comment,
 	})
 }
@@ -86,14 +104,20 @@ func (c *CommentController) UpdateComment(ctx *gin.Context) {
 		return
 	}
 
-	req.Content = middleware.SanitizeHTML(req.Content)
+	// 内容以 Markdown 源文本保存，渲染后的 HTML 由业务层过滤
 	userID := ctx.GetUint("user_id")
 	if userID == 0 {
 		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	comment, err := c.commentService.UpdateComment(uint(id), userID, req.Content)
+	// 审核员编辑的评论无需重新审核
+	moderationMode := c.config.Moderation.Mode
+	if middleware.HasPermission(ctx, c.config, moderateCommentsPermission) {
+		moderationMode = config.ModerationModeOff
+	}
+
+	comment, err := c.commentService.UpdateComment(uint(id), userID, req.Content, moderationMode)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -133,3 +157,109 @@ func (c *CommentController) DeleteComment(ctx *gin.Context) {
 		"message": "comment deleted successfully",
 	})
 }
+
+// ListPendingComments 获取待审核评论队列
+func (c *CommentController) ListPendingComments(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	comments, total, err := c.commentService.ListPendingComments(page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get pending comments"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "pending comments retrieved successfully",
+		"comments": comments,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// ApproveComment 审核通过评论
+func (c *CommentController) ApproveComment(ctx *gin.Context) {
+	c.moderate(ctx, c.commentService.ApproveComment, "comment approved successfully")
+}
+
+// RejectComment 审核拒绝评论
+func (c *CommentController) RejectComment(ctx *gin.Context) {
+	c.moderate(ctx, c.commentService.RejectComment, "comment rejected successfully")
+}
+
+// BulkApproveComments 批量审核通过评论
+func (c *CommentController) BulkApproveComments(ctx *gin.Context) {
+	var req models.BulkApproveCommentsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	approved, err := c.commentService.BulkApproveComments(req.IDs, ctx.GetUint("user_id"), req.Reason)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to approve comments")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve comments"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "comments approved successfully",
+		"approved": approved,
+	})
+}
+
+// GetCommentModerations 获取评论的审核记录
+func (c *CommentController) GetCommentModerations(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	moderations, err := c.commentService.GetCommentModerations(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get comment moderations"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":     "comment moderations retrieved successfully",
+		"moderations": moderations,
+	})
+}
+
+// moderate 处理单条评论审核请求
+func (c *CommentController) moderate(ctx *gin.Context, action func(commentID, moderatorID uint, reason string) (*models.Comment, error), message string) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	// 审核理由可选
+	var req models.ModerateCommentRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	comment, err := action(uint(id), ctx.GetUint("user_id"), req.Reason)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"comment": comment,
+	})
+}
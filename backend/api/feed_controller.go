@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/service"
+	"keep_learning_blog/utils/logger"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeedController 订阅源控制器
+type FeedController struct {
+	config      *config.Config
+	feedService service.FeedService
+}
+
+// NewFeedController 创建订阅源控制器
+func NewFeedController(config *config.Config) *FeedController {
+	return &FeedController{
+		config:      config,
+		feedService: service.FeedService{Site: &config.Site},
+	}
+}
+
+// RSS 全站 RSS 2.0 订阅源
+func (c *FeedController) RSS(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatRSS, "", "")
+}
+
+// Atom 全站 Atom 订阅源
+func (c *FeedController) Atom(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatAtom, "", "")
+}
+
+// JSON 全站 JSON Feed 订阅源
+func (c *FeedController) JSON(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatJSON, "", "")
+}
+
+// TagRSS 指定标签的 RSS 2.0 订阅源
+func (c *FeedController) TagRSS(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatRSS, "tag", ctx.Param("name"))
+}
+
+// TagAtom 指定标签的 Atom 订阅源
+func (c *FeedController) TagAtom(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatAtom, "tag", ctx.Param("name"))
+}
+
+// TagJSON 指定标签的 JSON Feed 订阅源
+func (c *FeedController) TagJSON(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatJSON, "tag", ctx.Param("name"))
+}
+
+// AuthorRSS 指定作者的 RSS 2.0 订阅源
+func (c *FeedController) AuthorRSS(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatRSS, "author", ctx.Param("username"))
+}
+
+// AuthorAtom 指定作者的 Atom 订阅源
+func (c *FeedController) AuthorAtom(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatAtom, "author", ctx.Param("username"))
+}
+
+// AuthorJSON 指定作者的 JSON Feed 订阅源
+func (c *FeedController) AuthorJSON(ctx *gin.Context) {
+	c.serveFeed(ctx, service.FeedFormatJSON, "author", ctx.Param("username"))
+}
+
+// serveFeed 输出订阅源，支持 If-None-Match / If-Modified-Since 条件请求
+func (c *FeedController) serveFeed(ctx *gin.Context, format, scope, value string) {
+	doc, err := c.feedService.GetFeed(ctx.Request.Context(), format, scope, value)
+	if err != nil {
+		if errors.Is(err, service.ErrFeedNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "feed not found"})
+			return
+		}
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":  err.Error(),
+			"format": format,
+			"scope":  scope,
+			"value":  value,
+		})).Error("Failed to generate feed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate feed"})
+		return
+	}
+
+	serveDocument(ctx, doc)
+}
+
+// serveDocument 输出带 ETag / Last-Modified 的生成文档，客户端缓存有效时返回 304
+func serveDocument(ctx *gin.Context, doc *service.CachedDocument) {
+	ctx.Header("ETag", doc.ETag)
+	ctx.Header("Last-Modified", doc.LastModified.UTC().Format(http.TimeFormat))
+	ctx.Header("Cache-Control", "public, max-age=300")
+
+	if notModified(ctx, doc.ETag, doc.LastModified) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.Data(http.StatusOK, doc.ContentType, doc.Body)
+}
+
+// notModified 判断客户端缓存是否仍然有效，If-None-Match 优先于 If-Modified-Since
+func notModified(ctx *gin.Context, etag string, lastModified time.Time) bool {
+	if match := ctx.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := ctx.GetHeader("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"keep_learning_blog/config"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"net/http"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportController 内容导入控制器
+type ImportController struct {
+	config        *config.Config
+	importService service.ImportService
+}
+
+// NewImportController 创建内容导入控制器
+func NewImportController(config *config.Config) *ImportController {
+	return &ImportController{
+		config:        config,
+		importService: service.ImportService{Import: &config.Import},
+	}
+}
+
+// ImportMarkdown 从 Hugo / Jekyll 的 Markdown 压缩包（zip、tar、tar.gz）导入文章
+func (c *ImportController) ImportMarkdown(ctx *gin.Context) {
+	var req models.ImportPostsRequest
+	data, ok := c.readImportFile(ctx, &req)
+	if !ok {
+		return
+	}
+
+	userID := ctx.GetUint("user_id")
+	report, err := c.importService.ImportMarkdownArchive(userID, data, req.DryRun)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+		})).Error("Failed to import markdown archive")
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "posts imported successfully",
+		"report":  report,
+	})
+}
+
+// readImportFile 绑定请求参数并读取上传的导入文件，失败时写入错误响应
+func (c *ImportController) readImportFile(ctx *gin.Context, req interface{}) ([]byte, bool) {
+	// 限制请求体大小，超出部分不会被读取
+	maxSize := c.config.Import.MaxArchiveSize
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize+multipartOverhead)
+
+	if err := ctx.ShouldBind(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return nil, false
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+			return nil, false
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return nil, false
+	}
+	if fileHeader.Size > maxSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		return nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"keep_learning_blog/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSController 令牌签名公钥控制器
+type JWKSController struct{}
+
+// NewJWKSController 创建令牌签名公钥控制器
+func NewJWKSController() *JWKSController {
+	return &JWKSController{}
+}
+
+// GetJWKS 公布令牌签名公钥，其他服务据此离线校验博客签发的令牌
+// 轮换期间旧密钥仍在列表中，客户端可按令牌头部的 kid 选择公钥
+func (c *JWKSController) GetJWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, middleware.PublicKeys())
+}
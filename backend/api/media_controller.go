@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"keep_learning_blog/config"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"net/http"
+	"strconv"
+
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead multipart 请求中文件以外内容（边界、表单字段）允许的大小
+const multipartOverhead = 1 << 20
+
+// MediaController 媒体文件控制器
+type MediaController struct {
+	config       *config.Config
+	mediaService *service.MediaService
+}
+
+// NewMediaController 创建媒体文件控制器
+func NewMediaController(config *config.Config, backend storage.Backend, processor *service.ImageProcessor) *MediaController {
+	return &MediaController{
+		config: config,
+		mediaService: &service.MediaService{
+			Storage:   backend,
+			Upload:    &config.Upload,
+			Image:     &config.Image,
+			Processor: processor,
+		},
+	}
+}
+
+// UploadMedia 上传媒体文件
+func (c *MediaController) UploadMedia(ctx *gin.Context) {
+	// 限制请求体大小，超出部分不会被读取
+	maxSize := c.config.Upload.MaxFileSize
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize+multipartOverhead)
+
+	var req models.UploadMediaRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+
+	userID := ctx.GetUint("user_id")
+	item, err := c.mediaService.UploadMedia(ctx.Request.Context(), userID, req.PostID, fileHeader.Filename, data)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":   err.Error(),
+			"user_id": userID,
+		})).Error("Failed to upload media")
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "media uploaded successfully",
+		"media":   item,
+	})
+}
+
+// GetUserMedia 获取当前用户上传的媒体文件
+func (c *MediaController) GetUserMedia(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	items, total, used, err := c.mediaService.GetUserMedia(ctx.GetUint("user_id"), page, pageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get media"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "media retrieved successfully",
+		"media":    items,
+		"total":    total,
+		"used":     used,
+		"quota":    c.config.Upload.UserQuota,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// AttachMedia 将媒体文件关联到文章
+func (c *MediaController) AttachMedia(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid media ID"})
+		return
+	}
+
+	var req models.AttachMediaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	item, err := c.mediaService.AttachMedia(uint(id), ctx.GetUint("user_id"), req.PostID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "media updated successfully",
+		"media":   item,
+	})
+}
+
+// DeleteMedia 删除媒体文件
+func (c *MediaController) DeleteMedia(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid media ID"})
+		return
+	}
+
+	if err := c.mediaService.DeleteMedia(ctx.Request.Context(), uint(id), ctx.GetUint("user_id")); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "media deleted successfully",
+	})
+}
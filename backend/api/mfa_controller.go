@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/middleware"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"net/http"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAController 两步验证控制器
+type MFAController struct {
+	config     *config.Config
+	mfaService *service.MFAService
+}
+
+// NewMFAController 创建两步验证控制器
+func NewMFAController(config *config.Config) *MFAController {
+	return &MFAController{
+		config:     config,
+		mfaService: newMFAService(config),
+	}
+}
+
+// newMFAService 根据配置创建两步验证服务
+func newMFAService(config *config.Config) *service.MFAService {
+	return &service.MFAService{
+		MFA:           &config.MFA,
+		EncryptionKey: config.Security.EncryptionKey,
+	}
+}
+
+// VerifyLogin 使用两步验证令牌和验证码完成登录
+func (c *MFAController) VerifyLogin(ctx *gin.Context) {
+	var req models.MFALoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, recoveryCodes, err := c.mfaService.VerifyChallenge(ctx.Request.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":     err.Error(),
+			"client_ip": ctx.ClientIP(),
+		})).Warn("Failed two-factor verification")
+
+		if errors.Is(err, service.ErrInvalidMFACode) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.respondError(ctx, err)
+		return
+	}
+
+	// 生成令牌对
+	accessToken, refreshToken, err := middleware.CreateTokenPair(ctx, user.ID, user.Username, &c.config.JWT)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})).Info("User logged in successfully with two-factor authentication")
+
+	// 返回成功响应，登录过程中完成绑定时附带恢复码
+	response := gin.H{
+		"message": "login successfully",
+		"user": gin.H{
+			"id":             user.ID,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": user.EmailVerifiedAt != nil,
+		},
+	}
+	if recoveryCodes != nil {
+		response["recovery_codes"] = recoveryCodes
+	}
+	respondTokens(ctx, &c.config.JWT, response, accessToken, refreshToken)
+}
+
+// EnrollLogin 角色要求两步验证而用户尚未启用时，在登录过程中生成绑定密钥
+func (c *MFAController) EnrollLogin(ctx *gin.Context) {
+	var req models.MFAEnrollRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := c.mfaService.BeginChallengeEnrollment(ctx.Request.Context(), req.MFAToken)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    "scan the QR code with your authenticator app and submit a code to finish login",
+		"enrollment": enrollment,
+	})
+}
+
+// GetStatus 获取当前用户的两步验证状态
+func (c *MFAController) GetStatus(ctx *gin.Context) {
+	status, err := c.mfaService.GetStatus(ctx.GetUint("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "two-factor status retrieved successfully",
+		"mfa":     status,
+	})
+}
+
+// BeginEnrollment 为当前用户生成待确认的 TOTP 密钥
+func (c *MFAController) BeginEnrollment(ctx *gin.Context) {
+	enrollment, err := c.mfaService.BeginEnrollment(ctx.Request.Context(), ctx.GetUint("user_id"))
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":    "scan the QR code with your authenticator app and confirm with a code",
+		"enrollment": enrollment,
+	})
+}
+
+// ConfirmEnrollment 使用验证码确认绑定并启用两步验证
+func (c *MFAController) ConfirmEnrollment(ctx *gin.Context) {
+	var req models.MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := c.mfaService.ConfirmEnrollment(ctx.Request.Context(), ctx.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "two-factor authentication enabled, store the recovery codes in a safe place",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// Disable 停用当前用户的两步验证
+func (c *MFAController) Disable(ctx *gin.Context) {
+	var req models.MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.mfaService.Disable(ctx.Request.Context(), ctx.GetUint("user_id"), req.Code); err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成当前用户的恢复码
+func (c *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req models.MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := c.mfaService.RegenerateRecoveryCodes(ctx.Request.Context(), ctx.GetUint("user_id"), req.Code)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":        "recovery codes regenerated, previous codes no longer work",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// respondError 将两步验证服务的错误转换为响应
+func (c *MFAController) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFAToken), errors.Is(err, service.ErrTooManyMFAAttempts):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidMFACode):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnabled),
+		errors.Is(err, service.ErrMFAEnrollmentNotFound):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrMFARequiredByRole):
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process two-factor request"})
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/middleware"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCController OpenID Connect 登录控制器
+type OIDCController struct {
+	config      *config.Config
+	oidcService *service.OIDCService
+	mfaService  *service.MFAService
+}
+
+// NewOIDCController 创建 OpenID Connect 登录控制器
+func NewOIDCController(config *config.Config) *OIDCController {
+	return &OIDCController{
+		config:      config,
+		oidcService: service.NewOIDCService(&config.OIDC),
+		mfaService:  newMFAService(config),
+	}
+}
+
+// GetProviders 获取已配置的身份提供方，供前端显示登录按钮
+func (c *OIDCController) GetProviders(ctx *gin.Context) {
+	providers := make([]string, 0, len(c.config.OIDC.Providers))
+	for _, provider := range c.config.OIDC.Providers {
+		providers = append(providers, provider.Name)
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "identity providers retrieved successfully",
+		"providers": providers,
+	})
+}
+
+// oidcBindingCookie 绑定授权请求与发起登录的浏览器的 Cookie，仅发送到 OpenID Connect 登录接口
+const (
+	oidcBindingCookie     = "oidc_binding"
+	oidcBindingCookiePath = "/api/auth/oidc/"
+)
+
+// Login 跳转到身份提供方的授权页面，并写入绑定当前浏览器的 Cookie
+func (c *OIDCController) Login(ctx *gin.Context) {
+	authURL, binding, err := c.oidcService.AuthCodeURL(ctx.Request.Context(), ctx.Param("provider"))
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	c.setBindingCookie(ctx, binding, int(c.config.OIDC.StateTTL.Seconds()))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 处理身份提供方的授权回调
+// 回调由浏览器整页跳转而来，结果不以 JSON 返回，而是跳转到前端页面 OIDC_FRONTEND_URL：
+// 成功时附加一次性登录兑换码 code，前端调用 POST /api/auth/oidc/exchange 换取令牌（或两步验证挑战）；失败时附加 error
+func (c *OIDCController) Callback(ctx *gin.Context) {
+	binding, _ := ctx.Cookie(oidcBindingCookie)
+	c.setBindingCookie(ctx, "", -1)
+
+	var req models.OIDCCallbackRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		c.redirectToFrontend(ctx, url.Values{"error": {"Login state is required"}})
+		return
+	}
+
+	// 用户在身份提供方拒绝授权
+	if req.Error != "" {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"provider":          ctx.Param("provider"),
+			"error":             req.Error,
+			"error_description": req.ErrorDescription,
+		})).Warn("Identity provider returned an error")
+
+		c.redirectToFrontend(ctx, url.Values{"error": {service.ErrOIDCAuthorizationFailed.Error()}})
+		return
+	}
+	if req.Code == "" {
+		c.redirectToFrontend(ctx, url.Values{"error": {"Authorization code is required"}})
+		return
+	}
+
+	user, linked, err := c.oidcService.Callback(ctx.Request.Context(), ctx.Param("provider"), req.State, binding, req.Code)
+	if err != nil {
+		c.redirectToFrontend(ctx, url.Values{"error": {c.callbackError(ctx, err)}})
+		return
+	}
+	if linked {
+		middleware.AuditSecurityEvent(ctx, "oidc_email_linked", map[string]interface{}{
+			"provider": ctx.Param("provider"),
+			"user_id":  user.ID,
+		})
+	}
+
+	code, err := c.oidcService.CreateLoginCode(ctx.Request.Context(), user.ID)
+	if err != nil {
+		c.redirectToFrontend(ctx, url.Values{"error": {c.callbackError(ctx, err)}})
+		return
+	}
+	c.redirectToFrontend(ctx, url.Values{"code": {code}})
+}
+
+// Exchange 兑换回调签发的一次性登录兑换码，按普通登录签发令牌对
+// header 模式下令牌在响应体中返回，cookie 模式下写入 Cookie；需要两步验证时返回挑战
+func (c *OIDCController) Exchange(ctx *gin.Context) {
+	var req models.OIDCExchangeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := c.oidcService.ExchangeLoginCode(ctx.Request.Context(), req.Code)
+	if err != nil {
+		c.respondError(ctx, err)
+		return
+	}
+
+	completeLogin(ctx, c.config, c.mfaService, user)
+}
+
+// setBindingCookie 写入或删除（maxAge 小于 0）绑定浏览器的 Cookie
+// 回调是从身份提供方跳转回来的跨站顶级导航，SameSite 需为 Lax 才会携带
+func (c *OIDCController) setBindingCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    value,
+		Path:     oidcBindingCookiePath,
+		MaxAge:   maxAge,
+		Secure:   c.config.JWT.Cookie.Secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectToFrontend 携带参数跳转到前端的登录结果页面
+func (c *OIDCController) redirectToFrontend(ctx *gin.Context, params url.Values) {
+	target := c.config.OIDC.FrontendURL
+	if strings.Contains(target, "?") {
+		target += "&" + params.Encode()
+	} else {
+		target += "?" + params.Encode()
+	}
+	ctx.Redirect(http.StatusFound, target)
+}
+
+// callbackError 将回调错误转换为传给前端的错误信息，未知错误只记录日志
+func (c *OIDCController) callbackError(ctx *gin.Context, err error) string {
+	switch {
+	case errors.Is(err, service.ErrOIDCProviderNotFound), errors.Is(err, service.ErrInvalidOIDCState),
+		errors.Is(err, service.ErrOIDCEmailRequired), errors.Is(err, service.ErrOIDCAuthorizationFailed),
+		errors.Is(err, service.ErrOIDCEmailTaken), errors.Is(err, service.ErrOIDCProviderUnavailable):
+		return err.Error()
+	default:
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"provider": ctx.Param("provider"),
+			"error":    err.Error(),
+		})).Error("Failed to process identity provider login")
+		return "Failed to login with identity provider"
+	}
+}
+
+// respondError 将 OpenID Connect 登录服务的错误转换为响应
+func (c *OIDCController) respondError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOIDCProviderNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidOIDCState), errors.Is(err, service.ErrOIDCEmailRequired),
+		errors.Is(err, service.ErrInvalidOIDCLoginCode):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCAuthorizationFailed):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCEmailTaken):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOIDCProviderUnavailable):
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	default:
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"provider": ctx.Param("provider"),
+			"error":    err.Error(),
+		})).Error("Failed to process identity provider login")
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login with identity provider"})
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PersonalAccessTokenController 个人访问令牌控制器
+type PersonalAccessTokenController struct {
+	tokenService *service.PersonalAccessTokenService
+}
+
+// NewPersonalAccessTokenController 创建个人访问令牌控制器
+func NewPersonalAccessTokenController(config *config.Config) *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		tokenService: &service.PersonalAccessTokenService{
+			PersonalAccessToken: &config.PersonalAccessToken,
+		},
+	}
+}
+
+// CreateToken 为当前用户创建个人访问令牌
+func (c *PersonalAccessTokenController) CreateToken(ctx *gin.Context) {
+	var req models.CreatePersonalAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, raw, err := c.tokenService.CreateToken(ctx.GetUint("user_id"), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTokenScopes), errors.Is(err, service.ErrInvalidTokenExpiry):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrTooManyPersonalAccessTokens):
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create personal access token"})
+		}
+		return
+	}
+
+	// 返回成功响应，令牌原文只返回这一次
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message":               "personal access token created, copy it now as it will not be shown again",
+		"token":                 raw,
+		"personal_access_token": token,
+	})
+}
+
+// GetTokens 获取当前用户的所有个人访问令牌
+func (c *PersonalAccessTokenController) GetTokens(ctx *gin.Context) {
+	tokens, err := c.tokenService.ListTokens(ctx.GetUint("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "personal access tokens retrieved successfully",
+		"tokens":  tokens,
+	})
+}
+
+// RevokeToken 撤销当前用户的指定个人访问令牌
+func (c *PersonalAccessTokenController) RevokeToken(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	if err := c.tokenService.RevokeToken(ctx.GetUint("user_id"), uint(id)); err != nil {
+		if errors.Is(err, service.ErrPersonalAccessTokenNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "personal access token revoked successfully",
+	})
+}
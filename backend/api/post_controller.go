@@ -1,25 +1,33 @@
 package api
 
 import (
+	"keep_learning_blog/config"
 	"keep_learning_blog/middleware"
 	"keep_learning_blog/models"
 	"keep_learning_blog/service"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"keep_learning_blog/utils/logger"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 查看所有用户草稿及归档文章的权限编码
+const viewDraftsPermission = "posts:select:drafts"
+
 // PostController 文章控制器
 type PostController struct {
+	config      *config.Config
 	postService service.PostService
 }
 
 // NewPostController 创建文章控制器
-func NewPostController() *PostController {
+func NewPostController(config *config.Config) *PostController {
 	return &PostController{
+		config:      config,
 		postService: service.PostService{},
 	}
 }
@@ -36,8 +44,8 @@ func (c *PostController) CreatePost(ctx *gin.Context) {
 		return
 	}
 
+	// 标题使用普通文本过滤，内容以 Markdown 源文本保存，渲染后的 HTML 由业务层过滤
 	req.Title = middleware.SanitizeText(req.Title)
-	req.Content = middleware.SanitizeHTML(req.Content)
 
 	userID, exists := ctx.Get("user_id")
 	if !exists {
@@ -46,7 +54,7 @@ func (c *PostController) CreatePost(ctx *gin.Context) {
 		return
 	}
 
-	post, err := c.postService.CreatePost(req.Title, req.Content, userID.(uint), req.TagNames)
+	post, err := c.postService.CreatePost(req.Title, req.Content, userID.(uint), req.TagNames, req.Status, req.PublishAt, req.CoverMediaID)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields(map[string]interface{}{
 			"error":   err.Error(),
@@ -80,13 +88,45 @@ func (c *PostController) GetPost(ctx *gin.Context) {
 		return
 	}
 
-	// 获取文章
-	post, err := c.postService.GetPost(uint(id))
+	// 获取文章（草稿仅作者及拥有权限的角色可见）
+	canViewDrafts := middleware.HasPermission(ctx, c.config, viewDraftsPermission)
+	post, err := c.postService.GetPost(uint(id), ctx.GetUint("user_id"), canViewDrafts)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "post retrieved successfully",
+		"post":    post,
+	})
+}
+
+// GetPostBySlug 根据 slug 获取文章，旧 slug 返回指向新 slug 的永久重定向
+func (c *PostController) GetPostBySlug(ctx *gin.Context) {
+	postSlug := ctx.Param("slug")
+
+	// 获取文章（草稿仅作者及拥有权限的角色可见）
+	canViewDrafts := middleware.HasPermission(ctx, c.config, viewDraftsPermission)
+	post, redirectSlug, err := c.postService.GetPostBySlug(postSlug, ctx.GetUint("user_id"), canViewDrafts)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
 
+	// 标题已修改，重定向到新的 slug
+	if redirectSlug != "" {
+		location := "/api/posts/by-slug/" + url.PathEscape(redirectSlug)
+		ctx.Header("Location", location)
+		ctx.JSON(http.StatusMovedPermanently, gin.H{
+			"message":  "post slug has changed",
+			"slug":     redirectSlug,
+			"location": location,
+		})
+		return
+	}
+
 	// 返回成功响应
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "post retrieved successfully",
@@ -99,9 +139,11 @@ func (c *PostController) GetAllPosts(ctx *gin.Context) {
 	// 解析分页参数
 	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "10"))
+	status := ctx.Query("status")
 
 	// 获取文章列表
-	posts, total, err := c.postService.GetAllPosts(page, pageSize)
+	canViewDrafts := middleware.HasPermission(ctx, c.config, viewDraftsPermission)
+	posts, total, err := c.postService.GetAllPosts(page, pageSize, status, ctx.GetUint("user_id"), canViewDrafts)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -115,6 +157,45 @@ func (c *PostController) GetAllPosts(ctx *gin.Context) {
 	})
 }
 
+// SearchPosts 全文搜索文章
+func (c *PostController) SearchPosts(ctx *gin.Context) {
+	// 解析查询参数
+	var req models.SearchPostsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 标签支持逗号分隔
+	var tags []string
+	for _, tag := range req.Tags {
+		for _, name := range strings.Split(tag, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				tags = append(tags, name)
+			}
+		}
+	}
+
+	// 搜索文章
+	results, total, err := c.postService.SearchPosts(strings.TrimSpace(req.Query), tags, req.From, req.To, req.Page, req.PageSize)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error": err.Error(),
+			"query": req.Query,
+		})).Error("Failed to search posts")
+
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search posts"})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "posts searched successfully",
+		"results": results,
+		"total":   total,
+	})
+}
+
 // UpdatePost 更新文章
 func (c *PostController) UpdatePost(ctx *gin.Context) {
 	// 解析文章ID
@@ -131,9 +212,8 @@ func (c *PostController) UpdatePost(ctx *gin.Context) {
 		return
 	}
 
-	// 对标题使用普通文本过滤，对内容使用 HTML 过滤
+	// 标题使用普通文本过滤，内容以 Markdown 源文本保存，渲染后的 HTML 由业务层过滤
 	req.Title = middleware.SanitizeText(req.Title)
-	req.Content = middleware.SanitizeHTML(req.Content)
 
 	// 从上下文获取用户ID
 	userID, exists := ctx.Get("user_id")
@@ -143,7 +223,7 @@ func (c *PostController) UpdatePost(ctx *gin.Context) {
 	}
 
 	// 更新文章
-	post, err := c.postService.UpdatePost(uint(id), userID.(uint), req.Title, req.Content, req.TagNames)
+	post, err := c.postService.UpdatePost(uint(id), userID.(uint), req.Title, req.Content, req.TagNames, req.PublishAt, req.CoverMediaID)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -183,7 +263,7 @@ func (c *PostController) DeletePost(ctx *gin.Context) {
 	})
 }
 
-// GetPostComments 获取文章的所有评论
+// GetPostComments 获取文章评论，默认分页返回顶层评论，mode=tree 时返回完整评论树
 func (c *PostController) GetPostComments(ctx *gin.Context) {
 	postID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
@@ -191,8 +271,34 @@ func (c *PostController) GetPostComments(ctx *gin.Context) {
 		return
 	}
 
-	// 获取文章的所有评论
-	comments, total, err := c.postService.GetPostComments(uint(postID))
+	var req models.ListCommentsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	canViewDrafts := middleware.HasPermission(ctx, c.config, viewDraftsPermission)
+	canModerate := middleware.HasPermission(ctx, c.config, moderateCommentsPermission)
+	viewerID := ctx.GetUint("user_id")
+
+	// 树形模式返回完整评论树
+	if req.Mode == "tree" {
+		comments, total, err := c.postService.GetPostCommentTree(uint(postID), viewerID, canViewDrafts, canModerate)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":  "comments retrieved successfully",
+			"comments": comments,
+			"total":    total,
+		})
+		return
+	}
+
+	// 默认分页返回顶层评论
+	comments, total, err := c.postService.GetPostComments(uint(postID), viewerID, canViewDrafts, canModerate, req.Page, req.PageSize)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -203,6 +309,223 @@ func (c *PostController) GetPostComments(ctx *gin.Context) {
 		"message":  "comments retrieved successfully",
 		"comments": comments,
 		"total":    total,
+		"page":     req.Page,
+		"pageSize": req.PageSize,
+	})
+}
+
+// GetCommentReplies 分页获取评论的直接回复
+func (c *PostController) GetCommentReplies(ctx *gin.Context) {
+	postID, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
+	commentID, err := strconv.ParseUint(ctx.Param("comment_id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return
+	}
+
+	var req models.ListCommentsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	canViewDrafts := middleware.HasPermission(ctx, c.config, viewDraftsPermission)
+	canModerate := middleware.HasPermission(ctx, c.config, moderateCommentsPermission)
+	replies, total, err := c.postService.GetCommentReplies(uint(postID), uint(commentID), ctx.GetUint("user_id"), canViewDrafts, canModerate, req.Page, req.PageSize)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "replies retrieved successfully",
+		"replies":  replies,
+		"total":    total,
+		"page":     req.Page,
+		"pageSize": req.PageSize,
+	})
+}
+
+// PublishPost 发布文章
+func (c *PostController) PublishPost(ctx *gin.Context) {
+	c.changePostStatus(ctx, c.postService.PublishPost, "post published successfully")
+}
+
+// UnpublishPost 撤回文章为草稿
+func (c *PostController) UnpublishPost(ctx *gin.Context) {
+	c.changePostStatus(ctx, c.postService.UnpublishPost, "post unpublished successfully")
+}
+
+// ArchivePost 归档文章
+func (c *PostController) ArchivePost(ctx *gin.Context) {
+	c.changePostStatus(ctx, c.postService.ArchivePost, "post archived successfully")
+}
+
+// changePostStatus 处理文章状态变更请求
+func (c *PostController) changePostStatus(ctx *gin.Context, change func(id, userID uint) (*models.Post, error), message string) {
+	// 解析文章ID
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
+
+	// 从上下文获取用户ID
+	userID := ctx.GetUint("user_id")
+	if userID == 0 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// 变更文章状态
+	post, err := change(uint(id), userID)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":   err.Error(),
+			"post_id": id,
+			"user_id": userID,
+		})).Error("Failed to change post status")
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"post_id": post.ID,
+		"user_id": userID,
+		"status":  post.Status,
+	})).Info("Post status changed successfully")
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"post":    post,
+	})
+}
+
+// PreviewMarkdown 预览 Markdown 渲染结果（不保存）
+func (c *PostController) PreviewMarkdown(ctx *gin.Context) {
+	var req models.PreviewMarkdownRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 渲染 Markdown
+	contentHTML, err := c.postService.PreviewMarkdown(req.Content)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":      "markdown rendered successfully",
+		"content_html": contentHTML,
+	})
+}
+
+// GetPostRevisions 获取文章修订历史
+func (c *PostController) GetPostRevisions(ctx *gin.Context) {
+	// 解析文章ID
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
+
+	// 获取修订历史
+	revisions, err := c.postService.GetPostRevisions(uint(id), ctx.GetUint("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":   "revisions retrieved successfully",
+		"revisions": revisions,
+		"total":     len(revisions),
+	})
+}
+
+// DiffPostRevisions 比较文章两个修订版本的差异
+func (c *PostController) DiffPostRevisions(ctx *gin.Context) {
+	// 解析文章ID
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
+
+	// 解析版本号
+	from, err := strconv.Atoi(ctx.Query("from"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid from version"})
+		return
+	}
+	to, err := strconv.Atoi(ctx.Query("to"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid to version"})
+		return
+	}
+
+	// 生成差异
+	diff, err := c.postService.DiffPostRevisions(uint(id), ctx.GetUint("user_id"), from, to)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "revision diff generated successfully",
+		"from":    from,
+		"to":      to,
+		"diff":    diff,
+	})
+}
+
+// RestorePostRevision 恢复文章修订版本
+func (c *PostController) RestorePostRevision(ctx *gin.Context) {
+	// 解析文章ID
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return
+	}
+
+	// 解析版本号
+	version, err := strconv.Atoi(ctx.Param("version"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision version"})
+		return
+	}
+
+	// 恢复修订版本
+	userID := ctx.GetUint("user_id")
+	post, err := c.postService.RestorePostRevision(uint(id), userID, version)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error":   err.Error(),
+			"post_id": id,
+			"user_id": userID,
+			"version": version,
+		})).Error("Failed to restore post revision")
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "post revision restored successfully",
+		"post":    post,
 	})
 }
 
@@ -37,7 +37,7 @@ func (c *RoleController) CreateRole(ctx *gin.Context) {
 		return
 	}
 
-	role, err := c.roleService.CreateRole(req.Name, req.Code, req.Description, req.PermissionIDs, req.IsDefault)
+	role, err := c.roleService.CreateRole(req.Name, req.Code, req.Description, req.PermissionIDs, req.IsDefault, req.RequireMFA)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields(map[string]interface{}{
 			"error": err.Error(),
@@ -118,7 +118,7 @@ func (c *RoleController) UpdateRole(ctx *gin.Context) {
 	}
 
 	// 更新角色
-	role, err := c.roleService.UpdateRole(uint(id), req.Name, req.Code, req.Description, req.IsDefault)
+	role, err := c.roleService.UpdateRole(uint(id), req.Name, req.Code, req.Description, req.IsDefault, req.RequireMFA)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
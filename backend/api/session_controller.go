@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"keep_learning_blog/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionController 登录会话控制器
+type SessionController struct {
+	sessionService service.SessionService
+}
+
+// NewSessionController 创建登录会话控制器
+func NewSessionController() *SessionController {
+	return &SessionController{
+		sessionService: service.SessionService{},
+	}
+}
+
+// GetSessions 获取当前用户的所有登录会话
+func (c *SessionController) GetSessions(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+
+	sessions, err := c.sessionService.ListSessions(ctx.Request.Context(), userID, ctx.GetString("session_id"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "sessions retrieved successfully",
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession 撤销当前用户的指定会话
+func (c *SessionController) RevokeSession(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+
+	if err := c.sessionService.RevokeSession(ctx.Request.Context(), userID, ctx.Param("id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "session revoked successfully",
+	})
+}
+
+// RevokeAllSessions 撤销当前用户的所有会话，在所有设备上退出登录
+func (c *SessionController) RevokeAllSessions(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+
+	if err := c.sessionService.RevokeAllSessions(ctx.Request.Context(), userID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "logged out from all sessions",
+	})
+}
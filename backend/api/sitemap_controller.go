@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/service"
+	"keep_learning_blog/utils/logger"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SitemapController 站点地图控制器
+type SitemapController struct {
+	config         *config.Config
+	sitemapService service.SitemapService
+}
+
+// NewSitemapController 创建站点地图控制器
+func NewSitemapController(config *config.Config) *SitemapController {
+	return &SitemapController{
+		config:         config,
+		sitemapService: service.SitemapService{Site: &config.Site},
+	}
+}
+
+// GetSitemap 获取站点地图，URL 过多时返回站点地图索引
+func (c *SitemapController) GetSitemap(ctx *gin.Context) {
+	doc, err := c.sitemapService.GetSitemap(ctx.Request.Context())
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to generate sitemap")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate sitemap"})
+		return
+	}
+	serveDocument(ctx, doc)
+}
+
+// GetSitemapPage 获取站点地图索引中的分页，路径形如 /sitemaps/1.xml
+func (c *SitemapController) GetSitemapPage(ctx *gin.Context) {
+	page, err := strconv.Atoi(strings.TrimSuffix(ctx.Param("file"), ".xml"))
+	if err != nil || !strings.HasSuffix(ctx.Param("file"), ".xml") {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+		return
+	}
+
+	doc, err := c.sitemapService.GetSitemapPage(ctx.Request.Context(), page)
+	if err != nil {
+		if errors.Is(err, service.ErrSitemapNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "sitemap not found"})
+			return
+		}
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error": err.Error(),
+			"page":  page,
+		})).Error("Failed to generate sitemap page")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate sitemap"})
+		return
+	}
+	serveDocument(ctx, doc)
+}
+
+// GetRobots 获取 robots.txt
+func (c *SitemapController) GetRobots(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", c.sitemapService.GetRobots())
+}
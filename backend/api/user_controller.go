@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"keep_learning_blog/middleware"
 	"keep_learning_blog/models"
 	"keep_learning_blog/service"
@@ -10,21 +11,38 @@ import (
 	"keep_learning_blog/config"
 	"keep_learning_blog/db"
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/mail"
+	"keep_learning_blog/utils/spam"
 
 	"github.com/gin-gonic/gin"
 )
 
 // UserController 用户控制器
 type UserController struct {
-	config      *config.Config
-	userService *service.UserService
+	config              *config.Config
+	userService         *service.UserService
+	verificationService *service.EmailVerificationService
+	resetService        *service.PasswordResetService
+	mfaService          *service.MFAService
 }
 
 // NewUserController 创建用户控制器
-func NewUserController(config *config.Config) *UserController {
+func NewUserController(config *config.Config, mailer mail.Sender) *UserController {
 	return &UserController{
 		config:      config,
-		userService: &service.UserService{},
+		userService: &service.UserService{SpamFilter: spam.NewFilterFromConfig(&config.Spam, &service.SpamTokenStore{})},
+		verificationService: &service.EmailVerificationService{
+			Mailer:       mailer,
+			Site:         &config.Site,
+			Verification: &config.EmailVerification,
+		},
+		resetService: &service.PasswordResetService{
+			Mailer:        mailer,
+			Site:          &config.Site,
+			PasswordReset: &config.PasswordReset,
+			RateLimit:     &config.RateLimit,
+		},
+		mfaService: newMFAService(config),
 	}
 }
 
@@ -43,7 +61,7 @@ func (c *UserController) Register(ctx *gin.Context) {
 	req.Username = middleware.SanitizeText(req.Username)
 	req.Email = middleware.SanitizeText(req.Email)
 
-	user, err := c.userService.Register(req.Username, req.Password, req.Email)
+	user, err := c.userService.Register(req.Username, req.Password, req.Email, req.Website)
 	if err != nil {
 		logger.Log.WithFields(logger.Fields(map[string]interface{}{
 			"error":    err.Error(),
@@ -61,13 +79,102 @@ func (c *UserController) Register(ctx *gin.Context) {
 		"email":    user.Email,
 	})).Info("User registered successfully")
 
+	// 发送验证邮件，失败时用户可登录后重发
+	message := "user registered successfully, please check your email to verify your address"
+	if err := c.verificationService.SendVerification(ctx.Request.Context(), user); err != nil {
+		message = "user registered successfully, but the verification email could not be sent, please request a new one after login"
+	}
+
 	// 返回成功响应
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "user registered successfully",
+		"message": message,
 		"user":    user,
 	})
 }
 
+// VerifyEmail 通过验证链接验证邮箱
+func (c *UserController) VerifyEmail(ctx *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Verification token is required"})
+		return
+	}
+
+	user, err := c.verificationService.VerifyEmail(req.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":           "email verified successfully",
+		"email_verified_at": user.EmailVerifiedAt,
+	})
+}
+
+// ResendVerification 重新发送当前用户的验证邮件
+func (c *UserController) ResendVerification(ctx *gin.Context) {
+	userID := ctx.GetUint("user_id")
+
+	if err := c.verificationService.ResendVerification(ctx.Request.Context(), userID); err != nil {
+		if errors.Is(err, service.ErrEmailAlreadyVerified) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "verification email sent",
+	})
+}
+
+// ForgotPassword 发送密码重置邮件
+func (c *UserController) ForgotPassword(ctx *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.resetService.ForgotPassword(req.Email)
+
+	// 无论邮箱是否存在都返回相同的响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "if the email is registered, a password reset link has been sent",
+	})
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func (c *UserController) ResetPassword(ctx *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.resetService.ResetPassword(ctx.Request.Context(), req.Token, req.Password); err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"error": err.Error(),
+		})).Warn("Failed to reset password")
+
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 返回成功响应
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "password reset successfully, please login again",
+	})
+}
+
 // CreateUser 创建用户
 func (c *UserController) CreateUser(ctx *gin.Context) {
 	// 解析请求体
@@ -119,24 +226,71 @@ func (c *UserController) Login(ctx *gin.Context) {
 	// 登录成功，记录成功并清除失败计数
 	loginLimiter.RecordLoginAttempt(ctx, true, identifier)
 
+	completeLogin(ctx, c.config, c.mfaService, user)
+}
+
+// completeLogin 用户通过身份认证后签发令牌对
+// 已启用两步验证或角色要求两步验证时，返回两步验证令牌，提交验证码后再签发令牌对
+func completeLogin(ctx *gin.Context, cfg *config.Config, mfaService *service.MFAService, user *models.User) {
+	mfaEnabled, mfaRequired, err := mfaService.LoginRequirement(user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if mfaEnabled || mfaRequired {
+		mfaToken, err := mfaService.CreateChallenge(ctx.Request.Context(), user.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create two-factor challenge"})
+			return
+		}
+
+		// 未启用时需先通过 /login/mfa/enroll 绑定验证器应用
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":              "two-factor verification required",
+			"mfa_required":         true,
+			"mfa_enrolled":         mfaEnabled,
+			"mfa_token":            mfaToken,
+			"mfa_token_expires_in": int(cfg.MFA.ChallengeTTL.Seconds()),
+		})
+		return
+	}
+
 	// 生成令牌对
-	accessToken, refreshToken, err := middleware.CreateTokenPair(user.ID, user.Username, &c.config.JWT)
+	accessToken, refreshToken, err := middleware.CreateTokenPair(ctx, user.ID, user.Username, &cfg.JWT)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
 
 	// 返回成功响应
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":       "login successfully",
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+	respondTokens(ctx, &cfg.JWT, gin.H{
+		"message": "login successfully",
 		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
+			"id":             user.ID,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": user.EmailVerifiedAt != nil,
 		},
-	})
+	}, accessToken, refreshToken)
+}
+
+// respondTokens 返回签发的令牌对
+// header 模式下令牌放在响应体中；cookie 模式下写入 HttpOnly Cookie，响应体只返回 CSRF 令牌
+func respondTokens(ctx *gin.Context, cfg *config.JWTConfig, response gin.H, accessToken, refreshToken string) {
+	if cfg.Mode != config.AuthModeCookie {
+		response["access_token"] = accessToken
+		response["refresh_token"] = refreshToken
+		ctx.JSON(http.StatusOK, response)
+		return
+	}
+
+	csrfToken, err := middleware.SetAuthCookies(ctx, cfg, accessToken, refreshToken)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+	response["csrf_token"] = csrfToken
+	ctx.JSON(http.StatusOK, response)
 }
 
 // RefreshToken 处理令牌刷新请求
@@ -149,11 +303,9 @@ func (c *UserController) RefreshToken(ctx *gin.Context) {
 	}
 
 	// 返回成功响应
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":       "token refreshed successfully",
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
-	})
+	respondTokens(ctx, &c.config.JWT, gin.H{
+		"message": "token refreshed successfully",
+	}, accessToken, refreshToken)
 }
 
 // Logout 处理登出请求
@@ -171,6 +323,17 @@ func (c *UserController) Logout(ctx *gin.Context) {
 		return
 	}
 
+	// 删除当前会话，使 refresh token 同时失效
+	if _, err := db.DeleteSession(ctx, ctx.GetUint("user_id"), ctx.GetString("session_id")); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	// cookie 模式下删除令牌 Cookie
+	if c.config.JWT.Mode == config.AuthModeCookie {
+		middleware.ClearAuthCookies(ctx, &c.config.JWT)
+	}
+
 	// 返回成功响应
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Successfully logged out",
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"keep_learning_blog/api"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/middleware"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const (
+	testFrontendURL = "http://frontend.test/login/oidc"
+	testRedirectURL = "http://blog.test/api/auth/oidc/mock/callback"
+)
+
+var setupOnce sync.Once
+
+// testEnv 连接模拟身份提供方的博客 OpenID Connect 登录接口，Redis 和数据库使用内存实现
+type testEnv struct {
+	router *gin.Engine
+	redis  *miniredis.Miniredis
+}
+
+// newTestEnv 启动模拟的身份提供方，并以真实的 OIDCController 和 OIDCService 注册登录路由
+func newTestEnv(t *testing.T, linkVerifiedEmail bool) *testEnv {
+	t.Helper()
+
+	cfg := config.GetConfig()
+	cfg.JWT.TemporaryKey = true
+	setupOnce.Do(func() {
+		gin.SetMode(gin.TestMode)
+		logger.Log, logger.AuditLog = logrus.New(), logrus.New()
+		logger.Log.SetOutput(io.Discard)
+		logger.AuditLog.SetOutput(io.Discard)
+		if err := middleware.InitSigningKeys(&cfg.JWT); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	mr := miniredis.RunT(t)
+	db.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "blog.db")), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.AutoMigrate(&models.Permission{}, &models.Role{}, &models.User{}, &models.UserIdentity{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Create(&models.Role{Name: "user", Code: "user", IsDefault: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.DB = database
+
+	idp, err := newMockIdP("", "blog", "secret", map[string]interface{}{
+		"sub":                "mock-user-1",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(idp.handler())
+	t.Cleanup(server.Close)
+	idp.issuer = server.URL
+
+	cfg.OIDC.FrontendURL = testFrontendURL
+	cfg.OIDC.LinkVerifiedEmail = linkVerifiedEmail
+	cfg.OIDC.Providers = []config.OIDCProviderConfig{{
+		Name:         "mock",
+		Issuer:       server.URL,
+		ClientID:     "blog",
+		ClientSecret: "secret",
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "profile", "email"},
+	}}
+
+	controller := api.NewOIDCController(cfg)
+	router := gin.New()
+	router.GET("/api/auth/oidc/:provider/login", controller.Login)
+	router.GET("/api/auth/oidc/:provider/callback", controller.Callback)
+	router.POST("/api/auth/oidc/exchange", controller.Exchange)
+
+	return &testEnv{router: router, redis: mr}
+}
+
+// serve 向博客发送请求
+func (e *testEnv) serve(req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	e.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// login 发起登录，经身份提供方授权后返回博客的回调地址及绑定浏览器的 Cookie
+func (e *testEnv) login(t *testing.T) (*url.URL, *http.Cookie) {
+	t.Helper()
+
+	resp := e.serve(httptest.NewRequest(http.MethodGet, "/api/auth/oidc/mock/login", nil))
+	if resp.Code != http.StatusFound {
+		t.Fatalf("login returned %d: %s", resp.Code, resp.Body.String())
+	}
+	var binding *http.Cookie
+	for _, cookie := range resp.Result().Cookies() {
+		if cookie.Name == "oidc_binding" {
+			binding = cookie
+		}
+	}
+	if binding == nil || binding.Value == "" || !binding.HttpOnly {
+		t.Fatalf("login did not set an HttpOnly binding cookie: %+v", binding)
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	idpResp, err := noRedirect.Get(resp.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idpResp.Body.Close()
+	if idpResp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", idpResp.StatusCode)
+	}
+	callback, err := idpResp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback, binding
+}
+
+// callback 携带 Cookie 访问回调地址，返回跳转到前端页面时附加的参数
+func (e *testEnv) callback(t *testing.T, callback *url.URL, binding *http.Cookie) url.Values {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	if binding != nil {
+		req.AddCookie(binding)
+	}
+	resp := e.serve(req)
+	if resp.Code != http.StatusFound {
+		t.Fatalf("callback returned %d: %s", resp.Code, resp.Body.String())
+	}
+	location, err := url.Parse(resp.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testFrontendURL {
+		t.Fatalf("callback redirected to %q, want %q", got, testFrontendURL)
+	}
+	return location.Query()
+}
+
+// exchange 兑换登录兑换码
+func (e *testEnv) exchange(code string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(models.OIDCExchangeRequest{Code: code})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/oidc/exchange", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	return e.serve(req)
+}
+
+// rewriteState 修改 Redis 中保存的授权请求数据，模拟其他登录流程的 nonce 或 code_verifier
+func (e *testEnv) rewriteState(t *testing.T, callback *url.URL, field, value string) {
+	t.Helper()
+
+	key := db.OIDCStatePrefix + callback.Query().Get("state")
+	var saved map[string]string
+	if err := json.Unmarshal([]byte(e.redis.HGet(key, "data")), &saved); err != nil {
+		t.Fatal(err)
+	}
+	saved[field] = value
+	data, _ := json.Marshal(saved)
+	e.redis.HSet(key, "data", string(data))
+}
+
+func TestLogin(t *testing.T) {
+	env := newTestEnv(t, false)
+
+	callback, binding := env.login(t)
+	result := env.callback(t, callback, binding)
+	if result.Get("error") != "" || result.Get("code") == "" {
+		t.Fatalf("callback result = %v, want a login code", result)
+	}
+
+	resp := env.exchange(result.Get("code"))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("exchange returned %d: %s", resp.Code, resp.Body.String())
+	}
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		User         struct {
+			Username      string `json:"username"`
+			Email         string `json:"email"`
+			EmailVerified bool   `json:"email_verified"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.AccessToken == "" || body.RefreshToken == "" {
+		t.Fatalf("exchange did not return tokens: %s", resp.Body.String())
+	}
+	if body.User.Username != "alice" || body.User.Email != "alice@example.com" || !body.User.EmailVerified {
+		t.Fatalf("unexpected user: %+v", body.User)
+	}
+
+	var identity models.UserIdentity
+	if err := db.DB.Where("provider = ? AND subject = ?", "mock", "mock-user-1").First(&identity).Error; err != nil {
+		t.Fatalf("identity not linked: %v", err)
+	}
+}
+
+func TestLoginCodeReuse(t *testing.T) {
+	env := newTestEnv(t, false)
+
+	callback, binding := env.login(t)
+	code := env.callback(t, callback, binding).Get("code")
+	if resp := env.exchange(code); resp.Code != http.StatusOK {
+		t.Fatalf("first exchange returned %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp := env.exchange(code)
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), "invalid or expired login code") {
+		t.Fatalf("reused login code: %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := env.exchange("forged-code"); resp.Code != http.StatusBadRequest {
+		t.Fatalf("unknown login code returned %d", resp.Code)
+	}
+}
+
+func TestBindingCookieMismatch(t *testing.T) {
+	env := newTestEnv(t, false)
+	callback, binding := env.login(t)
+
+	cases := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{"missing cookie", nil},
+		{"other browser", &http.Cookie{Name: "oidc_binding", Value: "attacker-binding"}},
+	}
+	for _, tc := range cases {
+		result := env.callback(t, callback, tc.cookie)
+		if result.Get("error") != "invalid or expired login state" || result.Get("code") != "" {
+			t.Fatalf("%s: callback result = %v, want invalid state", tc.name, result)
+		}
+	}
+
+	// 不匹配的回调不会消耗 state，发起登录的浏览器仍可完成登录
+	if result := env.callback(t, callback, binding); result.Get("code") == "" {
+		t.Fatalf("original browser rejected after mismatched attempts: %v", result)
+	}
+}
+
+func TestStateTamperedOrReplayed(t *testing.T) {
+	env := newTestEnv(t, false)
+	callback, binding := env.login(t)
+
+	tampered := *callback
+	query := tampered.Query()
+	query.Set("state", "forged-state")
+	tampered.RawQuery = query.Encode()
+	if result := env.callback(t, &tampered, binding); result.Get("error") != "invalid or expired login state" {
+		t.Fatalf("tampered state: callback result = %v", result)
+	}
+
+	if result := env.callback(t, callback, binding); result.Get("code") == "" {
+		t.Fatalf("callback failed: %v", result)
+	}
+	if result := env.callback(t, callback, binding); result.Get("error") != "invalid or expired login state" {
+		t.Fatalf("replayed state: callback result = %v", result)
+	}
+}
+
+func TestIDTokenChecks(t *testing.T) {
+	cases := []struct {
+		name  string
+		field string
+		value string
+	}{
+		{"nonce mismatch", "nonce", "nonce-of-another-login"},
+		{"code_verifier mismatch", "code_verifier", "verifier-of-another-login-0123456789abcdefghijk"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, false)
+			callback, binding := env.login(t)
+			env.rewriteState(t, callback, tc.field, tc.value)
+
+			if result := env.callback(t, callback, binding); result.Get("error") != "identity provider authorization failed" {
+				t.Fatalf("callback result = %v, want authorization failure", result)
+			}
+		})
+	}
+}
+
+func TestVerifiedEmailLinking(t *testing.T) {
+	cases := []struct {
+		name      string
+		link      bool
+		wantError string
+	}{
+		{"linking disabled", false, "email already registered, please login with password"},
+		{"linking enabled", true, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t, tc.link)
+			existing := models.User{Username: "alice-local", Password: "x", Email: "Alice@Example.com"}
+			if err := db.DB.Create(&existing).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			callback, binding := env.login(t)
+			result := env.callback(t, callback, binding)
+			if result.Get("error") != tc.wantError {
+				t.Fatalf("callback result = %v, want error %q", result, tc.wantError)
+			}
+			if tc.wantError != "" {
+				return
+			}
+
+			var identity models.UserIdentity
+			if err := db.DB.Where("provider = ?", "mock").First(&identity).Error; err != nil {
+				t.Fatal(err)
+			}
+			if identity.UserID != existing.ID {
+				t.Fatalf("identity linked to user %d, want existing user %d", identity.UserID, existing.ID)
+			}
+		})
+	}
+}
+
+func TestPKCERequired(t *testing.T) {
+	idp, err := newMockIdP("", "blog", "secret", map[string]interface{}{"sub": "mock-user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := url.Values{"client_id": {"blog"}, "redirect_uri": {testRedirectURL}, "response_type": {"code"}, "state": {"s"}}
+	req := httptest.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+	resp := httptest.NewRecorder()
+	idp.handler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("authorize without code_challenge returned %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/service"
+	"os"
+	"sort"
+
+	"keep_learning_blog/utils/logger"
+)
+
+// main 从 /api/export 导出的 JSON Lines 归档恢复全站数据到新数据库
+// 归档只包含媒体记录，恢复前需将原站的上传目录或存储桶复制到新环境
+//
+//	go run ./cmd/restore -file keep-learning-blog-20260101-120000.jsonl
+func main() {
+	file := flag.String("file", "", "导出归档文件，支持 gzip 压缩，- 表示标准输入")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 获取配置
+	cfg := config.GetConfig()
+
+	// 初始化日志
+	if err := logger.InitLogger(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 初始化数据库
+	if err := db.InitDB(cfg); err != nil {
+		fatalf("Failed to initialize database: %v", err)
+	}
+
+	// 初始化Redis，用于恢复后使订阅源等缓存失效
+	if err := db.InitRedis(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Redis unavailable, feed and sitemap caches will expire on their own: %v\n", err)
+		db.RedisClient = nil
+	}
+
+	var input io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fatalf("Failed to open archive: %v", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	// 根据魔数识别 gzip 压缩的归档
+	buffered := bufio.NewReader(input)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			fatalf("Failed to read gzip archive: %v", err)
+		}
+		defer gz.Close()
+		input = gz
+	} else {
+		input = buffered
+	}
+
+	backupService := service.BackupService{}
+	summary, err := backupService.RestoreSite(input)
+	if err != nil {
+		fatalf("Restore failed, nothing was written: %v", err)
+	}
+
+	types := make([]string, 0, len(summary.Counts))
+	for recordType := range summary.Counts {
+		types = append(types, recordType)
+	}
+	sort.Strings(types)
+
+	fmt.Fprintf(os.Stderr, "restored archive version %d\n", summary.Version)
+	for _, recordType := range types {
+		fmt.Fprintf(os.Stderr, "  %-16s %d\n", recordType, summary.Counts[recordType])
+	}
+	fmt.Fprintln(os.Stderr, "users restored without password hashes have random passwords and must reset them")
+}
+
+// fatalf 输出错误并退出
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
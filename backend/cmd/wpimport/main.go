@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
+	"math"
+	"os"
+
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/storage"
+)
+
+// main 从 WordPress WXR 导出文件导入用户、标签、文章、附件和评论
+//
+//	go run ./cmd/wpimport -file export.xml -uploads /path/to/wp-content/uploads
+func main() {
+	file := flag.String("file", "", "WordPress WXR 导出文件")
+	uploads := flag.String("uploads", "", "wp-content/uploads 目录，为空时不复制附件")
+	defaultUser := flag.String("default-user", "SuperAdmin", "作者缺少邮箱时文章归属的用户名")
+	reportFile := flag.String("report", "", "导入报告输出文件，为空时输出到标准输出")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 获取配置
+	cfg := config.GetConfig()
+
+	// 初始化日志
+	if err := logger.InitLogger(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to setup logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 初始化数据库
+	if err := db.InitDB(cfg); err != nil {
+		fatalf("Failed to initialize database: %v", err)
+	}
+
+	// 初始化Redis，用于导入后使订阅源等缓存失效
+	if err := db.InitRedis(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Redis unavailable, feed and sitemap caches will expire on their own: %v\n", err)
+		db.RedisClient = nil
+	}
+
+	// 初始化文件存储
+	backend, err := storage.New(&cfg.Storage)
+	if err != nil {
+		fatalf("Failed to initialize storage: %v", err)
+	}
+
+	var user models.User
+	if err := db.DB.Where("username = ?", *defaultUser).First(&user).Error; err != nil {
+		fatalf("Default user %q not found: %v", *defaultUser, err)
+	}
+
+	input, err := os.Open(*file)
+	if err != nil {
+		fatalf("Failed to open export file: %v", err)
+	}
+	defer input.Close()
+
+	// 导入不受用户存储配额限制，衍生图由服务端定期扫描生成
+	upload := cfg.Upload
+	upload.UserQuota = math.MaxInt64
+	mediaService := &service.MediaService{Storage: backend, Upload: &upload, Image: &cfg.Image}
+
+	importer := service.NewWordPressImporter(mediaService, *uploads, user.ID)
+	report, importErr := importer.Import(context.Background(), bufio.NewReader(input))
+
+	if err := writeReport(report, *reportFile); err != nil {
+		fatalf("Failed to write report: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "total: %d, created: %d, unchanged: %d, skipped: %d, failed: %d\n",
+		report.Total, report.Created, report.Unchanged, report.Skipped, report.Failed)
+
+	if importErr != nil {
+		fatalf("Import stopped: %v", importErr)
+	}
+}
+
+// writeReport 以 JSON 格式输出导入报告
+func writeReport(report *models.ImportReport, filename string) error {
+	out := os.Stdout
+	if filename != "" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// fatalf 输出错误并退出
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
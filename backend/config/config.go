@@ -1,15 +1,31 @@
 package config
 
 import (
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 // GetConfig 获取配置
 func GetConfig() *Config {
+	env := getEnvOrDefault("APP_ENV", EnvProduction)
+
+	// 邮件和验证链接密钥仅在开发环境有默认值，其他环境未设置时拒绝启动
+	mailDriver, verificationSecret := os.Getenv("MAIL_DRIVER"), os.Getenv("EMAIL_VERIFICATION_SECRET")
+	if env == EnvDevelopment {
+		if mailDriver == "" {
+			mailDriver = MailDriverLog
+		}
+		if verificationSecret == "" {
+			verificationSecret = "dev-email-verification-secret"
+		}
+	}
+
 	return &Config{
 		Server: ServerConfig{
+			Env:  env,    // 运行环境：development/production
 			Port: "8080", // 端口
 			TLS: TLSConfig{
 				Enable:   false, // 是否启用TLS
@@ -36,16 +52,33 @@ func GetConfig() *Config {
 			RBACCacheTTL: 30 * time.Minute,    // RBAC缓存过期时间
 		},
 		JWT: JWTConfig{
-			AccessTokenSecret:  getEnvOrDefault("JWT_ACCESS_SECRET", "your-access-secret-key"),   // 访问令牌密钥
-			RefreshTokenSecret: getEnvOrDefault("JWT_REFRESH_SECRET", "your-refresh-secret-key"), // 刷新令牌密钥
-			AccessTokenTTL:     15 * time.Minute,                                                 // 访问令牌15分钟过期
-			RefreshTokenTTL:    7 * 24 * time.Hour,                                               // 刷新令牌7天过期
+			SigningKeyFile:       os.Getenv("JWT_SIGNING_KEY_FILE"),                                                   // 签名私钥（PEM，RSA 或 Ed25519），仅开发环境可为空
+			TemporaryKey:         env == EnvDevelopment,                                                               // 未设置签名私钥时是否允许生成临时密钥
+			VerificationKeyFiles: envList("JWT_VERIFICATION_KEY_FILES"),                                               // 轮换期间仍接受的旧密钥（PEM 公钥或私钥），逗号分隔
+			Issuer:               getEnvOrDefault("JWT_ISSUER", getEnvOrDefault("SITE_URL", "http://localhost:8080")), // 令牌签发者
+			AccessTokenTTL:       15 * time.Minute,                                                                    // 访问令牌15分钟过期
+			RefreshTokenTTL:      7 * 24 * time.Hour,                                                                  // 刷新令牌7天过期
+			Mode:                 getEnvOrDefault("AUTH_MODE", AuthModeHeader),                                        // 令牌传递方式：header/cookie
+			Cookie: AuthCookieConfig{
+				AccessTokenName:  "access_token",                                                // 访问令牌 Cookie 名称
+				RefreshTokenName: "refresh_token",                                               // 刷新令牌 Cookie 名称，仅发送到刷新接口
+				CSRFTokenName:    "csrf_token",                                                  // CSRF 令牌 Cookie 名称，前端读取后放入 X-CSRF-Token 请求头
+				Domain:           os.Getenv("AUTH_COOKIE_DOMAIN"),                               // Cookie 域名，为空时仅限当前主机
+				Secure:           getEnvOrDefault("AUTH_COOKIE_SECURE", "true") == "true",       // 仅通过 HTTPS 发送
+				SameSite:         parseSameSite(getEnvOrDefault("AUTH_COOKIE_SAMESITE", "lax")), // 前后端不在同一站点时需设为 none
+			},
 		},
 		RateLimit: RateLimitConfig{
 			PublicAPILimit:  100,         // 100次/分钟
 			PrivateAPILimit: 60,          // 60次/分钟
 			AuthAPILimit:    5,           // 5次/分钟
 			Duration:        time.Minute, // 1分钟时间窗口
+
+			VerificationResendLimit:    3,         // 每小时最多重发3次验证邮件
+			VerificationResendDuration: time.Hour, // 重发验证邮件的时间窗口
+
+			PasswordResetEmailLimit:    3,         // 同一邮箱每小时最多发送3封重置邮件，与 AuthAPILimit 的按 IP 限流叠加
+			PasswordResetEmailDuration: time.Hour, // 重置邮件按邮箱限流的时间窗口
 		},
 		CORS: CORSConfig{
 			AllowOrigins: []string{
@@ -76,7 +109,7 @@ func GetConfig() *Config {
 			MaxAge:           86400, // 24小时
 		},
 		Security: SecurityConfig{
-			EncryptionKey: getEnvOrDefault("ENCRYPTION_KEY", "12345678901234567890123456789012"), // 加密密钥
+			EncryptionKey: getEnvOrDefault("ENCRYPTION_KEY", devEncryptionKey), // 加密密钥（AES-256，32 字节），仅开发环境可使用默认值
 		},
 		SystemLog: SystemLogConfig{
 			Level:         getEnvOrDefault("LOG_LEVEL", "info"),
@@ -87,6 +120,123 @@ func GetConfig() *Config {
 			MaxAge:        30,   // 保留30天
 			Compress:      true, // 压缩旧日志
 		},
+		Site: SiteConfig{
+			Title:           getEnvOrDefault("SITE_TITLE", "Keep Learning Blog"),  // 站点名称
+			Description:     getEnvOrDefault("SITE_DESCRIPTION", "持续学习，记录成长"),     // 站点描述
+			URL:             getEnvOrDefault("SITE_URL", "http://localhost:8080"), // 站点地址，用于生成订阅源及站点地图中的绝对链接
+			Language:        "zh-CN",                                              // 站点语言
+			FeedSize:        20,                                                   // 订阅源包含的文章数
+			CacheTTL:        time.Hour,                                            // 订阅源及站点地图缓存时间，内容变更时立即失效
+			SitemapURLLimit: 50000,                                                // 单个站点地图文件的 URL 上限，超过后生成站点地图索引
+			Robots: RobotsConfig{
+				DisallowAll: getEnvOrDefault("ROBOTS_DISALLOW_ALL", "false") == "true", // 禁止所有爬虫，用于测试环境
+				Allow:       envListOrDefault("ROBOTS_ALLOW", []string{}),              // 允许抓取的路径，逗号分隔
+				Disallow:    envListOrDefault("ROBOTS_DISALLOW", []string{"/api/"}),    // 禁止抓取的路径，逗号分隔，设为空值时不禁止任何路径
+			},
+		},
+		Scheduler: SchedulerConfig{
+			Interval: 30 * time.Second,               // 每30秒检查一次到期的定时发布文章
+			LockKey:  "scheduler:publish_posts:lock", // 多实例部署时的分布式锁
+			LockTTL:  25 * time.Second,               // 锁过期时间，需小于检查间隔
+		},
+		Moderation: ModerationConfig{
+			Mode: getEnvOrDefault("COMMENT_MODERATION_MODE", ModerationModeOff), // 评论审核模式：off/first/all
+		},
+		Spam: SpamConfig{
+			Enabled:                  getEnvOrDefault("SPAM_FILTER_ENABLED", "true") == "true", // 是否启用垃圾内容过滤
+			MaxLinks:                 5,                                                        // 链接数超过该值判定为垃圾内容
+			SuspiciousLinks:          2,                                                        // 链接数达到该值进入人工审核
+			BlockedKeywords:          []string{"viagra", "casino", "博彩", "代开发票"},               // 命中即拒绝的关键词
+			SuspiciousKeywords:       []string{"crypto", "loan", "贷款", "加微信"},                  // 命中后进入人工审核的关键词
+			BayesSpamThreshold:       0.99,                                                     // 贝叶斯垃圾概率达到该值判定为垃圾内容
+			BayesSuspiciousThreshold: 0.8,                                                      // 贝叶斯垃圾概率达到该值进入人工审核
+			BayesMinDocuments:        20,                                                       // 垃圾和正常样本均达到该数量后才启用贝叶斯判定
+		},
+		Storage: StorageConfig{
+			Backend: getEnvOrDefault("STORAGE_BACKEND", StorageBackendLocal), // 存储后端：local/s3
+			Local: LocalStorageConfig{
+				Root:    getEnvOrDefault("STORAGE_LOCAL_ROOT", "uploads"), // 本地存储根目录
+				BaseURL: "/uploads",                                       // 本地文件访问路径
+			},
+			S3: S3StorageConfig{
+				Endpoint:  getEnvOrDefault("S3_ENDPOINT", "localhost:9000"), // S3 兼容服务地址（如本地 MinIO）
+				AccessKey: getEnvOrDefault("S3_ACCESS_KEY", "minioadmin"),   // 访问密钥
+				SecretKey: getEnvOrDefault("S3_SECRET_KEY", "minioadmin"),   // 私有密钥
+				Bucket:    getEnvOrDefault("S3_BUCKET", "blog-media"),       // 存储桶
+				Region:    getEnvOrDefault("S3_REGION", ""),                 // 区域
+				UseSSL:    getEnvOrDefault("S3_USE_SSL", "false") == "true", // 是否使用 HTTPS
+				PublicURL: getEnvOrDefault("S3_PUBLIC_URL", ""),             // 公开访问地址，为空时使用 endpoint/bucket
+			},
+		},
+		Upload: UploadConfig{
+			MaxFileSize: 10 << 20,  // 单个文件最大10MB
+			UserQuota:   200 << 20, // 每个用户最多200MB，包括原图及生成的衍生图
+			AllowedTypes: []string{ // 允许上传的类型（按文件内容识别）
+				"image/jpeg",
+				"image/png",
+				"image/gif",
+				"image/webp",
+				"application/pdf",
+			},
+		},
+		Image: ImageConfig{
+			Workers:       2,           // 并发处理图片的协程数
+			QueueSize:     100,         // 待处理队列长度，队列满时由定期扫描补处理
+			SweepInterval: time.Minute, // 扫描未处理图片的间隔
+			MaxPixels:     50_000_000,  // 允许上传的最大像素数，防止解压炸弹
+			JPEGQuality:   82,          // JPEG 衍生图质量
+			WebPQuality:   80,          // WebP 衍生图质量
+			Variants: []ImageVariant{ // 衍生图尺寸，原图较小时不放大
+				{Name: "thumbnail", Width: 320},
+				{Name: "medium", Width: 800},
+				{Name: "large", Width: 1600},
+			},
+		},
+		Import: ImportConfig{
+			MaxArchiveSize: 100 << 20, // 导入压缩包最大100MB
+			MaxFileSize:    5 << 20,   // 压缩包内单个文件解压后最大5MB
+			MaxFiles:       10000,     // 压缩包内最多处理的文件数
+		},
+		Mail: MailConfig{
+			Driver:   mailDriver,                                                               // 邮件发送方式：log/file/smtp，开发环境默认 log
+			From:     getEnvOrDefault("MAIL_FROM", "Keep Learning Blog <noreply@example.com>"), // 发件人
+			FilePath: getEnvOrDefault("MAIL_FILE_PATH", "logs/mail.log"),                       // file 方式下邮件写入的文件
+			SMTP: SMTPConfig{
+				Host:     getEnvOrDefault("SMTP_HOST", "localhost"), // SMTP 服务器地址
+				Port:     getEnvOrDefault("SMTP_PORT", "587"),       // SMTP 端口，服务器支持时自动启用 STARTTLS
+				Username: getEnvOrDefault("SMTP_USERNAME", ""),      // 用户名，为空时不认证
+				Password: getEnvOrDefault("SMTP_PASSWORD", ""),      // 密码
+			},
+		},
+		EmailVerification: EmailVerificationConfig{
+			Secret: verificationSecret, // 验证链接签名密钥
+			TTL:    24 * time.Hour,     // 验证链接24小时过期
+		},
+		PasswordReset: PasswordResetConfig{
+			URL: getEnvOrDefault("PASSWORD_RESET_URL", "http://localhost:8080/reset-password"), // 前端重置密码页面，令牌以 token 参数附加
+			TTL: 30 * time.Minute,                                                              // 重置令牌30分钟过期
+		},
+		MFA: MFAConfig{
+			Issuer:        getEnvOrDefault("MFA_ISSUER", "Keep Learning Blog"), // 验证器应用中显示的发行方
+			ChallengeTTL:  5 * time.Minute,                                     // 登录时两步验证令牌5分钟过期
+			EnrollmentTTL: 10 * time.Minute,                                    // 未确认的绑定密钥10分钟过期
+			MaxAttempts:   5,                                                   // 每个两步验证令牌最多尝试5次验证码
+			Skew:          1,                                                   // 允许前后1个时间步（30秒）的时钟偏差
+			RecoveryCodes: 10,                                                  // 每次生成的恢复码数量
+		},
+		PersonalAccessToken: PersonalAccessTokenConfig{
+			MaxPerUser: 20, // 每个用户最多20个个人访问令牌
+			MaxTTL:     0,  // 过期时间上限，为0时允许永不过期的令牌
+		},
+		OIDC: OIDCConfig{
+			StateTTL:     10 * time.Minute, // 授权请求的 state 10分钟过期
+			LoginCodeTTL: time.Minute,      // 回调签发的一次性登录兑换码1分钟过期
+			// 回调完成后跳转的前端页面，成功时附加 code 参数（登录兑换码），失败时附加 error 参数
+			FrontendURL: getEnvOrDefault("OIDC_FRONTEND_URL", getEnvOrDefault("SITE_URL", "http://localhost:8080")+"/login/oidc"),
+			// 身份提供方确认邮箱已验证时关联邮箱相同的本地用户，默认关闭，每次关联均记录审计日志
+			LinkVerifiedEmail: os.Getenv("OIDC_LINK_VERIFIED_EMAIL") == "true",
+			Providers:         oidcProvidersFromEnv(),
+		},
 		AuditLog: AuditLogConfig{
 			Filename:   getEnvOrDefault("AUDIT_LOG_FILE_PATH", "logs/audit.log"),
 			MaxSize:    100,
@@ -99,23 +249,50 @@ func GetConfig() *Config {
 
 // Config
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	Redis     RedisConfig
-	JWT       JWTConfig
-	RateLimit RateLimitConfig
-	CORS      CORSConfig
-	Security  SecurityConfig
-	SystemLog SystemLogConfig
-	AuditLog  AuditLogConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	JWT        JWTConfig
+	RateLimit  RateLimitConfig
+	CORS       CORSConfig
+	Security   SecurityConfig
+	SystemLog  SystemLogConfig
+	AuditLog   AuditLogConfig
+	Scheduler  SchedulerConfig
+	Site       SiteConfig
+	Moderation ModerationConfig
+	Spam       SpamConfig
+	Storage    StorageConfig
+	Upload     UploadConfig
+	Image      ImageConfig
+	Import     ImportConfig
+	Mail       MailConfig
+
+	EmailVerification   EmailVerificationConfig
+	PasswordReset       PasswordResetConfig
+	MFA                 MFAConfig
+	OIDC                OIDCConfig
+	PersonalAccessToken PersonalAccessTokenConfig
 }
 
+// 运行环境
+const (
+	EnvDevelopment = "development" // 开发环境，允许使用不安全的默认配置
+	EnvProduction  = "production"  // 生产环境
+)
+
 // ServerConfig 服务器配置
 type ServerConfig struct {
+	Env  string
 	Port string
 	TLS  TLSConfig
 }
 
+// IsDevelopment 是否为开发环境
+func (c *ServerConfig) IsDevelopment() bool {
+	return c.Env == EnvDevelopment
+}
+
 type TLSConfig struct {
 	Enable   bool
 	CertFile string
@@ -151,14 +328,42 @@ type RateLimitConfig struct {
 	PrivateAPILimit int
 	AuthAPILimit    int
 	Duration        time.Duration
+
+	VerificationResendLimit    int
+	VerificationResendDuration time.Duration
+
+	PasswordResetEmailLimit    int
+	PasswordResetEmailDuration time.Duration
 }
 
 // JWTConfig JWT配置
+// 令牌使用非对称密钥签名（RS256 或 EdDSA），头部携带 kid；轮换密钥时将旧密钥加入 VerificationKeyFiles，
+// 旧密钥签发的令牌在过期前仍然有效，公钥通过 /.well-known/jwks.json 公布
 type JWTConfig struct {
-	AccessTokenSecret  string
-	RefreshTokenSecret string
-	AccessTokenTTL     time.Duration
-	RefreshTokenTTL    time.Duration
+	SigningKeyFile       string
+	TemporaryKey         bool
+	VerificationKeyFiles []string
+	Issuer               string
+	AccessTokenTTL       time.Duration
+	RefreshTokenTTL      time.Duration
+	Mode                 string
+	Cookie               AuthCookieConfig
+}
+
+// 令牌传递方式
+const (
+	AuthModeHeader = "header" // 令牌在响应体中返回，前端通过 Authorization 和 Refresh-Token 请求头传递
+	AuthModeCookie = "cookie" // 令牌写入 HttpOnly Cookie，非安全方法的请求需携带 X-CSRF-Token 请求头
+)
+
+// AuthCookieConfig Cookie 模式下的 Cookie 配置
+type AuthCookieConfig struct {
+	AccessTokenName  string
+	RefreshTokenName string
+	CSRFTokenName    string
+	Domain           string
+	Secure           bool
+	SameSite         http.SameSite
 }
 
 // CORSConfig CORS 配置
@@ -176,6 +381,9 @@ type SecurityConfig struct {
 	EncryptionKey string
 }
 
+// devEncryptionKey 开发环境的默认加密密钥，公开在源码中，其他环境拒绝使用
+const devEncryptionKey = "12345678901234567890123456789012"
+
 // LogConfig 日志配置
 type SystemLogConfig struct {
 	Level         string
@@ -196,6 +404,276 @@ type AuditLogConfig struct {
 	Compress   bool
 }
 
+// SiteConfig 站点配置
+type SiteConfig struct {
+	Title           string
+	Description     string
+	URL             string
+	Language        string
+	FeedSize        int
+	CacheTTL        time.Duration
+	SitemapURLLimit int
+	Robots          RobotsConfig
+}
+
+// RobotsConfig robots.txt 配置
+type RobotsConfig struct {
+	DisallowAll bool
+	Allow       []string
+	Disallow    []string
+}
+
+// SchedulerConfig 定时任务配置
+type SchedulerConfig struct {
+	Interval time.Duration
+	LockKey  string
+	LockTTL  time.Duration
+}
+
+// 评论审核模式
+const (
+	ModerationModeOff   = "off"   // 不审核，评论直接发布
+	ModerationModeFirst = "first" // 仅审核用户的首条评论
+	ModerationModeAll   = "all"   // 审核所有评论
+)
+
+// ModerationConfig 评论审核配置
+type ModerationConfig struct {
+	Mode string
+}
+
+// SpamConfig 垃圾内容过滤配置
+type SpamConfig struct {
+	Enabled                  bool
+	MaxLinks                 int
+	SuspiciousLinks          int
+	BlockedKeywords          []string
+	SuspiciousKeywords       []string
+	BayesSpamThreshold       float64
+	BayesSuspiciousThreshold float64
+	BayesMinDocuments        int64
+}
+
+// 存储后端
+const (
+	StorageBackendLocal = "local" // 本地文件系统
+	StorageBackendS3    = "s3"    // S3 兼容对象存储
+)
+
+// StorageConfig 文件存储配置
+type StorageConfig struct {
+	Backend string
+	Local   LocalStorageConfig
+	S3      S3StorageConfig
+}
+
+// LocalStorageConfig 本地文件存储配置
+type LocalStorageConfig struct {
+	Root    string
+	BaseURL string
+}
+
+// S3StorageConfig S3 兼容对象存储配置
+type S3StorageConfig struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+	PublicURL string
+}
+
+// UploadConfig 文件上传配置
+type UploadConfig struct {
+	MaxFileSize  int64
+	UserQuota    int64
+	AllowedTypes []string
+}
+
+// ImageConfig 图片衍生图配置
+type ImageConfig struct {
+	Workers       int
+	QueueSize     int
+	SweepInterval time.Duration
+	MaxPixels     int
+	JPEGQuality   int
+	WebPQuality   int
+	Variants      []ImageVariant
+}
+
+// ImageVariant 衍生图尺寸
+type ImageVariant struct {
+	Name  string
+	Width int
+}
+
+// ImportConfig 内容导入配置
+type ImportConfig struct {
+	MaxArchiveSize int64
+	MaxFileSize    int64
+	MaxFiles       int
+}
+
+// 邮件发送方式
+const (
+	MailDriverLog  = "log"  // 写入系统日志，用于开发环境
+	MailDriverFile = "file" // 追加写入文件，用于开发环境
+	MailDriverSMTP = "smtp" // 通过 SMTP 服务器发送
+)
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	Driver   string
+	From     string
+	FilePath string
+	SMTP     SMTPConfig
+}
+
+// SMTPConfig SMTP 服务器配置
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+// EmailVerificationConfig 邮箱验证配置
+type EmailVerificationConfig struct {
+	Secret string
+	TTL    time.Duration
+}
+
+// PasswordResetConfig 找回密码配置
+type PasswordResetConfig struct {
+	URL string
+	TTL time.Duration
+}
+
+// MFAConfig 两步验证配置
+type MFAConfig struct {
+	Issuer        string
+	ChallengeTTL  time.Duration
+	EnrollmentTTL time.Duration
+	MaxAttempts   int
+	Skew          int
+	RecoveryCodes int
+}
+
+// PersonalAccessTokenConfig 个人访问令牌配置
+type PersonalAccessTokenConfig struct {
+	MaxPerUser int
+	MaxTTL     time.Duration
+}
+
+// OIDCConfig OpenID Connect 登录配置
+type OIDCConfig struct {
+	StateTTL          time.Duration
+	LoginCodeTTL      time.Duration
+	FrontendURL       string
+	LinkVerifiedEmail bool
+	Providers         []OIDCProviderConfig
+}
+
+// OIDCProviderConfig OpenID Connect 身份提供方配置
+type OIDCProviderConfig struct {
+	Name         string // 提供方标识，用于路由及关联的外部身份
+	Issuer       string // 签发者地址，通过 /.well-known/openid-configuration 发现端点
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // 授权回调地址，需在身份提供方登记
+	Scopes       []string
+}
+
+// oidcProvidersFromEnv 根据环境变量配置企业身份提供方，未设置 OIDC_ISSUER 时不启用
+func oidcProvidersFromEnv() []OIDCProviderConfig {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+
+	name := getEnvOrDefault("OIDC_PROVIDER_NAME", "corporate")
+	siteURL := getEnvOrDefault("SITE_URL", "http://localhost:8080")
+	return []OIDCProviderConfig{{
+		Name:         name,
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  getEnvOrDefault("OIDC_REDIRECT_URL", siteURL+"/api/auth/oidc/"+name+"/callback"),
+		Scopes:       []string{"openid", "profile", "email"},
+	}}
+}
+
+// Validate 检查配置，存在无效或不安全的配置时拒绝启动
+func (c *Config) Validate() error {
+	switch c.Server.Env {
+	case EnvDevelopment, EnvProduction:
+	default:
+		return fmt.Errorf("invalid APP_ENV %q, must be development or production", c.Server.Env)
+	}
+	switch c.Moderation.Mode {
+	case ModerationModeOff, ModerationModeFirst, ModerationModeAll:
+	default:
+		return fmt.Errorf("invalid COMMENT_MODERATION_MODE %q, must be off, first or all", c.Moderation.Mode)
+	}
+	switch c.JWT.Mode {
+	case AuthModeHeader, AuthModeCookie:
+	default:
+		return fmt.Errorf("invalid AUTH_MODE %q, must be header or cookie", c.JWT.Mode)
+	}
+	if c.Mail.Driver == "" {
+		return fmt.Errorf("MAIL_DRIVER must be set when APP_ENV is %q", c.Server.Env)
+	}
+	if c.JWT.SigningKeyFile == "" && !c.JWT.TemporaryKey {
+		return fmt.Errorf("JWT_SIGNING_KEY_FILE must be set when APP_ENV is %q", c.Server.Env)
+	}
+	if c.EmailVerification.Secret == "" {
+		return fmt.Errorf("EMAIL_VERIFICATION_SECRET must be set when APP_ENV is %q", c.Server.Env)
+	}
+	if len(c.Security.EncryptionKey) != 32 {
+		return fmt.Errorf("ENCRYPTION_KEY must be 32 bytes, got %d", len(c.Security.EncryptionKey))
+	}
+	if c.Security.EncryptionKey == devEncryptionKey && !c.Server.IsDevelopment() {
+		return fmt.Errorf("ENCRYPTION_KEY must be set when APP_ENV is %q", c.Server.Env)
+	}
+	return nil
+}
+
+// parseSameSite 解析 Cookie 的 SameSite 属性，无法识别时使用 Lax
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// envList 获取逗号分隔的环境变量列表，忽略空项
+func envList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envListOrDefault 获取逗号分隔的环境变量列表，未设置时使用默认值，设置为空值时返回空列表
+func envListOrDefault(key string, defaultValue []string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return defaultValue
+	}
+	values := envList(key)
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
 // 获取环境变量，如果没有则使用默认值。
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// productionConfig 返回可通过校验的生产环境配置
+func productionConfig() *Config {
+	c := GetConfig()
+	c.Server.Env = EnvProduction
+	c.Mail.Driver = MailDriverSMTP
+	c.JWT.SigningKeyFile = "/etc/blog/jwt.pem"
+	c.JWT.TemporaryKey = false
+	c.EmailVerification.Secret = "verification-secret"
+	c.Security.EncryptionKey = strings.Repeat("k", 32)
+	return c
+}
+
+// TestValidateEncryptionKey 加密密钥必须为 32 字节，开发环境以外不能使用默认密钥
+func TestValidateEncryptionKey(t *testing.T) {
+	cases := []struct {
+		name    string
+		env     string
+		key     string
+		wantErr string
+	}{
+		{"random key", EnvProduction, strings.Repeat("k", 32), ""},
+		{"default key in production", EnvProduction, devEncryptionKey, "ENCRYPTION_KEY must be set"},
+		{"default key in development", EnvDevelopment, devEncryptionKey, ""},
+		{"short key", EnvProduction, "short", "ENCRYPTION_KEY must be 32 bytes"},
+		{"long key", EnvProduction, strings.Repeat("k", 33), "ENCRYPTION_KEY must be 32 bytes"},
+		{"short key in development", EnvDevelopment, "short", "ENCRYPTION_KEY must be 32 bytes"},
+		{"empty key", EnvProduction, "", "ENCRYPTION_KEY must be 32 bytes"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := productionConfig()
+			c.Server.Env = tc.env
+			c.Security.EncryptionKey = tc.key
+
+			err := c.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestValidateAuthMode 令牌传递方式只能为 header 或 cookie
+func TestValidateAuthMode(t *testing.T) {
+	cases := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{AuthModeHeader, false},
+		{AuthModeCookie, false},
+		{"", true},
+		{"Cookie", true},
+		{"session", true},
+	}
+
+	for _, tc := range cases {
+		c := productionConfig()
+		c.JWT.Mode = tc.mode
+
+		err := c.Validate()
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("Validate() with AUTH_MODE %q = %v, want error %v", tc.mode, err, tc.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "invalid AUTH_MODE") {
+			t.Fatalf("Validate() with AUTH_MODE %q = %v, want invalid AUTH_MODE", tc.mode, err)
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"keep_learning_blog/config"
 	"keep_learning_blog/models"
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/markdown"
+	"keep_learning_blog/utils/slug"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
@@ -32,16 +35,65 @@ func InitDB(cfg *config.Config) error {
 		return err
 	}
 
+	// 邮箱验证字段上线前注册的用户视为已验证
+	backfillEmailVerified := !db.Migrator().HasColumn(&models.User{}, "email_verified_at")
+
 	// 自动迁移数据库结构
 	err = db.AutoMigrate(
 		&models.Permission{}, &models.Role{}, &models.User{},
 		&models.Post{}, &models.Tag{}, &models.Comment{},
+		&models.PostRevision{}, &models.PostSlugHistory{}, &models.CommentModeration{},
+		&models.SpamToken{}, &models.SpamClassStat{}, &models.Media{}, &models.MediaDerivative{},
+		&models.RecoveryCode{}, &models.UserIdentity{}, &models.PersonalAccessToken{},
 	)
 	if err != nil {
 		logger.Log.WithError(err).Error("Failed to migrate database")
 		return err
 	}
 
+	if backfillEmailVerified {
+		if err := db.Model(&models.User{}).Where("email_verified_at IS NULL").
+			UpdateColumn("email_verified_at", gorm.Expr("created_at")).Error; err != nil {
+			logger.Log.WithError(err).Error("Failed to backfill user email_verified_at")
+			return err
+		}
+	}
+
+	// 创建全文搜索相关的生成列和索引
+	if err := migrateSearchIndexes(db); err != nil {
+		logger.Log.WithError(err).Error("Failed to migrate search indexes")
+		return err
+	}
+
+	// 为已发布但缺少发布时间的历史文章补齐发布时间
+	if err := db.Model(&models.Post{}).
+		Where("status = ? AND published_at IS NULL", models.PostStatusPublished).
+		Update("published_at", gorm.Expr("created_at")).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to backfill post published_at")
+		return err
+	}
+
+	// 为历史文章生成 slug
+	if err := backfillPostSlugs(db); err != nil {
+		logger.Log.WithError(err).Error("Failed to backfill post slugs")
+		return err
+	}
+
+	// 为历史图片生成衍生图
+	if err := db.Model(&models.Media{}).
+		Where("(derivative_status IS NULL OR derivative_status = '') AND mime_type IN ?",
+			[]string{"image/jpeg", "image/png", "image/gif", "image/webp"}).
+		Update("derivative_status", models.DerivativeStatusPending).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to queue image derivatives")
+		return err
+	}
+
+	// 为历史文章和评论渲染 Markdown
+	if err := backfillRenderedContent(db); err != nil {
+		logger.Log.WithError(err).Error("Failed to backfill rendered content")
+		return err
+	}
+
 	// 初始化基础数据
 	if err := InitBaseData(db); err != nil {
 		logger.Log.WithError(err).Error("Failed to initialize base data")
@@ -54,6 +106,104 @@ func InitDB(cfg *config.Config) error {
 	return nil
 }
 
+// migrateSearchIndexes 创建文章全文搜索所需的 tsvector 生成列、GIN 索引及 trigram 索引
+// tsvector 使用 simple 配置以兼容中英文混合内容，中文检索通过 pg_trgm 索引加速的 ILIKE 兜底
+func migrateSearchIndexes(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+			) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_title_trgm ON posts USING GIN (title gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_content_trgm ON posts USING GIN (content gin_trgm_ops)`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillRenderedContent 为尚未渲染的历史文章和评论生成 content_html
+func backfillRenderedContent(db *gorm.DB) error {
+	var posts []models.Post
+	err := db.Select("id", "content").Where("content_html IS NULL OR content_html = ''").
+		FindInBatches(&posts, 100, func(tx *gorm.DB, batch int) error {
+			for _, post := range posts {
+				contentHTML, err := markdown.Render(post.Content)
+				if err != nil {
+					return err
+				}
+				if err := db.Model(&models.Post{}).Where("id = ?", post.ID).
+					UpdateColumn("content_html", contentHTML).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	var comments []models.Comment
+	return db.Select("id", "content").Where("content_html IS NULL OR content_html = ''").
+		FindInBatches(&comments, 100, func(tx *gorm.DB, batch int) error {
+			for _, comment := range comments {
+				contentHTML, err := markdown.Render(comment.Content)
+				if err != nil {
+					return err
+				}
+				if err := db.Model(&models.Comment{}).Where("id = ?", comment.ID).
+					UpdateColumn("content_html", contentHTML).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// backfillPostSlugs 为没有 slug 的历史文章生成 slug
+func backfillPostSlugs(db *gorm.DB) error {
+	var posts []models.Post
+	if err := db.Select("id", "title").Where("slug IS NULL OR slug = ''").Order("id").Find(&posts).Error; err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		postSlug, err := slug.Unique(slug.Make(post.Title), PostSlugTaken(db, post.ID))
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("slug", postSlug).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PostSlugTaken 返回检查 slug 是否已被其他文章占用（包括其他文章的历史 slug）的函数
+func PostSlugTaken(tx *gorm.DB, postID uint) func(string) (bool, error) {
+	return func(candidate string) (bool, error) {
+		var count int64
+		if err := tx.Model(&models.Post{}).Where("slug = ? AND id <> ?", candidate, postID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+
+		if err := tx.Model(&models.PostSlugHistory{}).Where("slug = ? AND post_id <> ?", candidate, postID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		return count > 0, nil
+	}
+}
+
 // InitBaseData 初始化基础数据
 func InitBaseData(db *gorm.DB) error {
 	// 初始化权限
@@ -102,16 +252,52 @@ func InitBaseData(db *gorm.DB) error {
 		// 文章管理权限
 		{Name: "创建文章", Code: "post:create", Method: "POST", Path: "/post", Description: "创建新文章", IsDefault: true},
 
+		{Name: "预览文章内容", Code: "post:preview", Method: "POST", Path: "/post/preview", Description: "预览 Markdown 渲染结果", IsDefault: true},
+
 		{Name: "编辑指定文章", Code: "post:edit", Method: "PUT", Path: "/post/:id", Description: "编辑指定文章信息", IsDefault: true},
 
+		{Name: "发布指定文章", Code: "post:publish", Method: "PUT", Path: "/post/:id/publish", Description: "发布指定草稿或归档文章", IsDefault: true},
+		{Name: "撤回指定文章", Code: "post:unpublish", Method: "PUT", Path: "/post/:id/unpublish", Description: "将已发布文章撤回为草稿", IsDefault: true},
+		{Name: "归档指定文章", Code: "post:archive", Method: "PUT", Path: "/post/:id/archive", Description: "归档指定文章", IsDefault: true},
+
+		{Name: "查看指定文章修订版本", Code: "post:select:revisions", Method: "GET", Path: "/post/:id/revisions", Description: "查看指定文章的修订历史", IsDefault: true},
+		{Name: "比较指定文章修订版本", Code: "post:diff:revisions", Method: "GET", Path: "/post/:id/revisions/diff", Description: "比较指定文章两个修订版本的差异", IsDefault: true},
+		{Name: "恢复指定文章修订版本", Code: "post:restore:revision", Method: "POST", Path: "/post/:id/revisions/:version/restore", Description: "将指定文章恢复为某个修订版本", IsDefault: true},
+
 		{Name: "删除指定文章", Code: "post:delete", Method: "DELETE", Path: "/post/:id", Description: "删除指定文章", IsDefault: true},
 
+		{Name: "查看所有草稿文章", Code: "posts:select:drafts", Method: "GET", Path: "/posts", Description: "查看所有用户的草稿及归档文章"},
+
+		{Name: "导入文章", Code: "posts:import", Method: "POST", Path: "/posts/import", Description: "从 Hugo/Jekyll Markdown 压缩包导入文章"},
+
 		// 评论管理权限
 		{Name: "创建评论", Code: "comment:create", Method: "POST", Path: "/comment", Description: "创建新评论", IsDefault: true},
 
 		{Name: "编辑指定评论", Code: "comment:edit", Method: "PUT", Path: "/comment/:id", Description: "编辑指定评论信息", IsDefault: true},
 
 		{Name: "删除指定评论", Code: "comment:delete", Method: "DELETE", Path: "/comment/:id", Description: "删除指定评论", IsDefault: true},
+
+		{Name: "通过指定评论", Code: "comment:approve", Method: "PUT", Path: "/comment/:id/approve", Description: "审核通过指定评论"},
+
+		{Name: "拒绝指定评论", Code: "comment:reject", Method: "PUT", Path: "/comment/:id/reject", Description: "审核拒绝指定评论"},
+
+		{Name: "查看指定评论审核记录", Code: "comment:select:moderations", Method: "GET", Path: "/comment/:id/moderations", Description: "查看指定评论的审核记录"},
+
+		{Name: "查看待审核评论", Code: "comments:select:pending", Method: "GET", Path: "/comments/pending", Description: "查看评论审核队列"},
+
+		{Name: "批量通过评论", Code: "comments:approve", Method: "POST", Path: "/comments/approve", Description: "批量审核通过评论"},
+
+		// 媒体文件权限
+		{Name: "上传媒体文件", Code: "media:create", Method: "POST", Path: "/media", Description: "上传图片等媒体文件", IsDefault: true},
+
+		{Name: "查看媒体文件", Code: "media:select", Method: "GET", Path: "/media", Description: "查看当前用户上传的媒体文件", IsDefault: true},
+
+		{Name: "关联媒体文件", Code: "media:attach", Method: "PUT", Path: "/media/:id", Description: "将媒体文件关联到文章", IsDefault: true},
+
+		{Name: "删除媒体文件", Code: "media:delete", Method: "DELETE", Path: "/media/:id", Description: "删除当前用户上传的媒体文件", IsDefault: true},
+
+		// 站点管理权限
+		{Name: "导出全站数据", Code: "site:export", Method: "GET", Path: "/export", Description: "以 JSON Lines 归档导出全站数据"},
 	}
 
 	// 使用FirstOrCreate避免重复创建
@@ -131,6 +317,7 @@ func InitBaseData(db *gorm.DB) error {
 			Name:        "超级管理员",
 			Code:        "SUPER_ADMIN",
 			Description: "系统超级管理员，拥有所有权限",
+			RequireMFA:  true,
 		},
 		{
 			Name:        "内容管理员",
@@ -184,8 +371,8 @@ func InitBaseData(db *gorm.DB) error {
 
 	// 获取内容管理员权限
 	var contentPermissions []models.Permission
-	if err := db.Where("code LIKE ? OR code LIKE ? OR code LIKE ?",
-		"post:%", "comment:%", "tag:%").
+	if err := db.Where("code LIKE ? OR code LIKE ? OR code LIKE ? OR code LIKE ? OR code LIKE ?",
+		"post:%", "posts:%", "comment:%", "comments:%", "tag:%").
 		Find(&contentPermissions).Error; err != nil {
 		return err
 	}
@@ -220,10 +407,12 @@ func InitBaseData(db *gorm.DB) error {
 	}
 
 	// 创建默认管理员用户
+	verifiedAt := time.Now()
 	superAdminUser := models.User{
-		Username: "SuperAdmin",
-		Password: string(passwordHash),
-		Email:    "SuperAdmin@example.com",
+		Username:        "SuperAdmin",
+		Password:        string(passwordHash),
+		Email:           "SuperAdmin@example.com",
+		EmailVerifiedAt: &verifiedAt,
 	}
 
 	// 使用FirstOrCreate避免重复创建
@@ -7,6 +7,7 @@ import (
 	"keep_learning_blog/config"
 	"keep_learning_blog/models"
 	"keep_learning_blog/utils/logger"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -208,3 +209,421 @@ func RecordLoginAttempt(ctx context.Context, identifier string, success bool, ma
 
 	return nil
 }
+
+// releaseLockScript 仅当锁仍由当前持有者持有时才释放，避免误删其他实例的锁
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock 获取分布式锁，owner 用于标识锁的持有者
+func AcquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	ok, err := RedisClient.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"key":   key,
+			"error": err,
+		})).Error("Failed to acquire lock")
+		return false, err
+	}
+	return ok, nil
+}
+
+// ReleaseLock 释放分布式锁
+func ReleaseLock(ctx context.Context, key, owner string) error {
+	return releaseLockScript.Run(ctx, RedisClient, []string{key}, owner).Err()
+}
+
+// contentVersionKey 内容版本号，文章变更时递增，使订阅源等派生内容的缓存全部失效
+const contentVersionKey = "cache:content_version"
+
+// GetContentVersion 获取当前内容版本号
+func GetContentVersion(ctx context.Context) (int64, error) {
+	version, err := RedisClient.Get(ctx, contentVersionKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// BumpContentVersion 递增内容版本号
+func BumpContentVersion(ctx context.Context) error {
+	return RedisClient.Incr(ctx, contentVersionKey).Err()
+}
+
+// GetCache 获取缓存，未命中时返回 nil
+func GetCache(ctx context.Context, key string) ([]byte, error) {
+	data, err := RedisClient.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SetCache 设置缓存
+func SetCache(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return RedisClient.Set(ctx, key, data, ttl).Err()
+}
+
+// 密码重置令牌及用户令牌版本相关的前缀
+const (
+	PasswordResetPrefix      = "password_reset:"
+	PasswordResetEmailPrefix = "password_reset_email:"
+	TokenVersionPrefix       = "token_version:"
+)
+
+// AllowPasswordResetEmail 按邮箱限制重置邮件的发送次数，emailHash 为邮箱的哈希值
+// 窗口内的第 limit 次之后返回 false
+func AllowPasswordResetEmail(ctx context.Context, emailHash string, limit int, window time.Duration) (bool, error) {
+	key := PasswordResetEmailPrefix + emailHash
+	count, err := RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		if err := RedisClient.Expire(ctx, key, window).Err(); err != nil {
+			return false, err
+		}
+	}
+	return count <= int64(limit), nil
+}
+
+// SetPasswordResetToken 保存密码重置令牌，tokenHash 为令牌的哈希值
+func SetPasswordResetToken(ctx context.Context, tokenHash, value string, ttl time.Duration) error {
+	return RedisClient.Set(ctx, PasswordResetPrefix+tokenHash, value, ttl).Err()
+}
+
+// TakePasswordResetToken 读取并删除密码重置令牌，保证令牌只能使用一次，不存在时返回空字符串
+func TakePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	value, err := RedisClient.GetDel(ctx, PasswordResetPrefix+tokenHash).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return value, err
+}
+
+// GetTokenVersion 获取用户当前的令牌版本号，签发的令牌携带该版本号，版本不一致的令牌视为失效
+func GetTokenVersion(ctx context.Context, userID uint) (int64, error) {
+	version, err := RedisClient.Get(ctx, fmt.Sprintf("%s%d", TokenVersionPrefix, userID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return version, err
+}
+
+// IncrTokenVersion 递增用户令牌版本号，使该用户已签发的所有令牌失效
+func IncrTokenVersion(ctx context.Context, userID uint) error {
+	if err := RedisClient.Incr(ctx, fmt.Sprintf("%s%d", TokenVersionPrefix, userID)).Err(); err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"user_id": userID,
+			"error":   err,
+		})).Error("Failed to increase token version")
+		return err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("All tokens of user revoked")
+	return nil
+}
+
+// 登录会话相关的前缀
+const (
+	SessionPrefix      = "session:"
+	UserSessionsPrefix = "user_sessions:"
+)
+
+// SaveSession 保存会话并加入用户会话集合，ttl 为会话有效期
+func SaveSession(ctx context.Context, session *models.Session, ttl time.Duration) error {
+	key := SessionPrefix + session.ID
+	userKey := fmt.Sprintf("%s%d", UserSessionsPrefix, session.UserID)
+
+	_, err := RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"user_id":          session.UserID,
+			"device":           session.Device,
+			"user_agent":       session.UserAgent,
+			"ip":               session.IP,
+			"created_at":       session.CreatedAt.Unix(),
+			"last_seen":        session.LastSeen.Unix(),
+			"access_token_id":  session.AccessTokenID,
+			"refresh_token_id": session.RefreshTokenID,
+		})
+		pipe.Expire(ctx, key, ttl)
+		pipe.SAdd(ctx, userKey, session.ID)
+		pipe.Expire(ctx, userKey, ttl)
+		return nil
+	})
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"session_id": session.ID,
+			"user_id":    session.UserID,
+			"error":      err,
+		})).Error("Failed to save session")
+	}
+	return err
+}
+
+// GetSession 获取会话，不存在或已过期时返回 nil
+func GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	if sessionID == "" {
+		return nil, nil
+	}
+
+	values, err := RedisClient.HGetAll(ctx, SessionPrefix+sessionID).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	userID, _ := strconv.ParseUint(values["user_id"], 10, 32)
+	createdAt, _ := strconv.ParseInt(values["created_at"], 10, 64)
+	lastSeen, _ := strconv.ParseInt(values["last_seen"], 10, 64)
+	return &models.Session{
+		ID:             sessionID,
+		UserID:         uint(userID),
+		Device:         values["device"],
+		UserAgent:      values["user_agent"],
+		IP:             values["ip"],
+		CreatedAt:      time.Unix(createdAt, 0),
+		LastSeen:       time.Unix(lastSeen, 0),
+		AccessTokenID:  values["access_token_id"],
+		RefreshTokenID: values["refresh_token_id"],
+	}, nil
+}
+
+// touchSessionScript 仅在会话仍存在时更新最近活动时间和 IP，避免重新创建已撤销的会话
+var touchSessionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("HSET", KEYS[1], "last_seen", ARGV[1], "ip", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// TouchSession 更新会话的最近活动时间和 IP
+func TouchSession(ctx context.Context, sessionID, ip string) error {
+	return touchSessionScript.Run(ctx, RedisClient, []string{SessionPrefix + sessionID}, time.Now().Unix(), ip).Err()
+}
+
+// ListSessions 获取用户的所有会话，并清理集合中已过期的会话
+func ListSessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	userKey := fmt.Sprintf("%s%d", UserSessionsPrefix, userID)
+	sessionIDs, err := RedisClient.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]models.Session, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := GetSession(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			RedisClient.SRem(ctx, userKey, sessionID)
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, nil
+}
+
+// DeleteSession 删除用户的指定会话，会话不属于该用户时返回 false
+func DeleteSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	removed, err := RedisClient.SRem(ctx, fmt.Sprintf("%s%d", UserSessionsPrefix, userID), sessionID).Result()
+	if err != nil {
+		return false, err
+	}
+	// 会话不在该用户的会话集合中时不删除，避免删除其他用户的会话
+	if removed == 0 {
+		return false, nil
+	}
+	if err := RedisClient.Del(ctx, SessionPrefix+sessionID).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteUserSessions 删除用户的所有会话
+func DeleteUserSessions(ctx context.Context, userID uint) error {
+	userKey := fmt.Sprintf("%s%d", UserSessionsPrefix, userID)
+	sessionIDs, err := RedisClient.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{userKey}
+	for _, sessionID := range sessionIDs {
+		keys = append(keys, SessionPrefix+sessionID)
+	}
+	if err := RedisClient.Del(ctx, keys...).Err(); err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"user_id": userID,
+			"error":   err,
+		})).Error("Failed to delete user sessions")
+		return err
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":  userID,
+		"sessions": len(sessionIDs),
+	})).Info("All sessions of user deleted")
+	return nil
+}
+
+// rotateSessionScript 仅当会话当前的 refresh token 仍为 ARGV[1] 时替换令牌 ID，保证同一 refresh token 只能轮换一次
+var rotateSessionScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "refresh_token_id") ~= ARGV[1] then
+	return 0
+end
+redis.call("HSET", KEYS[1], "access_token_id", ARGV[2], "refresh_token_id", ARGV[3], "last_seen", ARGV[4], "ip", ARGV[5])
+redis.call("EXPIRE", KEYS[1], ARGV[6])
+redis.call("EXPIRE", KEYS[2], ARGV[6])
+return 1
+`)
+
+// RotateSession 将会话的令牌替换为 session 中的新令牌，previousRefreshTokenID 不是当前 refresh token 时返回 false
+func RotateSession(ctx context.Context, session *models.Session, previousRefreshTokenID string, ttl time.Duration) (bool, error) {
+	keys := []string{SessionPrefix + session.ID, fmt.Sprintf("%s%d", UserSessionsPrefix, session.UserID)}
+	rotated, err := rotateSessionScript.Run(ctx, RedisClient, keys, previousRefreshTokenID,
+		session.AccessTokenID, session.RefreshTokenID, session.LastSeen.Unix(), session.IP, int64(ttl.Seconds())).Int()
+	if err != nil {
+		return false, err
+	}
+	return rotated == 1, nil
+}
+
+// 两步验证相关的前缀
+const (
+	MFAChallengePrefix   = "mfa_challenge:"
+	TOTPEnrollmentPrefix = "totp_enrollment:"
+	TOTPUsedStepPrefix   = "totp_used:"
+)
+
+// SaveMFAChallenge 保存登录时的两步验证令牌，tokenHash 为令牌的哈希值
+func SaveMFAChallenge(ctx context.Context, tokenHash string, userID uint, ttl time.Duration) error {
+	key := MFAChallengePrefix + tokenHash
+	_, err := RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userID, "attempts", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// incrMFAAttemptsScript 仅在两步验证令牌仍存在时递增尝试次数，令牌不存在时返回 -1
+var incrMFAAttemptsScript = redis.NewScript(`
+local userID = redis.call("HGET", KEYS[1], "user_id")
+if not userID then
+	return {-1, 0}
+end
+return {redis.call("HINCRBY", KEYS[1], "attempts", 1), tonumber(userID)}
+`)
+
+// AttemptMFAChallenge 记录一次两步验证尝试，返回令牌所属用户和累计尝试次数，令牌不存在或已过期时用户为 0
+func AttemptMFAChallenge(ctx context.Context, tokenHash string) (uint, int64, error) {
+	result, err := incrMFAAttemptsScript.Run(ctx, RedisClient, []string{MFAChallengePrefix + tokenHash}).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	if result[0] < 0 {
+		return 0, 0, nil
+	}
+	return uint(result[1]), result[0], nil
+}
+
+// GetMFAChallengeUser 获取两步验证令牌所属的用户，令牌不存在或已过期时返回 0
+func GetMFAChallengeUser(ctx context.Context, tokenHash string) (uint, error) {
+	userID, err := RedisClient.HGet(ctx, MFAChallengePrefix+tokenHash, "user_id").Uint64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return uint(userID), err
+}
+
+// DeleteMFAChallenge 删除两步验证令牌，令牌已被删除时返回 false，保证令牌只能兑换一次
+func DeleteMFAChallenge(ctx context.Context, tokenHash string) (bool, error) {
+	deleted, err := RedisClient.Del(ctx, MFAChallengePrefix+tokenHash).Result()
+	return deleted > 0, err
+}
+
+// SetTOTPEnrollment 保存用户待确认的 TOTP 密钥（已加密）
+func SetTOTPEnrollment(ctx context.Context, userID uint, secret string, ttl time.Duration) error {
+	return RedisClient.Set(ctx, fmt.Sprintf("%s%d", TOTPEnrollmentPrefix, userID), secret, ttl).Err()
+}
+
+// GetTOTPEnrollment 获取用户待确认的 TOTP 密钥，不存在时返回空字符串
+func GetTOTPEnrollment(ctx context.Context, userID uint) (string, error) {
+	secret, err := RedisClient.Get(ctx, fmt.Sprintf("%s%d", TOTPEnrollmentPrefix, userID)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return secret, err
+}
+
+// DeleteTOTPEnrollment 删除用户待确认的 TOTP 密钥
+func DeleteTOTPEnrollment(ctx context.Context, userID uint) error {
+	return RedisClient.Del(ctx, fmt.Sprintf("%s%d", TOTPEnrollmentPrefix, userID)).Err()
+}
+
+// MarkTOTPStepUsed 标记用户在某个时间步的验证码已使用，已使用过时返回 false，防止验证码被重放
+func MarkTOTPStepUsed(ctx context.Context, userID uint, step int64, ttl time.Duration) (bool, error) {
+	return RedisClient.SetNX(ctx, fmt.Sprintf("%s%d:%d", TOTPUsedStepPrefix, userID, step), 1, ttl).Result()
+}
+
+// OpenID Connect 登录相关的前缀
+const (
+	OIDCStatePrefix     = "oidc_state:"
+	OIDCLoginCodePrefix = "oidc_login_code:"
+)
+
+// SetOIDCState 保存授权请求的 state 及其关联数据，binding 为发起登录的浏览器所持 Cookie 的哈希值
+func SetOIDCState(ctx context.Context, state, binding string, value []byte, ttl time.Duration) error {
+	key := OIDCStatePrefix + state
+	_, err := RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "binding", binding, "data", value)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// takeOIDCStateScript 仅当 binding 与保存的一致时读取并删除 state，其他浏览器无法使用或消耗该 state
+var takeOIDCStateScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "binding") ~= ARGV[1] then
+	return false
+end
+local data = redis.call("HGET", KEYS[1], "data")
+redis.call("DEL", KEYS[1])
+return data
+`)
+
+// TakeOIDCState 校验发起登录的浏览器后读取并删除授权请求的 state，保证每个 state 只能使用一次
+// state 不存在或 binding 不一致时返回 nil
+func TakeOIDCState(ctx context.Context, state, binding string) ([]byte, error) {
+	value, err := takeOIDCStateScript.Run(ctx, RedisClient, []string{OIDCStatePrefix + state}, binding).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// SetOIDCLoginCode 保存一次性登录兑换码，codeHash 为兑换码的哈希值
+func SetOIDCLoginCode(ctx context.Context, codeHash string, userID uint, ttl time.Duration) error {
+	return RedisClient.Set(ctx, OIDCLoginCodePrefix+codeHash, userID, ttl).Err()
+}
+
+// TakeOIDCLoginCode 读取并删除登录兑换码，保证兑换码只能使用一次，不存在时返回 0
+func TakeOIDCLoginCode(ctx context.Context, codeHash string) (uint, error) {
+	value, err := RedisClient.GetDel(ctx, OIDCLoginCodePrefix+codeHash).Uint64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return uint(value), err
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"keep_learning_blog/config"
 	"keep_learning_blog/db"
+	"keep_learning_blog/middleware"
 	"keep_learning_blog/routes"
+	"keep_learning_blog/service"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/mail"
+	"keep_learning_blog/utils/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +26,13 @@ import (
 func main() {
 	// 获取配置
 	cfg := config.GetConfig()
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("Invalid configuration: %v\n", err)
+		if !cfg.Server.IsDevelopment() {
+			fmt.Println("Set APP_ENV=development to run locally with development defaults, see README.md for the production settings")
+		}
+		os.Exit(1)
+	}
 
 	// 初始化日志
 	if err := logger.InitLogger(cfg); err != nil {
@@ -26,6 +43,11 @@ func main() {
 	// 使用全局日志实例
 	log := logger.Log
 
+	// 加载令牌签名密钥
+	if err := middleware.InitSigningKeys(&cfg.JWT); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
 	// 初始化数据库
 	if err := db.InitDB(cfg); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
@@ -36,24 +58,66 @@ func main() {
 		log.Fatalf("Failed to initialize Redis: %v", err)
 	}
 
+	// 初始化文件存储
+	backend, err := storage.New(&cfg.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// 初始化邮件发送器
+	mailer, err := mail.New(&cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// 启动图片衍生图生成器
+	imageProcessor := service.NewImageProcessor(cfg, backend)
+	imageProcessor.Start()
+
 	// 创建 Gin 实例
 	r := gin.Default()
 
 	// 设置路由
-	routes.SetupRoutes(r, cfg)
+	routes.SetupRoutes(r, cfg, backend, imageProcessor, mailer)
+
+	// 启动文章定时发布调度器
+	scheduler := service.NewPostScheduler(cfg)
+	scheduler.Start()
 
 	// 启动服务器
 	serverAddr := fmt.Sprintf(":%s", cfg.Server.Port)
-	log.Infof("Server starting on %s", serverAddr)
-	if cfg.Server.TLS.Enable {
-		if err := r.RunTLS(serverAddr,
-			cfg.Server.TLS.CertFile,
-			cfg.Server.TLS.KeyFile); err != nil {
-			log.Fatalf("Failed to start HTTPS server: %v", err)
+	srv := &http.Server{
+		Addr:    serverAddr,
+		Handler: r,
+	}
+
+	go func() {
+		log.Infof("Server starting on %s", serverAddr)
+		var err error
+		if cfg.Server.TLS.Enable {
+			err = srv.ListenAndServeTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
 		}
-	} else {
-		if err := r.Run(serverAddr); err != nil {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Failed to start server: %v", err)
 		}
+	}()
+
+	// 等待退出信号
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Info("Shutting down server...")
+
+	// 停止后台任务并优雅关闭服务器
+	scheduler.Stop()
+	imageProcessor.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Errorf("Server forced to shutdown: %v", err)
 	}
+	log.Info("Server exited")
 }
@@ -57,3 +57,20 @@ func AuditLog() gin.HandlerFunc {
 		})).Info("Audit log")
 	}
 }
+
+// AuditSecurityEvent 记录安全事件到审计日志，如令牌重用等可疑行为
+func AuditSecurityEvent(c *gin.Context, event string, fields map[string]interface{}) {
+	entry := map[string]interface{}{
+		"event":      event,
+		"path":       c.Request.URL.Path,
+		"method":     c.Request.Method,
+		"client_ip":  c.ClientIP(),
+		"user_agent": c.Request.UserAgent(),
+	}
+	for key, value := range fields {
+		entry[key] = value
+	}
+
+	logger.AuditLog.WithFields(logger.Fields(entry)).Warn("Security event")
+	logger.Log.WithFields(logger.Fields(entry)).Warn("Security event")
+}
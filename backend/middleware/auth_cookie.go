@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"keep_learning_blog/config"
+	"net/http"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie 模式下令牌 Cookie 的路径，刷新令牌只发送到刷新接口
+const (
+	accessTokenCookiePath  = "/api"
+	refreshTokenCookiePath = "/api/refresh"
+)
+
+// CSRFHeader 前端提交 CSRF 令牌的请求头
+const CSRFHeader = "X-CSRF-Token"
+
+// SetAuthCookies Cookie 模式下将令牌对写入 HttpOnly Cookie，并生成新的 CSRF 令牌
+// CSRF 令牌写入前端可读取的 Cookie 并返回，前后端不同源时前端无法读取 Cookie，可使用返回值
+func SetAuthCookies(c *gin.Context, cfg *config.JWTConfig, accessToken, refreshToken string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	setAuthCookie(c, cfg, cfg.Cookie.AccessTokenName, accessToken, accessTokenCookiePath, cfg.AccessTokenTTL, true)
+	setAuthCookie(c, cfg, cfg.Cookie.RefreshTokenName, refreshToken, refreshTokenCookiePath, cfg.RefreshTokenTTL, true)
+	setAuthCookie(c, cfg, cfg.Cookie.CSRFTokenName, csrfToken, "/", cfg.RefreshTokenTTL, false)
+	return csrfToken, nil
+}
+
+// ClearAuthCookies 删除 Cookie 模式下的令牌 Cookie
+func ClearAuthCookies(c *gin.Context, cfg *config.JWTConfig) {
+	setAuthCookie(c, cfg, cfg.Cookie.AccessTokenName, "", accessTokenCookiePath, -1, true)
+	setAuthCookie(c, cfg, cfg.Cookie.RefreshTokenName, "", refreshTokenCookiePath, -1, true)
+	setAuthCookie(c, cfg, cfg.Cookie.CSRFTokenName, "", "/", -1, false)
+}
+
+// setAuthCookie 写入 Cookie，ttl 小于 0 时删除
+func setAuthCookie(c *gin.Context, cfg *config.JWTConfig, name, value, path string, ttl time.Duration, httpOnly bool) {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.Cookie.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Cookie.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.Cookie.SameSite,
+	})
+}
+
+// CSRF 双重提交 Cookie 的 CSRF 防护中间件，仅在 Cookie 模式下生效
+// 携带令牌 Cookie 的非安全方法请求，X-CSRF-Token 请求头必须与 CSRF Cookie 一致；
+// 通过 Authorization 请求头认证的请求（如个人访问令牌）不依赖 Cookie，不做检查
+func CSRF(cfg *config.JWTConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Mode != config.AuthModeCookie {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if c.GetHeader("Authorization") != "" || !hasAuthCookie(c, cfg) {
+			c.Next()
+			return
+		}
+
+		cookie, err := c.Cookie(cfg.Cookie.CSRFTokenName)
+		header := c.GetHeader(CSRFHeader)
+		if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			logger.Log.WithFields(logger.Fields(map[string]interface{}{
+				"path":      c.Request.URL.Path,
+				"method":    c.Request.Method,
+				"client_ip": c.ClientIP(),
+			})).Warn("CSRF token mismatch")
+
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// hasAuthCookie 检查请求是否携带令牌 Cookie
+func hasAuthCookie(c *gin.Context, cfg *config.JWTConfig) bool {
+	for _, name := range []string{cfg.Cookie.AccessTokenName, cfg.Cookie.RefreshTokenName} {
+		if value, err := c.Cookie(name); err == nil && value != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"keep_learning_blog/config"
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// csrfRouter 使用 CSRF 中间件保护所有方法的路由
+func csrfRouter(mode string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	cfg := config.GetConfig().JWT
+	cfg.Mode = mode
+
+	router := gin.New()
+	router.Use(CSRF(&cfg))
+	router.Any("/api/posts", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return router
+}
+
+// TestCSRF 携带令牌 Cookie 的非安全方法请求必须提交与 CSRF Cookie 一致的请求头
+func TestCSRF(t *testing.T) {
+	cases := []struct {
+		name          string
+		method        string
+		accessCookie  string
+		refreshCookie string
+		csrfCookie    string
+		csrfHeader    string
+		authorization string
+		want          int
+	}{
+		{"matching header", http.MethodPost, "access", "", "token", "token", "", http.StatusNoContent},
+		{"missing header", http.MethodPost, "access", "", "token", "", "", http.StatusForbidden},
+		{"mismatched header", http.MethodPost, "access", "", "token", "other", "", http.StatusForbidden},
+		{"missing CSRF cookie", http.MethodPost, "access", "", "", "token", "", http.StatusForbidden},
+		{"empty CSRF cookie and header", http.MethodPost, "access", "", "", "", "", http.StatusForbidden},
+		{"refresh cookie only", http.MethodPost, "", "refresh", "token", "other", "", http.StatusForbidden},
+		{"delete with mismatched header", http.MethodDelete, "access", "", "token", "other", "", http.StatusForbidden},
+		{"put with matching header", http.MethodPut, "access", "", "token", "token", "", http.StatusNoContent},
+		{"authorization header present", http.MethodPost, "access", "", "token", "", "Bearer pat_token", http.StatusNoContent},
+		{"no auth cookie", http.MethodPost, "", "", "", "", "", http.StatusNoContent},
+		{"GET is safe", http.MethodGet, "access", "", "token", "", "", http.StatusNoContent},
+		{"HEAD is safe", http.MethodHead, "access", "", "token", "other", "", http.StatusNoContent},
+		{"OPTIONS is safe", http.MethodOptions, "access", "", "", "", "", http.StatusNoContent},
+	}
+
+	router := csrfRouter(config.AuthModeCookie)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/posts", nil)
+			for name, value := range map[string]string{
+				"access_token":  tc.accessCookie,
+				"refresh_token": tc.refreshCookie,
+				"csrf_token":    tc.csrfCookie,
+			} {
+				if value != "" {
+					req.AddCookie(&http.Cookie{Name: name, Value: value})
+				}
+			}
+			if tc.csrfHeader != "" {
+				req.Header.Set(CSRFHeader, tc.csrfHeader)
+			}
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			if resp.Code != tc.want {
+				t.Fatalf("status = %d, want %d", resp.Code, tc.want)
+			}
+		})
+	}
+}
+
+// TestCSRFHeaderMode header 模式下令牌不在 Cookie 中，不做 CSRF 检查
+func TestCSRFHeaderMode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: "access"})
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: "token"})
+
+	resp := httptest.NewRecorder()
+	csrfRouter(config.AuthModeHeader).ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusNoContent)
+	}
+}
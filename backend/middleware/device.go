@@ -0,0 +1,56 @@
+package middleware
+
+import "strings"
+
+// maxUserAgentLength 会话中保存的 User-Agent 最大长度
+const maxUserAgentLength = 512
+
+// browserPatterns、platformPatterns 按顺序匹配 User-Agent 中的关键字，靠前的规则优先
+var (
+	browserPatterns = []struct{ keyword, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+	}
+	platformPatterns = []struct{ keyword, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// describeDevice 根据 User-Agent 生成简短的设备描述，如 "Chrome on Windows"
+func describeDevice(userAgent string) string {
+	browser, platform := "", ""
+	for _, p := range browserPatterns {
+		if strings.Contains(userAgent, p.keyword) {
+			browser = p.name
+			break
+		}
+	}
+	for _, p := range platformPatterns {
+		if strings.Contains(userAgent, p.keyword) {
+			platform = p.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
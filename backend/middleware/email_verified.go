@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail 邮箱验证中间件，未验证邮箱的用户不能发表文章和评论
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetUint("user_id")
+
+		verified, err := userService.IsEmailVerified(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if !verified {
+			logger.Log.WithFields(logger.Fields(map[string]interface{}{
+				"user_id": userID,
+				"path":    c.FullPath(),
+			})).Warn("Email not verified")
+
+			c.JSON(http.StatusForbidden, gin.H{"error": "email address has not been verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
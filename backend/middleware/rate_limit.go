@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"keep_learning_blog/config"
 	"keep_learning_blog/db"
@@ -22,8 +23,13 @@ func NewRateLimiter(config *config.Config) *RateLimiter {
 	}
 }
 
-// RateLimit 创建限流中间件
+// RateLimit 创建限流中间件，使用默认时间窗口
 func (rl *RateLimiter) RateLimit(limit int) gin.HandlerFunc {
+	return rl.RateLimitWindow(limit, rl.config.RateLimit.Duration)
+}
+
+// RateLimitWindow 创建指定时间窗口的限流中间件
+func (rl *RateLimiter) RateLimitWindow(limit int, window time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取客户端标识（优先使用用户ID，其次使用IP）
 		identifier := getClientIdentifier(c)
@@ -46,7 +52,7 @@ func (rl *RateLimiter) RateLimit(limit int) gin.HandlerFunc {
 
 		// 如果是第一次请求，设置初始值和过期时间
 		if count == 0 {
-			err = db.SetRateLimit(context.Background(), key, rl.config.RateLimit.Duration)
+			err = db.SetRateLimit(context.Background(), key, window)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limit set failed"})
 				c.Abort()
@@ -67,7 +73,7 @@ func (rl *RateLimiter) RateLimit(limit int) gin.HandlerFunc {
 
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":       "Rate limit exceeded",
-				"retry_after": rl.config.RateLimit.Duration.Seconds(),
+				"retry_after": window.Seconds(),
 			})
 			c.Abort()
 			return
@@ -100,6 +106,11 @@ func (rl *RateLimiter) AuthAPILimit() gin.HandlerFunc {
 	return rl.RateLimit(rl.config.RateLimit.AuthAPILimit)
 }
 
+// VerificationResendLimit 重发验证邮件限流
+func (rl *RateLimiter) VerificationResendLimit() gin.HandlerFunc {
+	return rl.RateLimitWindow(rl.config.RateLimit.VerificationResendLimit, rl.config.RateLimit.VerificationResendDuration)
+}
+
 // getClientIdentifier 获取客户端标识
 func getClientIdentifier(c *gin.Context) string {
 	// 如果用户已登录，使用用户ID
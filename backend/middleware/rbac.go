@@ -30,33 +30,16 @@ func RBACAuth(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// 尝试从Redis获取权限
-		permissions, err := db.GetUserPermissions(c, userID.(uint), cfg)
+		// 获取用户权限
+		permissions, err := loadUserPermissions(c, userID.(uint), cfg)
 		if err != nil {
-			logger.Log.WithError(err).Error("Failed to get permissions from Redis")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get permissions"})
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "failed to get user permissions",
+			})
 			c.Abort()
 			return
 		}
 
-		// 如果Redis中没有,从数据库获取并缓存
-		if permissions == nil {
-			permissions, err = userService.GetUserPermissions(userID.(uint))
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"message": "failed to get user permissions",
-				})
-				c.Abort()
-				return
-			}
-
-			// 缓存到Redis
-			if err := db.SetUserPermissions(c, userID.(uint), permissions, cfg); err != nil {
-				// 仅记录日志,不中断请求
-				log.Printf("Failed to cache permissions: %v", err)
-			}
-		}
-
 		// 获取路径并去掉 /api 前缀
 		fullPath := c.Request.URL.Path
 		path := strings.TrimPrefix(fullPath, "/api")
@@ -83,6 +66,76 @@ func RBACAuth(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// HasPermission 检查当前登录用户是否拥有指定编码的权限
+// 用于公开路由中按权限控制数据可见性，未登录或查询失败时返回 false
+func HasPermission(c *gin.Context, cfg *config.Config, code string) bool {
+	userID := c.GetUint("user_id")
+	if userID == 0 {
+		return false
+	}
+
+	permissions, err := loadUserPermissions(c, userID, cfg)
+	if err != nil {
+		return false
+	}
+
+	for _, permission := range permissions {
+		if permission.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// loadUserPermissions 获取用户权限，优先从Redis读取，未命中时从数据库获取并缓存
+// 使用个人访问令牌时只返回令牌范围内的权限
+func loadUserPermissions(c *gin.Context, userID uint, cfg *config.Config) ([]models.Permission, error) {
+	// 尝试从Redis获取权限
+	permissions, err := db.GetUserPermissions(c, userID, cfg)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to get permissions from Redis")
+		return nil, err
+	}
+
+	// 如果Redis中没有,从数据库获取并缓存
+	if permissions == nil {
+		permissions, err = userService.GetUserPermissions(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		// 缓存到Redis
+		if err := db.SetUserPermissions(c, userID, permissions, cfg); err != nil {
+			// 仅记录日志,不中断请求
+			log.Printf("Failed to cache permissions: %v", err)
+		}
+	}
+
+	return scopePermissions(c, permissions), nil
+}
+
+// scopePermissions 使用个人访问令牌时，有效权限为用户角色权限与令牌范围的交集
+// 角色被收回的权限即使仍在令牌范围内也不再生效
+func scopePermissions(c *gin.Context, permissions []models.Permission) []models.Permission {
+	value, exists := c.Get("token_scopes")
+	if !exists {
+		return permissions
+	}
+	scopes, _ := value.([]string)
+
+	allowed := make(map[string]bool, len(scopes))
+	for _, code := range scopes {
+		allowed[code] = true
+	}
+	scoped := make([]models.Permission, 0, len(scopes))
+	for _, permission := range permissions {
+		if allowed[permission.Code] {
+			scoped = append(scoped, permission)
+		}
+	}
+	return scoped
+}
+
 // checkPermission 检查是否有权限访问
 func checkPermission(permissions []models.Permission, method, path string) bool {
 	for _, permission := range permissions {
@@ -99,8 +152,8 @@ func matchPath(pattern, path string) bool {
 	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
 	pathParts := strings.Split(strings.Trim(path, "/"), "/")
 
-	// 如果路径部分比模式部分少，直接返回false
-	if len(pathParts) < len(patternParts) {
+	// 路径段数必须与模式一致，避免 /post/:id 匹配到 /post/:id/publish 等子路由
+	if len(pathParts) != len(patternParts) {
 		return false
 	}
 
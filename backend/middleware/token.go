@@ -1,13 +1,17 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"keep_learning_blog/config"
 	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/service"
 	"net/http"
 	"strings"
 	"time"
 
+	"keep_learning_blog/utils/jwtkeys"
 	"keep_learning_blog/utils/logger"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +19,32 @@ import (
 	"github.com/google/uuid"
 )
 
+// signingKeys 令牌签名密钥，由 InitSigningKeys 初始化
+var signingKeys *jwtkeys.KeySet
+
+// InitSigningKeys 加载令牌签名密钥
+func InitSigningKeys(cfg *config.JWTConfig) error {
+	keys, err := jwtkeys.Load(cfg)
+	if err != nil {
+		return err
+	}
+	signingKeys = keys
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"kid":       keys.SigningKey().ID,
+		"algorithm": keys.SigningKey().Algorithm,
+	})).Info("JWT signing keys loaded")
+	return nil
+}
+
+// PublicKeys 返回所有有效签名密钥的公钥（JSON Web Key Set）
+func PublicKeys() jwtkeys.JSONWebKeySet {
+	return signingKeys.JWKS()
+}
+
+// personalAccessTokenService 个人访问令牌服务
+var personalAccessTokenService = &service.PersonalAccessTokenService{}
+
 type TokenAuther struct {
 	config *config.JWTConfig
 }
@@ -31,25 +61,68 @@ type JWTClaims struct {
 	Username  string `json:"username"`
 	TokenID   string `json:"token_id"`
 	TokenType string `json:"token_type"`
+	SessionID string `json:"session_id"`    // 所属登录会话
+	Version   int64  `json:"token_version"` // 签发时用户的令牌版本号，重置密码后旧令牌失效
 	jwt.StandardClaims
 }
 
-// CreateTokenPair 生成访问令牌和刷新令牌
-func CreateTokenPair(userID uint, username string, cfg *config.JWTConfig) (accessToken, refreshToken string, err error) {
+// CreateTokenPair 创建登录会话并生成访问令牌和刷新令牌
+// 会话记录请求的设备、User-Agent 和 IP，两个令牌都携带会话 ID，撤销会话后同时失效
+func CreateTokenPair(c *gin.Context, userID uint, username string, cfg *config.JWTConfig) (accessToken, refreshToken string, err error) {
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Device:    describeDevice(userAgent),
+		UserAgent: userAgent,
+		IP:        c.ClientIP(),
+		CreatedAt: now,
+		LastSeen:  now,
+	}
+	accessToken, refreshToken, err = signTokenPair(c, session, username, cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 保存会话
+	if err := db.SaveSession(c, session, cfg.RefreshTokenTTL); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// signTokenPair 为会话签发新的令牌对，并将新令牌 ID 写入 session（不保存）
+func signTokenPair(ctx context.Context, session *models.Session, username string, cfg *config.JWTConfig) (accessToken, refreshToken string, err error) {
+	// 获取用户当前的令牌版本号
+	version, err := db.GetTokenVersion(ctx, session.UserID)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+
 	// 生成访问令牌
 	accessTokenID := uuid.New().String()
 	accessClaims := JWTClaims{
-		UserID:    userID,
+		UserID:    session.UserID,
 		Username:  username,
 		TokenID:   accessTokenID,
 		TokenType: "access",
+		SessionID: session.ID,
+		Version:   version,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(cfg.AccessTokenTTL).Unix(),
+			Issuer:    cfg.Issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(cfg.AccessTokenTTL).Unix(),
 		},
 	}
 
-	accessToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).
-		SignedString([]byte(cfg.AccessTokenSecret))
+	accessToken, err = signingKeys.Sign(accessClaims)
 	if err != nil {
 		return "", "", err
 	}
@@ -57,28 +130,38 @@ func CreateTokenPair(userID uint, username string, cfg *config.JWTConfig) (acces
 	// 生成 refresh token
 	refreshTokenID := uuid.New().String()
 	refreshClaims := JWTClaims{
-		UserID:    userID,
+		UserID:    session.UserID,
 		Username:  username,
 		TokenID:   refreshTokenID,
 		TokenType: "refresh",
+		SessionID: session.ID,
+		Version:   version,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(cfg.RefreshTokenTTL).Unix(),
+			Issuer:    cfg.Issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(cfg.RefreshTokenTTL).Unix(),
 		},
 	}
 
-	refreshToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).
-		SignedString([]byte(cfg.RefreshTokenSecret))
+	refreshToken, err = signingKeys.Sign(refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
 
+	session.AccessTokenID = accessTokenID
+	session.RefreshTokenID = refreshTokenID
 	return accessToken, refreshToken, nil
 }
 
 // RefreshToken 刷新访问令牌
+// 同一会话中依次签发的 refresh token 组成一个令牌族，每次刷新后旧令牌失效；
+// 若已轮换过的 refresh token 被再次使用，说明令牌可能被盗用，撤销整个令牌族（会话）并记录安全事件
 func RefreshJWTToken(c *gin.Context, cfg *config.JWTConfig) (string, string, error) {
-	// 获取 refresh token
+	// 获取 refresh token，Cookie 模式下未携带请求头时从 Cookie 读取
 	refreshToken := c.GetHeader("Refresh-Token")
+	if refreshToken == "" && cfg.Mode == config.AuthModeCookie {
+		refreshToken, _ = c.Cookie(cfg.Cookie.RefreshTokenName)
+	}
 	if refreshToken == "" {
 		return "", "", errors.New("refresh token is required")
 	}
@@ -89,16 +172,47 @@ func RefreshJWTToken(c *gin.Context, cfg *config.JWTConfig) (string, string, err
 		if claims.TokenType != "refresh" {
 			return nil, errors.New("invalid token type")
 		}
-		return []byte(cfg.RefreshTokenSecret), nil
+		return signingKeys.Keyfunc(token)
 	})
 
 	// 检查 refresh token 是否有效
-	if err != nil || !token.Valid {
+	if err != nil || !token.Valid || !claims.VerifyIssuer(cfg.Issuer, true) {
 		return "", "", errors.New("invalid refresh token")
 	}
 
-	// 检查 refresh token 是否在黑名单中
-	if db.IsBlacklisted(c, claims.TokenID) {
+	// 检查令牌版本号
+	if !tokenVersionValid(c, claims) {
+		return "", "", errors.New("refresh token has been revoked")
+	}
+
+	// 检查会话是否仍然有效
+	session, err := db.GetSession(c, claims.SessionID)
+	if err != nil {
+		return "", "", errors.New("failed to get session")
+	}
+	if session == nil || session.UserID != claims.UserID {
+		return "", "", errors.New("refresh token has been revoked")
+	}
+
+	// 不是会话当前的 refresh token，说明已被轮换过
+	if session.RefreshTokenID != claims.TokenID || db.IsBlacklisted(c, claims.TokenID) {
+		revokeTokenFamily(c, claims)
+		return "", "", errors.New("refresh token has been revoked")
+	}
+
+	// 在原会话中生成新的令牌对，仅当会话当前的 refresh token 仍为本令牌时才替换，避免并发刷新
+	session.LastSeen = time.Now()
+	session.IP = c.ClientIP()
+	accessToken, newRefreshToken, err := signTokenPair(c, session, claims.Username, cfg)
+	if err != nil {
+		return "", "", err
+	}
+	rotated, err := db.RotateSession(c, session, claims.TokenID, cfg.RefreshTokenTTL)
+	if err != nil {
+		return "", "", errors.New("failed to rotate refresh token")
+	}
+	if !rotated {
+		revokeTokenFamily(c, claims)
 		return "", "", errors.New("refresh token has been revoked")
 	}
 
@@ -107,66 +221,187 @@ func RefreshJWTToken(c *gin.Context, cfg *config.JWTConfig) (string, string, err
 		return "", "", errors.New("failed to invalidate refresh token")
 	}
 
-	// 生成新的令牌对
-	return CreateTokenPair(claims.UserID, claims.Username, cfg)
+	return accessToken, newRefreshToken, nil
+}
+
+// revokeTokenFamily 检测到 refresh token 重用时撤销整个令牌族，并写入审计日志
+func revokeTokenFamily(c *gin.Context, claims *JWTClaims) {
+	if _, err := db.DeleteSession(c, claims.UserID, claims.SessionID); err != nil {
+		logger.Log.WithError(err).Error("Failed to revoke token family")
+	}
+
+	AuditSecurityEvent(c, "refresh_token_reuse", map[string]interface{}{
+		"user_id":    claims.UserID,
+		"username":   claims.Username,
+		"session_id": claims.SessionID,
+		"token_id":   claims.TokenID,
+	})
 }
 
 // TokenAuth 令牌认证中间件
 func (t *TokenAuther) TokenAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		cookieToken := t.cookieToken(c)
+		if authHeader == "" && cookieToken == "" {
 			logger.Log.Warn("Missing Authorization header")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			c.Abort()
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			logger.Log.Warn("Invalid authorization header format")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		var err error
+		if authHeader != "" {
+			err = t.authenticate(c, authHeader)
+		} else {
+			err = t.authenticateToken(c, cookieToken)
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// 获取 access token
-		tokenString := parts[1]
-		claims := &JWTClaims{}
-
-		// 解析 access token
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if claims.TokenType != "access" {
-				return nil, errors.New("invalid token type")
-			}
-			return []byte(t.config.AccessTokenSecret), nil
-		})
-
-		// 检查 access token 是否有效
-		if err != nil || !token.Valid {
-			logger.Log.WithError(err).Warn("Invalid token")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
+		c.Next()
+	}
+}
+
+// OptionalTokenAuth 可选令牌认证中间件
+// 用于公开路由：携带有效令牌时写入用户信息，未携带或令牌无效时按匿名用户继续处理
+func (t *TokenAuther) OptionalTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 令牌无效时按匿名用户继续处理
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			_ = t.authenticate(c, authHeader)
+		} else if cookieToken := t.cookieToken(c); cookieToken != "" {
+			_ = t.authenticateToken(c, cookieToken)
 		}
 
-		// 检查 access token 是否在黑名单中
-		if db.IsBlacklisted(c, claims.TokenID) {
-			logger.Log.WithFields(logger.Fields(map[string]interface{}{
-				"token_id": claims.TokenID,
-				"user_id":  claims.UserID,
-			})).Warn("Token has been revoked")
+		c.Next()
+	}
+}
 
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+// RequireSession 要求请求使用登录会话签发的 access token
+// 用于账户安全相关的路由，个人访问令牌不能管理会话、两步验证及其他个人访问令牌
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("session_id") == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This operation requires an interactive login session"})
 			c.Abort()
 			return
 		}
 
-		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("token_id", claims.TokenID)
-
 		c.Next()
 	}
 }
+
+// cookieToken Cookie 模式下从 Cookie 中读取 access token
+func (t *TokenAuther) cookieToken(c *gin.Context) string {
+	if t.config.Mode != config.AuthModeCookie {
+		return ""
+	}
+	token, _ := c.Cookie(t.config.Cookie.AccessTokenName)
+	return token
+}
+
+// authenticate 校验 Authorization 头中的令牌
+func (t *TokenAuther) authenticate(c *gin.Context, authHeader string) error {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		logger.Log.Warn("Invalid authorization header format")
+		return errors.New("Invalid authorization header format")
+	}
+
+	return t.authenticateToken(c, parts[1])
+}
+
+// authenticateToken 校验令牌，并将用户信息存储到上下文中
+// 支持登录签发的 access token 和个人访问令牌，个人访问令牌的权限范围存储为 token_scopes
+func (t *TokenAuther) authenticateToken(c *gin.Context, tokenString string) error {
+	// 个人访问令牌
+	if service.IsPersonalAccessToken(tokenString) {
+		token, err := personalAccessTokenService.Authenticate(tokenString)
+		if err != nil {
+			logger.Log.WithError(err).Warn("Invalid personal access token")
+			return errors.New("Invalid token")
+		}
+
+		c.Set("user_id", token.UserID)
+		c.Set("username", token.User.Username)
+		c.Set("personal_access_token_id", token.ID)
+		c.Set("token_scopes", token.Scopes)
+		return nil
+	}
+
+	claims, err := t.parseAccessToken(c, tokenString)
+	if err != nil {
+		return err
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("token_id", claims.TokenID)
+	c.Set("session_id", claims.SessionID)
+	return nil
+}
+
+// parseAccessToken 解析并校验 access token
+func (t *TokenAuther) parseAccessToken(c *gin.Context, tokenString string) (*JWTClaims, error) {
+	claims := &JWTClaims{}
+
+	// 解析 access token
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if claims.TokenType != "access" {
+			return nil, errors.New("invalid token type")
+		}
+		return signingKeys.Keyfunc(token)
+	})
+
+	// 检查 access token 是否有效
+	if err != nil || !token.Valid || !claims.VerifyIssuer(t.config.Issuer, true) {
+		logger.Log.WithError(err).Warn("Invalid token")
+		return nil, errors.New("Invalid token")
+	}
+
+	// 检查 access token 是否在黑名单中
+	if db.IsBlacklisted(c, claims.TokenID) || !tokenVersionValid(c, claims) {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"token_id": claims.TokenID,
+			"user_id":  claims.UserID,
+		})).Warn("Token has been revoked")
+
+		return nil, errors.New("Token has been revoked")
+	}
+
+	// 检查会话是否仍然有效，且 access token 为会话当前的令牌
+	session, err := db.GetSession(c, claims.SessionID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to get session")
+		return nil, errors.New("Failed to verify token")
+	}
+	if session == nil || session.UserID != claims.UserID || session.AccessTokenID != claims.TokenID {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"session_id": claims.SessionID,
+			"user_id":    claims.UserID,
+		})).Warn("Session has been revoked")
+
+		return nil, errors.New("Token has been revoked")
+	}
+
+	// 更新会话最近活动时间
+	if err := db.TouchSession(c, claims.SessionID, c.ClientIP()); err != nil {
+		logger.Log.WithError(err).Warn("Failed to update session last seen")
+	}
+
+	return claims, nil
+}
+
+// tokenVersionValid 检查令牌版本号是否与用户当前版本一致，读取失败时视为无效
+func tokenVersionValid(ctx context.Context, claims *JWTClaims) bool {
+	version, err := db.GetTokenVersion(ctx, claims.UserID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to get token version")
+		return false
+	}
+	return claims.Version == version
+}
@@ -3,7 +3,9 @@ package middleware
 import (
 	"bytes"
 	"io"
+	"keep_learning_blog/utils/sanitizer"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/microcosm-cc/bluemonday"
@@ -11,26 +13,12 @@ import (
 
 var (
 	// strictPolicy 用于普通文本，完全转义所有HTML
-	strictPolicy = bluemonday.StrictPolicy()
+	strictPolicy = sanitizer.StrictPolicy
 
-	// htmlPolicy 用于富文本，允许安全的HTML标签
-	htmlPolicy = bluemonday.UGCPolicy()
+	// htmlPolicy 用于富文本，允许安全的HTML标签（策略定义见 utils/sanitizer，供业务层渲染时复用）
+	htmlPolicy = sanitizer.HTMLPolicy
 )
 
-func init() {
-	// 配置富文本策略
-	htmlPolicy.AllowStandardURLs()
-	htmlPolicy.AllowStandardAttributes()
-	// 允许常用的安全标签
-	htmlPolicy.AllowElements("p", "br", "b", "i", "strong", "em", "ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "code", "pre", "hr")
-	// 允许链接
-	htmlPolicy.AllowAttrs("href").OnElements("a")
-	// 允许图片
-	htmlPolicy.AllowAttrs("src", "alt").OnElements("img")
-	// 允许代码高亮相关属性
-	htmlPolicy.AllowAttrs("class").OnElements("code", "pre")
-}
-
 // XSSProtection 中间件用于处理XSS防护
 func XSSProtection() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -76,7 +64,13 @@ type xssResponseWriter struct {
 }
 
 // Write 重写 Write 方法，对响应进行处理
+// 仅过滤 HTML 响应：JSON 响应由 encoding/json 转义 <、>、&，富文本字段在保存时已经过滤，
+// 对其再次过滤会破坏 JSON 结构和 content_html 等字段；XML、图片等响应同样不做处理
 func (w *xssResponseWriter) Write(data []byte) (int, error) {
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		return w.ResponseWriter.Write(data)
+	}
+
 	// 对响应数据进行处理
 	sanitized := w.policy.SanitizeBytes(data)
 	return w.ResponseWriter.Write(sanitized)
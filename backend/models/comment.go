@@ -4,25 +4,66 @@ import (
 	"time"
 )
 
+// 评论嵌套
+const (
+	MaxCommentDepth       = 5           // 最大嵌套深度，超出时回复挂在最深一层的父评论下
+	DeletedCommentContent = "[deleted]" // 已删除且仍有回复的评论保留的占位内容
+)
+
+// 评论审核状态
+const (
+	CommentStatusPending  = "pending"  // 待审核，仅作者及审核员可见
+	CommentStatusApproved = "approved" // 已通过，所有人可见
+	CommentStatusRejected = "rejected" // 已拒绝，仅作者可见
+)
+
 // Comment 评论模型
 type Comment struct {
-	ID        uint      `gorm:"primarykey;autoIncrement" json:"id"`
-	Content   string    `gorm:"type:varchar(1000);not null" json:"content" binding:"required,max=1000"`
-	PostID    uint      `gorm:"index" json:"post_id"`
-	UserID    uint      `gorm:"index" json:"user_id"`
-	Post      Post      `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE" json:"post"`
-	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint      `gorm:"primarykey;autoIncrement" json:"id"`
+	Content     string    `gorm:"type:varchar(1000);not null" json:"content" binding:"required,max=1000"` // Markdown 源文本
+	ContentHTML string    `gorm:"type:text" json:"content_html"`                                          // 渲染并过滤后的 HTML
+	PostID      uint      `gorm:"index" json:"post_id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	ParentID    *uint     `gorm:"index" json:"parent_id"`                         // 父评论ID，顶层评论为空
+	Depth       int       `gorm:"not null;default:0" json:"depth"`                // 嵌套深度，顶层评论为 0
+	ReplyCount  int       `gorm:"not null;default:0" json:"reply_count"`          // 直接回复数
+	IsDeleted   bool      `gorm:"not null;default:false;index" json:"is_deleted"` // 已删除但因存在回复而保留为占位
+	Status      string    `gorm:"type:varchar(20);not null;default:approved;index" json:"status"`
+	TrainedAs   string    `gorm:"type:varchar(20);not null;default:''" json:"-"` // 已用于训练垃圾评论分类器的类别，未训练时为空
+	Post        Post      `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE" json:"post"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user"`
+	Replies     []Comment `gorm:"-" json:"replies,omitempty"` // 树形模式下的子评论
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // CreateCommentRequest 创建评论请求
 type CreateCommentRequest struct {
-	Content string `json:"content" binding:"required"`
-	PostID  uint   `json:"post_id" binding:"required"`
+	Content  string `json:"content" binding:"required"`
+	PostID   uint   `json:"post_id" binding:"required"`
+	ParentID *uint  `json:"parent_id"` // 回复的评论ID
+	Website  string `json:"website"`   // 蜜罐字段，前端隐藏，正常用户不会填写
+}
+
+// ListCommentsRequest 获取评论列表请求
+type ListCommentsRequest struct {
+	Mode     string `form:"mode" binding:"omitempty,oneof=list tree"` // list: 分页的顶层评论，tree: 完整评论树
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"pageSize,default=20" binding:"min=1,max=100"`
 }
 
 // UpdateCommentRequest 更新评论请求
 type UpdateCommentRequest struct {
 	Content string `json:"content" binding:"required"`
 }
+
+// ModerateCommentRequest 审核评论请求
+type ModerateCommentRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// BulkApproveCommentsRequest 批量通过评论请求
+type BulkApproveCommentsRequest struct {
+	IDs    []uint `json:"ids" binding:"required,min=1,max=100,dive,min=1"`
+	Reason string `json:"reason" binding:"max=500"`
+}
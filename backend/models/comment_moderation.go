@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// 审核操作
+const (
+	ModerationActionApprove = "approve"
+	ModerationActionReject  = "reject"
+	ModerationActionFlag    = "flag" // 垃圾内容检查判定为可疑，由系统转入人工审核
+	ModerationActionEdit    = "edit" // 已通过的评论被作者编辑，由系统转入人工审核
+)
+
+// CommentModeration 评论审核记录
+// 不设置外键，评论或审核员删除后仍保留审核记录
+type CommentModeration struct {
+	ID          uint      `gorm:"primarykey;autoIncrement" json:"id"`
+	CommentID   uint      `gorm:"index;not null" json:"comment_id"`
+	ModeratorID uint      `gorm:"index;not null" json:"moderator_id"` // 系统自动处理时为 0
+	Action      string    `gorm:"type:varchar(20);not null" json:"action"`
+	FromStatus  string    `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus    string    `gorm:"type:varchar(20);not null" json:"to_status"`
+	Reason      string    `gorm:"type:varchar(500)" json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 导出归档格式及版本，归档结构不兼容地变化时递增版本号
+const (
+	ExportFormat  = "keep-learning-blog"
+	ExportVersion = 4 // 版本 2 增加文章封面、slug 历史、修订历史和媒体记录，版本 3 增加用户邮箱验证时间，版本 4 增加角色是否要求两步验证
+)
+
+// 导出记录类型，按依赖顺序排列，恢复时依次处理
+const (
+	ExportTypeHeader         = "header"
+	ExportTypePermission     = "permission"
+	ExportTypeRole           = "role"
+	ExportTypeRolePermission = "role_permission"
+	ExportTypeUser           = "user"
+	ExportTypeUserRole       = "user_role"
+	ExportTypeTag            = "tag"
+	ExportTypePost           = "post"
+	ExportTypePostTag        = "post_tag"
+	ExportTypePostSlug       = "post_slug_history"
+	ExportTypePostRevision   = "post_revision"
+	ExportTypeMedia          = "media"
+	ExportTypeDerivative     = "media_derivative"
+	ExportTypeComment        = "comment"
+	ExportTypeFooter         = "footer"
+)
+
+// ExportRecord 导出归档中的一行
+type ExportRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ExportHeader 导出归档的第一行
+type ExportHeader struct {
+	Format         string    `json:"format"`
+	Version        int       `json:"version"`
+	ExportedAt     time.Time `json:"exported_at"`
+	PasswordHashes bool      `json:"password_hashes"` // 是否包含密码哈希
+}
+
+// ExportFooter 导出归档的最后一行，用于检查归档是否完整
+type ExportFooter struct {
+	Records int `json:"records"` // 归档头和结尾之间的记录数
+}
+
+// ExportPermission 导出的权限
+type ExportPermission struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Code        string    `json:"code"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Description string    `json:"description"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportRole 导出的角色
+type ExportRole struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Code        string    `json:"code"`
+	Description string    `json:"description"`
+	IsDefault   bool      `json:"is_default"`
+	RequireMFA  bool      `json:"require_mfa"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportRolePermission 导出的角色权限关联
+type ExportRolePermission struct {
+	RoleID       uint `json:"role_id"`
+	PermissionID uint `json:"permission_id"`
+}
+
+// ExportUser 导出的用户，默认不包含密码哈希
+type ExportUser struct {
+	ID           uint      `json:"id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Flagged      bool      `json:"flagged"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+}
+
+// ExportUserRole 导出的用户角色关联
+type ExportUserRole struct {
+	UserID uint `json:"user_id"`
+	RoleID uint `json:"role_id"`
+}
+
+// ExportTag 导出的标签
+type ExportTag struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// ExportPost 导出的文章，封面图片以归档中的媒体ID引用
+type ExportPost struct {
+	ID           uint       `json:"id"`
+	Title        string     `json:"title"`
+	Slug         string     `json:"slug"`
+	Content      string     `json:"content"`
+	ContentHTML  string     `json:"content_html"`
+	Status       string     `json:"status"`
+	PublishedAt  *time.Time `json:"published_at"`
+	ScheduledAt  *time.Time `json:"scheduled_at"`
+	CoverMediaID *uint      `json:"cover_media_id"`
+	UserID       uint       `json:"user_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ExportPostTag 导出的文章标签关联
+type ExportPostTag struct {
+	PostID uint `json:"post_id"`
+	TagID  uint `json:"tag_id"`
+}
+
+// ExportPostSlug 导出的文章历史 slug
+type ExportPostSlug struct {
+	PostID    uint      `json:"post_id"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportPostRevision 导出的文章修订版本
+type ExportPostRevision struct {
+	PostID    uint      `json:"post_id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Note      string    `json:"note"`
+	UserID    uint      `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportMedia 导出的媒体记录，文件本身不包含在归档中，需另行复制存储目录或存储桶
+type ExportMedia struct {
+	ID               uint      `json:"id"`
+	UserID           uint      `json:"user_id"`
+	PostID           *uint     `json:"post_id"`
+	Key              string    `json:"key"`
+	URL              string    `json:"url"`
+	Filename         string    `json:"filename"`
+	MimeType         string    `json:"mime_type"`
+	Size             int64     `json:"size"`
+	Width            int       `json:"width"`
+	Height           int       `json:"height"`
+	Checksum         string    `json:"checksum"`
+	DerivativeStatus string    `json:"derivative_status"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ExportDerivative 导出的图片衍生图记录
+type ExportDerivative struct {
+	MediaID uint   `json:"media_id"`
+	Variant string `json:"variant"`
+	Format  string `json:"format"`
+	Key     string `json:"key"`
+	URL     string `json:"url"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Size    int64  `json:"size"`
+}
+
+// ExportComment 导出的评论
+type ExportComment struct {
+	ID          uint      `json:"id"`
+	PostID      uint      `json:"post_id"`
+	UserID      uint      `json:"user_id"`
+	ParentID    *uint     `json:"parent_id"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	Depth       int       `json:"depth"`
+	ReplyCount  int       `json:"reply_count"`
+	IsDeleted   bool      `json:"is_deleted"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportSiteRequest 导出请求
+type ExportSiteRequest struct {
+	IncludePasswordHashes bool `form:"include_password_hashes"` // 显式指定时才导出密码哈希
+}
+
+// RestoreSummary 恢复结果，按记录类型统计
+type RestoreSummary struct {
+	Version int            `json:"version"`
+	Counts  map[string]int `json:"counts"`
+}
@@ -0,0 +1,48 @@
+package models
+
+// 导入条目的处理结果
+const (
+	ImportActionCreate    = "create"    // 新建文章
+	ImportActionUpdate    = "update"    // 更新已有文章
+	ImportActionUnchanged = "unchanged" // 内容未变化，跳过
+	ImportActionSkip      = "skip"      // 非文章内容，跳过
+	ImportActionError     = "error"     // 导入失败
+)
+
+// ImportPostsRequest 导入文章请求（multipart/form-data，文件字段为 file）
+type ImportPostsRequest struct {
+	DryRun bool `form:"dry_run"` // 仅生成导入报告，不写入数据库
+}
+
+// 导入条目类型（WordPress 导入）
+const (
+	ImportKindUser       = "user"
+	ImportKindTag        = "tag"
+	ImportKindPost       = "post"
+	ImportKindAttachment = "attachment"
+	ImportKindComment    = "comment"
+)
+
+// ImportItem 单个导入条目的结果
+type ImportItem struct {
+	File     string `json:"file,omitempty"`      // Markdown 导入时的文件路径
+	Kind     string `json:"kind,omitempty"`      // WordPress 导入时的条目类型
+	SourceID string `json:"source_id,omitempty"` // WordPress 中的 ID 或登录名
+	Slug     string `json:"slug,omitempty"`
+	Title    string `json:"title,omitempty"`
+	PostID   uint   `json:"post_id,omitempty"`
+	Action   string `json:"action"`
+	Message  string `json:"message,omitempty"` // 跳过原因或错误信息
+}
+
+// ImportReport 导入报告
+type ImportReport struct {
+	DryRun    bool         `json:"dry_run"`
+	Total     int          `json:"total"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Skipped   int          `json:"skipped"`
+	Failed    int          `json:"failed"`
+	Items     []ImportItem `json:"items"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// 衍生图处理状态
+const (
+	DerivativeStatusNone       = ""           // 非图片，无需处理
+	DerivativeStatusPending    = "pending"    // 等待处理
+	DerivativeStatusProcessing = "processing" // 处理中
+	DerivativeStatusReady      = "ready"      // 已生成
+	DerivativeStatusFailed     = "failed"     // 生成失败
+)
+
+// Media 上传的媒体文件
+type Media struct {
+	ID       uint   `gorm:"primarykey;autoIncrement" json:"id"`
+	UserID   uint   `gorm:"index;not null" json:"user_id"`
+	PostID   *uint  `gorm:"index" json:"post_id"` // 关联的文章，文章删除后置空
+	Key      string `gorm:"type:varchar(255);uniqueIndex;not null" json:"key"`
+	URL      string `gorm:"type:varchar(512);not null" json:"url"`
+	Filename string `gorm:"type:varchar(255)" json:"filename"` // 原始文件名，仅用于展示
+	MimeType string `gorm:"type:varchar(100);not null" json:"mime_type"`
+	Size     int64  `gorm:"not null" json:"size"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Checksum string `gorm:"type:char(64);index" json:"checksum"` // SHA-256
+	User     User   `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Post     *Post  `gorm:"foreignKey:PostID;constraint:OnDelete:SET NULL" json:"-"`
+
+	DerivativeStatus string            `gorm:"type:varchar(20);index" json:"derivative_status,omitempty"`
+	Derivatives      []MediaDerivative `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE" json:"-"`
+	Responsive       *ResponsiveImage  `gorm:"-" json:"responsive,omitempty"` // 供 srcset 使用的衍生图
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MediaDerivative 图片衍生图，与原图保存在同一目录
+type MediaDerivative struct {
+	ID      uint   `gorm:"primarykey;autoIncrement" json:"id"`
+	MediaID uint   `gorm:"not null;uniqueIndex:idx_media_derivatives_variant" json:"media_id"`
+	Variant string `gorm:"type:varchar(20);not null;uniqueIndex:idx_media_derivatives_variant" json:"variant"`
+	Format  string `gorm:"type:varchar(10);not null;uniqueIndex:idx_media_derivatives_variant" json:"format"` // webp/jpeg
+	Key     string `gorm:"type:varchar(255);not null" json:"key"`
+	URL     string `gorm:"type:varchar(512);not null" json:"url"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Size    int64  `json:"size"`
+}
+
+// ResponsiveImage 响应式图片，可直接用于 <picture> 的 <source> 和 <img srcset>
+type ResponsiveImage struct {
+	MediaID  uint          `json:"media_id"`
+	Src      string        `json:"src"` // 默认显示的图片
+	Width    int           `json:"width"`
+	Height   int           `json:"height"`
+	Sources  []ImageSource `json:"sources"`  // 按格式分组，优先使用 WebP
+	Original string        `json:"original"` // 原图地址
+}
+
+// ImageSource 同一格式不同宽度的衍生图
+type ImageSource struct {
+	Type   string `json:"type"`   // MIME 类型
+	Srcset string `json:"srcset"` // 如 "a_320.webp 320w, a_800.webp 800w"
+}
+
+// UploadMediaRequest 上传媒体文件请求（multipart/form-data，文件字段为 file）
+type UploadMediaRequest struct {
+	PostID *uint `form:"post_id"`
+}
+
+// AttachMediaRequest 关联媒体文件到文章请求，post_id 为空时取消关联
+type AttachMediaRequest struct {
+	PostID *uint `json:"post_id"`
+}
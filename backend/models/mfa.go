@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证恢复码，只保存哈希值，每个恢复码只能使用一次
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey;autoIncrement" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:char(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// MFAStatus 当前用户的两步验证状态
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at"`
+	Required               bool       `json:"required"` // 用户的角色是否要求两步验证
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
+
+// TOTPEnrollment 待确认的 TOTP 绑定信息
+type TOTPEnrollment struct {
+	Secret    string    `json:"secret"` // Base32 编码的密钥，供无法扫码时手动输入
+	URI       string    `json:"uri"`    // otpauth:// 配置 URI，由前端渲染为二维码
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MFALoginRequest 登录时提交两步验证码的请求
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
+
+// MFAEnrollRequest 登录时绑定验证器应用的请求
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFACodeRequest 提交 TOTP 验证码的请求
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
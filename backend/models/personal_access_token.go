@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// PersonalAccessToken 个人访问令牌，用于脚本等自动化场景调用 API
+// 令牌只保存哈希值，权限为用户角色权限与令牌范围的交集
+type PersonalAccessToken struct {
+	ID          uint         `gorm:"primarykey;autoIncrement" json:"id"`
+	UserID      uint         `gorm:"not null;index" json:"-"`
+	Name        string       `gorm:"type:varchar(100);not null" json:"name"`
+	TokenHash   string       `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	TokenPrefix string       `gorm:"type:varchar(16);not null" json:"token_prefix"` // 令牌开头的几位字符，便于用户辨认
+	ExpiresAt   *time.Time   `json:"expires_at"`                                    // 过期时间，为空表示永不过期
+	LastUsedAt  *time.Time   `json:"last_used_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Scopes      []string     `gorm:"-" json:"scopes"` // 令牌范围内的权限编码，仅用于响应
+	Permissions []Permission `gorm:"many2many:personal_access_token_permissions;constraint:OnDelete:CASCADE" json:"-"`
+	User        User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// CreatePersonalAccessTokenRequest 创建个人访问令牌请求
+type CreatePersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"` // 权限编码，必须是用户当前拥有的权限
+	ExpiresAt *time.Time `json:"expires_at"`
+}
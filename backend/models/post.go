@@ -4,28 +4,71 @@ import (
 	"time"
 )
 
+// 文章状态
+const (
+	PostStatusDraft     = "draft"     // 草稿，仅作者及拥有权限的角色可见
+	PostStatusScheduled = "scheduled" // 定时发布，到达发布时间前与草稿相同
+	PostStatusPublished = "published" // 已发布，所有人可见
+	PostStatusArchived  = "archived"  // 已归档，不出现在文章列表中
+)
+
 // Post 文章模型
 type Post struct {
-	ID        uint      `gorm:"primarykey;autoIncrement" json:"id"`
-	Title     string    `gorm:"type:varchar(200);not null;index" json:"title" binding:"required,max=200"`
-	Content   string    `gorm:"type:text" json:"content"`
-	UserID    uint      `gorm:"index" json:"user_id"`
-	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user"`
-	Tags      []Tag     `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE" json:"tags"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint              `gorm:"primarykey;autoIncrement" json:"id"`
+	Title        string            `gorm:"type:varchar(200);not null;index" json:"title" binding:"required,max=200"`
+	Slug         string            `gorm:"type:varchar(255);uniqueIndex" json:"slug"`
+	Content      string            `gorm:"type:text" json:"content"`      // Markdown 源文本
+	ContentHTML  string            `gorm:"type:text" json:"content_html"` // 渲染并过滤后的 HTML
+	Status       string            `gorm:"type:varchar(20);not null;default:published;index" json:"status"`
+	PublishedAt  *time.Time        `gorm:"index" json:"published_at"`
+	ScheduledAt  *time.Time        `gorm:"index" json:"scheduled_at,omitempty"`
+	CoverMediaID *uint             `gorm:"index" json:"cover_media_id"` // 封面图片
+	UserID       uint              `gorm:"index" json:"user_id"`
+	User         User              `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user"`
+	Tags         []Tag             `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE" json:"tags"`
+	CoverImage   *ResponsiveImage  `gorm:"-" json:"cover_image,omitempty"` // 封面图片的衍生图
+	Images       []ResponsiveImage `gorm:"-" json:"images,omitempty"`      // 正文图片的衍生图，仅在获取单篇文章时返回
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
 }
 
 // CreatePostRequest 创建文章请求
 type CreatePostRequest struct {
-	Title    string   `json:"title" binding:"required"`
-	Content  string   `json:"content" binding:"required"`
-	TagNames []string `json:"tagNames"`
+	Title        string     `json:"title" binding:"required"`
+	Content      string     `json:"content" binding:"required"`
+	TagNames     []string   `json:"tagNames"`
+	Status       string     `json:"status" binding:"omitempty,oneof=draft published"` // 为空时直接发布，保存草稿需显式传 draft
+	PublishAt    *time.Time `json:"publish_at"`                                       // 定时发布时间，设置后忽略 status
+	CoverMediaID *uint      `json:"cover_media_id"`                                   // 封面图片，需为当前用户上传的图片
 }
 
 // UpdatePostRequest 更新文章请求
 type UpdatePostRequest struct {
-	Title    string   `json:"title" binding:"required"`
-	Content  string   `json:"content" binding:"required"`
-	TagNames []string `json:"tagNames"`
+	Title        string     `json:"title" binding:"required"`
+	Content      string     `json:"content" binding:"required"`
+	TagNames     []string   `json:"tagNames"`
+	PublishAt    *time.Time `json:"publish_at"`     // 定时发布时间
+	CoverMediaID *uint      `json:"cover_media_id"` // 封面图片，为空时移除封面
+}
+
+// PreviewMarkdownRequest 预览 Markdown 渲染结果请求
+type PreviewMarkdownRequest struct {
+	Content string `json:"content" binding:"required,max=100000"`
+}
+
+// SearchPostsRequest 搜索文章请求
+type SearchPostsRequest struct {
+	Query    string     `form:"q" binding:"max=100"`
+	Tags     []string   `form:"tags"` // 支持多次传参或逗号分隔
+	From     *time.Time `form:"from" time_format:"2006-01-02"`
+	To       *time.Time `form:"to" time_format:"2006-01-02"`
+	Page     int        `form:"page,default=1" binding:"min=1"`
+	PageSize int        `form:"pageSize,default=10" binding:"min=1,max=50"`
+}
+
+// PostSearchResult 文章搜索结果
+type PostSearchResult struct {
+	Post    Post    `json:"post"`
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"` // 使用 <mark> 标记命中词的摘要
 }
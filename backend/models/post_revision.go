@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// PostRevision 文章修订版本模型
+type PostRevision struct {
+	ID        uint      `gorm:"primarykey;autoIncrement" json:"id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_post_revisions_post_version" json:"post_id"`
+	Version   int       `gorm:"not null;uniqueIndex:idx_post_revisions_post_version" json:"version"`
+	Title     string    `gorm:"type:varchar(200);not null" json:"title"`
+	Content   string    `gorm:"type:text" json:"content"`
+	Note      string    `gorm:"type:varchar(255)" json:"note"`
+	UserID    uint      `gorm:"index" json:"user_id"` // 编辑者
+	Post      Post      `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE" json:"-"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
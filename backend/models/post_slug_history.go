@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// PostSlugHistory 文章历史 slug 模型，用于标题修改后旧链接的重定向
+type PostSlugHistory struct {
+	ID        uint      `gorm:"primarykey;autoIncrement" json:"id"`
+	PostID    uint      `gorm:"not null;index" json:"post_id"`
+	Slug      string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"slug"`
+	Post      Post      `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
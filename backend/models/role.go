@@ -11,6 +11,7 @@ type Role struct {
 	Code        string       `gorm:"type:varchar(50);not null;unique" json:"code" binding:"required,max=50"`
 	Description string       `gorm:"type:text" json:"description"`
 	IsDefault   bool         `gorm:"default:false" json:"is_default,omitempty"`
+	RequireMFA  bool         `gorm:"column:require_mfa;default:false" json:"require_mfa"` // 拥有该角色的用户必须启用两步验证才能登录
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE" json:"permissions,omitempty"`
@@ -24,6 +25,7 @@ type CreateRoleRequest struct {
 	Description   string `json:"description" binding:"required"`
 	PermissionIDs []uint `json:"permission_ids" binding:"required"`
 	IsDefault     *bool  `json:"is_default"`
+	RequireMFA    *bool  `json:"require_mfa"`
 }
 
 // UpdateRoleRequest 更新角色请求
@@ -32,6 +34,7 @@ type UpdateRoleRequest struct {
 	Code        string `json:"code" binding:"required"`
 	Description string `json:"description" binding:"required"`
 	IsDefault   *bool  `json:"is_default"`
+	RequireMFA  *bool  `json:"require_mfa"`
 }
 
 // UpdatePermissionsRequest 更新角色权限请求
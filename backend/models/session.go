@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session 登录会话，保存在 Redis 中，每次登录创建一个会话
+// 会话的访问令牌和刷新令牌均携带会话 ID，会话被撤销后两者同时失效
+type Session struct {
+	ID             string    `json:"id"`
+	UserID         uint      `json:"-"`
+	Device         string    `json:"device"`     // 根据 User-Agent 识别的设备描述
+	UserAgent      string    `json:"user_agent"` // 登录时的 User-Agent
+	IP             string    `json:"ip"`         // 最近一次请求的 IP
+	CreatedAt      time.Time `json:"created_at"`
+	LastSeen       time.Time `json:"last_seen"`
+	AccessTokenID  string    `json:"-"`       // 当前有效的访问令牌 ID
+	RefreshTokenID string    `json:"-"`       // 当前有效的刷新令牌 ID
+	Current        bool      `json:"current"` // 是否为发起请求的会话，仅用于响应
+}
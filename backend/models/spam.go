@@ -0,0 +1,20 @@
+package models
+
+// 贝叶斯分类样本类别
+const (
+	SpamClassSpam = "spam"
+	SpamClassHam  = "ham"
+)
+
+// SpamToken 贝叶斯垃圾内容分类器的词频，记录包含该词的垃圾和正常样本数
+type SpamToken struct {
+	Token     string `gorm:"type:varchar(64);primarykey" json:"token"`
+	SpamCount int64  `gorm:"not null;default:0" json:"spam_count"`
+	HamCount  int64  `gorm:"not null;default:0" json:"ham_count"`
+}
+
+// SpamClassStat 贝叶斯垃圾内容分类器各类别的样本总数
+type SpamClassStat struct {
+	Class     string `gorm:"type:varchar(20);primarykey" json:"class"`
+	Documents int64  `gorm:"not null;default:0" json:"documents"`
+}
@@ -10,9 +10,15 @@ type User struct {
 	Username  string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"username" binding:"required,max=64"`
 	Password  string    `gorm:"type:varchar(255);not null" json:"-" binding:"required,max=255"`
 	Email     string    `gorm:"type:varchar(128);uniqueIndex;not null" json:"email" binding:"required,email,max=128"`
+	Flagged   bool      `gorm:"not null;default:false" json:"-"` // 注册时被判定为可疑，评论需人工审核
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Roles     []Role    `gorm:"many2many:user_roles;constraint:OnDelete:CASCADE" json:"roles,omitempty"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"` // 邮箱验证时间，为空表示未验证，未验证的用户不能发表文章和评论
+
+	TOTPSecret    string     `gorm:"column:totp_secret;type:varchar(255)" json:"-"` // 加密保存的 TOTP 密钥
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at"` // 启用两步验证的时间，为空表示未启用
 }
 
 // RegisterRequest 注册请求
@@ -20,6 +26,7 @@ type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
+	Website  string `json:"website"` // 蜜罐字段，前端隐藏，正常用户不会填写
 }
 
 // CreateUserRequest 创建用户请求
@@ -44,6 +51,22 @@ type UpdateUserRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 }
 
+// VerifyEmailRequest 验证邮箱请求
+type VerifyEmailRequest struct {
+	Token string `form:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 找回密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 // UpdateUserRolesRequest 更新用户角色请求
 type UpdateUserRolesRequest struct {
 	RoleID uint `json:"role_id" binding:"required"`
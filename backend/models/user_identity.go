@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UserIdentity 用户在外部身份提供方的身份，通过 OpenID Connect 登录时按提供方和 subject 查找用户
+type UserIdentity struct {
+	ID          uint      `gorm:"primarykey;autoIncrement" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	Provider    string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_provider_subject" json:"subject"` // ID Token 中的 sub
+	Email       string    `gorm:"type:varchar(128)" json:"email"`                                                             // 最近一次登录时身份提供方返回的邮箱
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// OIDCCallbackRequest 身份提供方授权回调参数
+type OIDCCallbackRequest struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// OIDCExchangeRequest 兑换登录兑换码请求
+type OIDCExchangeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
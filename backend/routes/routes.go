@@ -4,19 +4,32 @@ import (
 	"keep_learning_blog/api"
 	"keep_learning_blog/config"
 	"keep_learning_blog/middleware"
+	"keep_learning_blog/service"
+	"keep_learning_blog/utils/mail"
+	"keep_learning_blog/utils/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes 设置路由
-func SetupRoutes(r *gin.Engine, cfg *config.Config) {
+func SetupRoutes(r *gin.Engine, cfg *config.Config, backend storage.Backend, imageProcessor *service.ImageProcessor, mailer mail.Sender) {
 
-	userController := api.NewUserController(cfg)
-	postController := api.NewPostController()
-	commentController := api.NewCommentController()
+	userController := api.NewUserController(cfg, mailer)
+	postController := api.NewPostController(cfg)
+	commentController := api.NewCommentController(cfg)
 	tagController := api.NewTagController()
 	roleController := api.NewRoleController(cfg)
 	permissionController := api.NewPermissionController()
+	mediaController := api.NewMediaController(cfg, backend, imageProcessor)
+	feedController := api.NewFeedController(cfg)
+	sitemapController := api.NewSitemapController(cfg)
+	importController := api.NewImportController(cfg)
+	backupController := api.NewBackupController()
+	sessionController := api.NewSessionController()
+	mfaController := api.NewMFAController(cfg)
+	oidcController := api.NewOIDCController(cfg)
+	personalAccessTokenController := api.NewPersonalAccessTokenController(cfg)
+	jwksController := api.NewJWKSController()
 
 	loginLimiter := middleware.NewLoginLimiter(cfg)
 	rateLimiter := middleware.NewRateLimiter(cfg)
@@ -37,9 +50,41 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 	// XSS防护
 	r.Use(middleware.XSSProtection())
 
+	// 本地存储的上传文件
+	if cfg.Storage.Backend == config.StorageBackendLocal {
+		r.Static(cfg.Storage.Local.BaseURL, cfg.Storage.Local.Root)
+	}
+
+	// 订阅源、站点地图及 robots.txt
+	feeds := r.Group("")
+	feeds.Use(rateLimiter.PublicAPILimit())
+	{
+		feeds.GET("/feed.xml", feedController.RSS)   // 全站 RSS 2.0
+		feeds.GET("/atom.xml", feedController.Atom)  // 全站 Atom
+		feeds.GET("/feed.json", feedController.JSON) // 全站 JSON Feed
+
+		feeds.GET("/tags/:name/feed.xml", feedController.TagRSS)   // 指定标签 RSS 2.0
+		feeds.GET("/tags/:name/atom.xml", feedController.TagAtom)  // 指定标签 Atom
+		feeds.GET("/tags/:name/feed.json", feedController.TagJSON) // 指定标签 JSON Feed
+
+		feeds.GET("/authors/:username/feed.xml", feedController.AuthorRSS)   // 指定作者 RSS 2.0
+		feeds.GET("/authors/:username/atom.xml", feedController.AuthorAtom)  // 指定作者 Atom
+		feeds.GET("/authors/:username/feed.json", feedController.AuthorJSON) // 指定作者 JSON Feed
+
+		feeds.GET("/sitemap.xml", sitemapController.GetSitemap)        // 站点地图或站点地图索引
+		feeds.GET("/sitemaps/:file", sitemapController.GetSitemapPage) // 站点地图分页
+		feeds.GET("/robots.txt", sitemapController.GetRobots)          // robots.txt
+	}
+
+	// 令牌签名公钥
+	r.GET("/.well-known/jwks.json", rateLimiter.PublicAPILimit(), jwksController.GetJWKS)
+
 	// API 版本控制
 	v1 := r.Group("/api")
 
+	// cookie 模式下的 CSRF 防护
+	v1.Use(middleware.CSRF(&cfg.JWT))
+
 	// 博客
 	blog := v1.Group("")
 	{
@@ -50,14 +95,28 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 		public.Use(rateLimiter.PublicAPILimit())
 		{
 			// 用户相关
-			public.POST("/register", userController.Register)                                                     //注册
-			public.POST("/login", middleware.AuditLog(), loginLimiter.CheckLoginAttempts(), userController.Login) //登录（审计日志/限制登录次数）
-			public.POST("/refresh", userController.RefreshToken)                                                  //刷新token
-
-			// 文章相关
-			public.GET("/posts", postController.GetAllPosts)                  // 获取所有文章
-			public.GET("/posts/:id", postController.GetPost)                  // 获取指定文章
-			public.GET("/posts/:id/comments", postController.GetPostComments) // 获取指定文章评论
+			public.POST("/register", userController.Register)                                                       //注册
+			public.POST("/login", middleware.AuditLog(), loginLimiter.CheckLoginAttempts(), userController.Login)   //登录（审计日志/限制登录次数）
+			public.POST("/login/mfa", rateLimiter.AuthAPILimit(), middleware.AuditLog(), mfaController.VerifyLogin) //两步验证登录（认证接口限流/审计日志）
+			public.POST("/login/mfa/enroll", rateLimiter.AuthAPILimit(), mfaController.EnrollLogin)                 //登录时绑定验证器应用（认证接口限流）
+			public.POST("/refresh", userController.RefreshToken)                                                    //刷新token
+			public.GET("/verify-email", userController.VerifyEmail)                                                 //验证邮箱
+			public.POST("/forgot-password", rateLimiter.AuthAPILimit(), userController.ForgotPassword)              //找回密码（认证接口限流）
+			public.POST("/reset-password", rateLimiter.AuthAPILimit(), userController.ResetPassword)                //重置密码（认证接口限流）
+
+			// 第三方登录相关
+			public.GET("/auth/oidc/providers", oidcController.GetProviders)                                                //获取已配置的身份提供方
+			public.GET("/auth/oidc/:provider/login", oidcController.Login)                                                 //跳转到身份提供方登录
+			public.GET("/auth/oidc/:provider/callback", middleware.AuditLog(), oidcController.Callback)                    //身份提供方登录回调，跳转到前端并附加登录兑换码（审计日志）
+			public.POST("/auth/oidc/exchange", rateLimiter.AuthAPILimit(), middleware.AuditLog(), oidcController.Exchange) //兑换登录兑换码（认证接口限流/审计日志）
+
+			// 文章相关（可选认证，用于判断草稿可见性）
+			public.GET("/posts", tokenAuther.OptionalTokenAuth(), postController.GetAllPosts)                                        // 获取所有文章
+			public.GET("/posts/search", postController.SearchPosts)                                                                  // 全文搜索文章
+			public.GET("/posts/by-slug/:slug", tokenAuther.OptionalTokenAuth(), postController.GetPostBySlug)                        // 根据 slug 获取文章
+			public.GET("/posts/:id", tokenAuther.OptionalTokenAuth(), postController.GetPost)                                        // 获取指定文章
+			public.GET("/posts/:id/comments", tokenAuther.OptionalTokenAuth(), postController.GetPostComments)                       // 获取指定文章评论
+			public.GET("/posts/:id/comments/:comment_id/replies", tokenAuther.OptionalTokenAuth(), postController.GetCommentReplies) // 获取指定评论的回复
 
 			// 标签相关
 			public.GET("/tags", tagController.GetAllTags) // 获取所有标签
@@ -72,7 +131,30 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 		private.Use(tokenAuther.TokenAuth())
 		{
 			// 用户相关
-			private.POST("/logout", middleware.AuditLog(), userController.Logout) // 退出登录
+			private.POST("/logout", middleware.AuditLog(), userController.Logout)                                          // 退出登录
+			private.POST("/verify-email/resend", rateLimiter.VerificationResendLimit(), userController.ResendVerification) // 重发验证邮件
+
+			// 账户安全相关，仅限登录会话，个人访问令牌不能访问
+			account := private.Group("")
+			account.Use(middleware.RequireSession())
+			{
+				// 登录会话相关
+				account.GET("/me/sessions", sessionController.GetSessions)                                 // 获取当前用户的登录会话
+				account.DELETE("/me/sessions", middleware.AuditLog(), sessionController.RevokeAllSessions) // 在所有设备上退出登录
+				account.DELETE("/me/sessions/:id", middleware.AuditLog(), sessionController.RevokeSession) // 撤销指定会话
+
+				// 两步验证相关
+				account.GET("/me/mfa", mfaController.GetStatus)                                                      // 获取当前用户的两步验证状态
+				account.POST("/me/mfa/totp", middleware.AuditLog(), mfaController.BeginEnrollment)                   // 生成 TOTP 密钥
+				account.POST("/me/mfa/totp/confirm", middleware.AuditLog(), mfaController.ConfirmEnrollment)         // 确认绑定并启用两步验证
+				account.DELETE("/me/mfa/totp", middleware.AuditLog(), mfaController.Disable)                         // 停用两步验证
+				account.POST("/me/mfa/recovery-codes", middleware.AuditLog(), mfaController.RegenerateRecoveryCodes) // 重新生成恢复码
+
+				// 个人访问令牌相关
+				account.GET("/me/tokens", personalAccessTokenController.GetTokens)                                 // 获取当前用户的个人访问令牌
+				account.POST("/me/tokens", middleware.AuditLog(), personalAccessTokenController.CreateToken)       // 创建个人访问令牌
+				account.DELETE("/me/tokens/:id", middleware.AuditLog(), personalAccessTokenController.RevokeToken) // 撤销个人访问令牌
+			}
 
 			// RBAC 认证
 			private.Use(middleware.RBACAuth(cfg))
@@ -122,18 +204,47 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 				private.DELETE("/tag/:id", tagController.DeleteTag) // 删除指定标签
 
 				// 文章相关
-				private.POST("/post", postController.CreatePost) //创建文章
+				private.POST("/post", middleware.RequireVerifiedEmail(), postController.CreatePost) //创建文章（需验证邮箱）
+				private.POST("/post/preview", postController.PreviewMarkdown)                       //预览 Markdown 渲染结果
 
-				private.PUT("/post/:id", postController.UpdatePost) //编辑指定文章
+				private.PUT("/post/:id", postController.UpdatePost)              //编辑指定文章
+				private.PUT("/post/:id/publish", postController.PublishPost)     //发布指定文章
+				private.PUT("/post/:id/unpublish", postController.UnpublishPost) //撤回指定文章
+				private.PUT("/post/:id/archive", postController.ArchivePost)     //归档指定文章
+
+				private.GET("/post/:id/revisions", postController.GetPostRevisions)                      //获取指定文章修订历史
+				private.GET("/post/:id/revisions/diff", postController.DiffPostRevisions)                //比较指定文章修订版本
+				private.POST("/post/:id/revisions/:version/restore", postController.RestorePostRevision) //恢复指定文章修订版本
 
 				private.DELETE("/post/:id", postController.DeletePost) //删除指定文章
 
+				private.POST("/posts/import", importController.ImportMarkdown) //从 Hugo/Jekyll Markdown 压缩包导入文章
+
 				// 评论相关
-				private.POST("/comment", commentController.CreateComment) //创建评论
+				private.POST("/comment", middleware.RequireVerifiedEmail(), commentController.CreateComment) //创建评论（需验证邮箱）
 
 				private.PUT("/comment/:id", commentController.UpdateComment) //编辑指定评论
 
 				private.DELETE("/comment/:id", commentController.DeleteComment) //删除指定评论
+
+				// 评论审核
+				private.GET("/comments/pending", commentController.ListPendingComments)          //查看待审核评论
+				private.POST("/comments/approve", commentController.BulkApproveComments)         //批量通过评论
+				private.PUT("/comment/:id/approve", commentController.ApproveComment)            //通过指定评论
+				private.PUT("/comment/:id/reject", commentController.RejectComment)              //拒绝指定评论
+				private.GET("/comment/:id/moderations", commentController.GetCommentModerations) //查看指定评论审核记录
+
+				// 媒体文件相关
+				private.POST("/media", mediaController.UploadMedia) //上传媒体文件
+
+				private.GET("/media", mediaController.GetUserMedia) //获取当前用户媒体文件
+
+				private.PUT("/media/:id", mediaController.AttachMedia) //关联媒体文件到文章
+
+				private.DELETE("/media/:id", mediaController.DeleteMedia) //删除指定媒体文件
+
+				// 全站导出
+				private.GET("/export", backupController.ExportSite) //导出全站数据
 			}
 		}
 
@@ -0,0 +1,618 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/logger"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize 导出时每批读取的记录数
+const exportBatchSize = 500
+
+// BackupService 全站导出与恢复服务结构体
+type BackupService struct{}
+
+// ExportSite 将权限、角色、用户、标签、文章、文章历史、媒体记录和评论以 JSON Lines 格式写入 w (select)
+// 第一行为带版本号的归档头，之后按依赖顺序输出各类记录，最后一行记录总数；密码哈希仅在 includePasswordHashes 为 true 时导出
+// 导出在只读的可重复读事务中进行，保证各表数据一致
+func (s *BackupService) ExportSite(ctx context.Context, w io.Writer, includePasswordHashes bool) error {
+	encoder := json.NewEncoder(w)
+	records := 0
+	write := func(recordType string, data interface{}) error {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if recordType != models.ExportTypeHeader {
+			records++
+		}
+		return encoder.Encode(models.ExportRecord{Type: recordType, Data: raw})
+	}
+
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := write(models.ExportTypeHeader, models.ExportHeader{
+			Format:         models.ExportFormat,
+			Version:        models.ExportVersion,
+			ExportedAt:     time.Now(),
+			PasswordHashes: includePasswordHashes,
+		}); err != nil {
+			return err
+		}
+
+		// 权限
+		var permissions []models.Permission
+		if err := tx.FindInBatches(&permissions, exportBatchSize, func(*gorm.DB, int) error {
+			for _, p := range permissions {
+				if err := write(models.ExportTypePermission, models.ExportPermission{
+					ID: p.ID, Name: p.Name, Code: p.Code, Method: p.Method, Path: p.Path,
+					Description: p.Description, IsDefault: p.IsDefault, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+
+		// 角色及角色权限关联
+		var roles []models.Role
+		if err := tx.FindInBatches(&roles, exportBatchSize, func(*gorm.DB, int) error {
+			for _, r := range roles {
+				if err := write(models.ExportTypeRole, models.ExportRole{
+					ID: r.ID, Name: r.Name, Code: r.Code, Description: r.Description,
+					IsDefault: r.IsDefault, RequireMFA: r.RequireMFA, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+		if err := exportLinks(tx, "SELECT role_id, permission_id FROM role_permissions ORDER BY role_id, permission_id",
+			func(roleID, permissionID uint) error {
+				return write(models.ExportTypeRolePermission, models.ExportRolePermission{RoleID: roleID, PermissionID: permissionID})
+			}); err != nil {
+			return err
+		}
+
+		// 用户及用户角色关联
+		var users []models.User
+		if err := tx.FindInBatches(&users, exportBatchSize, func(*gorm.DB, int) error {
+			for _, u := range users {
+				record := models.ExportUser{
+					ID: u.ID, Username: u.Username, Email: u.Email, Flagged: u.Flagged,
+					CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, EmailVerifiedAt: u.EmailVerifiedAt,
+				}
+				if includePasswordHashes {
+					record.PasswordHash = u.Password
+				}
+				if err := write(models.ExportTypeUser, record); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+		if err := exportLinks(tx, "SELECT user_id, role_id FROM user_roles ORDER BY user_id, role_id",
+			func(userID, roleID uint) error {
+				return write(models.ExportTypeUserRole, models.ExportUserRole{UserID: userID, RoleID: roleID})
+			}); err != nil {
+			return err
+		}
+
+		// 标签
+		var tags []models.Tag
+		if err := tx.FindInBatches(&tags, exportBatchSize, func(*gorm.DB, int) error {
+			for _, t := range tags {
+				if err := write(models.ExportTypeTag, models.ExportTag{ID: t.ID, Name: t.Name}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+
+		// 文章及文章标签关联
+		var posts []models.Post
+		if err := tx.FindInBatches(&posts, exportBatchSize, func(*gorm.DB, int) error {
+			for _, p := range posts {
+				if err := write(models.ExportTypePost, models.ExportPost{
+					ID: p.ID, Title: p.Title, Slug: p.Slug, Content: p.Content, ContentHTML: p.ContentHTML,
+					Status: p.Status, PublishedAt: p.PublishedAt, ScheduledAt: p.ScheduledAt, CoverMediaID: p.CoverMediaID,
+					UserID: p.UserID, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+		if err := exportLinks(tx, "SELECT post_id, tag_id FROM post_tags ORDER BY post_id, tag_id",
+			func(postID, tagID uint) error {
+				return write(models.ExportTypePostTag, models.ExportPostTag{PostID: postID, TagID: tagID})
+			}); err != nil {
+			return err
+		}
+
+		// 文章历史 slug 及修订历史
+		var slugs []models.PostSlugHistory
+		if err := tx.FindInBatches(&slugs, exportBatchSize, func(*gorm.DB, int) error {
+			for _, h := range slugs {
+				if err := write(models.ExportTypePostSlug, models.ExportPostSlug{
+					PostID: h.PostID, Slug: h.Slug, CreatedAt: h.CreatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+		var revisions []models.PostRevision
+		if err := tx.FindInBatches(&revisions, exportBatchSize, func(*gorm.DB, int) error {
+			for _, r := range revisions {
+				if err := write(models.ExportTypePostRevision, models.ExportPostRevision{
+					PostID: r.PostID, Version: r.Version, Title: r.Title, Content: r.Content,
+					Note: r.Note, UserID: r.UserID, CreatedAt: r.CreatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+
+		// 媒体及衍生图记录，文件本身不导出
+		var media []models.Media
+		if err := tx.FindInBatches(&media, exportBatchSize, func(*gorm.DB, int) error {
+			for _, m := range media {
+				if err := write(models.ExportTypeMedia, models.ExportMedia{
+					ID: m.ID, UserID: m.UserID, PostID: m.PostID, Key: m.Key, URL: m.URL, Filename: m.Filename,
+					MimeType: m.MimeType, Size: m.Size, Width: m.Width, Height: m.Height, Checksum: m.Checksum,
+					DerivativeStatus: m.DerivativeStatus, CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+		var derivatives []models.MediaDerivative
+		if err := tx.FindInBatches(&derivatives, exportBatchSize, func(*gorm.DB, int) error {
+			for _, d := range derivatives {
+				if err := write(models.ExportTypeDerivative, models.ExportDerivative{
+					MediaID: d.MediaID, Variant: d.Variant, Format: d.Format, Key: d.Key, URL: d.URL,
+					Width: d.Width, Height: d.Height, Size: d.Size,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+
+		// 评论，按 ID 顺序导出，保证父评论先于回复
+		var comments []models.Comment
+		if err := tx.FindInBatches(&comments, exportBatchSize, func(*gorm.DB, int) error {
+			for _, c := range comments {
+				if err := write(models.ExportTypeComment, models.ExportComment{
+					ID: c.ID, PostID: c.PostID, UserID: c.UserID, ParentID: c.ParentID,
+					Content: c.Content, ContentHTML: c.ContentHTML, Depth: c.Depth, ReplyCount: c.ReplyCount,
+					IsDeleted: c.IsDeleted, Status: c.Status, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error; err != nil {
+			return err
+		}
+
+		return write(models.ExportTypeFooter, models.ExportFooter{Records: records})
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// exportLinks 逐行导出多对多关联表
+func exportLinks(tx *gorm.DB, query string, fn func(a, b uint) error) error {
+	rows, err := tx.Raw(query).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a, b uint
+		if err := rows.Scan(&a, &b); err != nil {
+			return err
+		}
+		if err := fn(a, b); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// restoreState 恢复过程中归档 ID 到新 ID 的映射
+type restoreState struct {
+	version int // 归档版本
+
+	permissions map[uint]uint
+	roles       map[uint]uint
+	users       map[uint]uint
+	tags        map[uint]uint
+	posts       map[uint]uint
+	media       map[uint]uint
+	comments    map[uint]uint
+
+	covers map[uint]uint // 新文章ID -> 归档中的封面媒体ID，媒体恢复后统一设置
+}
+
+// RestoreSite 从 ExportSite 生成的归档恢复数据 (insert)
+// 目标库需为刚初始化的空库（可以包含初始化的权限、角色和管理员），所有记录重新分配 ID，
+// 权限和角色按编码、用户按用户名或邮箱与初始化数据合并，并以归档中的关联替换其角色权限和用户角色
+// 归档不含密码哈希时，新建用户的密码为随机值，需通过找回密码重新设置
+// 媒体只恢复数据库记录，存储键保持不变，上传目录或存储桶需另行复制
+func (s *BackupService) RestoreSite(r io.Reader) (*models.RestoreSummary, error) {
+	decoder := json.NewDecoder(r)
+
+	// 检查归档头
+	var record models.ExportRecord
+	if err := decoder.Decode(&record); err != nil {
+		return nil, fmt.Errorf("invalid archive header: %w", err)
+	}
+	var header models.ExportHeader
+	if record.Type != models.ExportTypeHeader || json.Unmarshal(record.Data, &header) != nil || header.Format != models.ExportFormat {
+		return nil, errors.New("not a keep-learning-blog export archive")
+	}
+	if header.Version < 1 || header.Version > models.ExportVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", header.Version)
+	}
+
+	summary := &models.RestoreSummary{Version: header.Version, Counts: map[string]int{}}
+	state := &restoreState{
+		version:     header.Version,
+		permissions: map[uint]uint{},
+		roles:       map[uint]uint{},
+		users:       map[uint]uint{},
+		tags:        map[uint]uint{},
+		posts:       map[uint]uint{},
+		media:       map[uint]uint{},
+		comments:    map[uint]uint{},
+		covers:      map[uint]uint{},
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// 拒绝恢复到已有内容的数据库
+		var count int64
+		if err := tx.Model(&models.Post{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("target database already contains posts, restore requires a fresh database")
+		}
+
+		for line, records := 2, 0; ; line, records = line+1, records+1 {
+			record = models.ExportRecord{}
+			if err := decoder.Decode(&record); err == io.EOF {
+				return errors.New("archive is truncated, footer not found")
+			} else if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+
+			// 结尾记录数一致时恢复完成
+			if record.Type == models.ExportTypeFooter {
+				var footer models.ExportFooter
+				if err := json.Unmarshal(record.Data, &footer); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+				if footer.Records != records {
+					return fmt.Errorf("archive is incomplete: expected %d records, got %d", footer.Records, records)
+				}
+				return state.restoreCovers(tx)
+			}
+
+			if err := state.restore(tx, &record); err != nil {
+				return fmt.Errorf("line %d (%s): %w", line, record.Type, err)
+			}
+			summary.Counts[record.Type]++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateContentCache()
+	logger.Log.WithField("counts", summary.Counts).Info("Site restored successfully")
+	return summary, nil
+}
+
+// restore 恢复单条记录
+func (st *restoreState) restore(tx *gorm.DB, record *models.ExportRecord) error {
+	switch record.Type {
+	case models.ExportTypePermission:
+		var data models.ExportPermission
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		var permission models.Permission
+		if err := tx.Where("code = ?", data.Code).Attrs(models.Permission{CreatedAt: data.CreatedAt}).
+			FirstOrInit(&permission).Error; err != nil {
+			return err
+		}
+		permission.Name, permission.Method, permission.Path = data.Name, data.Method, data.Path
+		permission.Code, permission.Description, permission.IsDefault = data.Code, data.Description, data.IsDefault
+		if err := tx.Save(&permission).Error; err != nil {
+			return err
+		}
+		st.permissions[data.ID] = permission.ID
+
+	case models.ExportTypeRole:
+		var data models.ExportRole
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		var role models.Role
+		if err := tx.Where("code = ?", data.Code).Attrs(models.Role{CreatedAt: data.CreatedAt}).
+			FirstOrInit(&role).Error; err != nil {
+			return err
+		}
+		role.Name, role.Code, role.Description, role.IsDefault = data.Name, data.Code, data.Description, data.IsDefault
+		// 版本 3 之前的归档不包含两步验证要求，保留现有角色的设置
+		if st.version >= 3 {
+			role.RequireMFA = data.RequireMFA
+		}
+		if err := tx.Save(&role).Error; err != nil {
+			return err
+		}
+		// 角色权限以归档为准
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		st.roles[data.ID] = role.ID
+
+	case models.ExportTypeRolePermission:
+		var data models.ExportRolePermission
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		roleID, permissionID := st.roles[data.RoleID], st.permissions[data.PermissionID]
+		if roleID == 0 || permissionID == 0 {
+			return errors.New("role or permission not found in archive")
+		}
+		return tx.Exec("INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			roleID, permissionID).Error
+
+	case models.ExportTypeUser:
+		var data models.ExportUser
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		var user models.User
+		err := tx.Where("username = ? OR email = ?", data.Username, data.Email).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = models.User{CreatedAt: data.CreatedAt, UpdatedAt: data.UpdatedAt}
+			if data.PasswordHash == "" {
+				if data.PasswordHash, err = randomPasswordHash(); err != nil {
+					return err
+				}
+			}
+		}
+		user.Username, user.Email, user.Flagged = data.Username, data.Email, data.Flagged
+		user.EmailVerifiedAt = data.EmailVerifiedAt
+		// 版本 1 的归档早于邮箱验证功能，其中的用户视为已验证
+		if st.version < 2 {
+			user.EmailVerifiedAt = &data.CreatedAt
+		}
+		if data.PasswordHash != "" {
+			user.Password = data.PasswordHash
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		// 用户角色以归档为准
+		if err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", user.ID).Error; err != nil {
+			return err
+		}
+		st.users[data.ID] = user.ID
+
+	case models.ExportTypeUserRole:
+		var data models.ExportUserRole
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		userID, roleID := st.users[data.UserID], st.roles[data.RoleID]
+		if userID == 0 || roleID == 0 {
+			return errors.New("user or role not found in archive")
+		}
+		return tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			userID, roleID).Error
+
+	case models.ExportTypeTag:
+		var data models.ExportTag
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		var tag models.Tag
+		if err := tx.Where("name = ?", data.Name).FirstOrCreate(&tag, models.Tag{Name: data.Name}).Error; err != nil {
+			return err
+		}
+		st.tags[data.ID] = tag.ID
+
+	case models.ExportTypePost:
+		var data models.ExportPost
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		userID := st.users[data.UserID]
+		if userID == 0 {
+			return errors.New("post author not found in archive")
+		}
+		post := models.Post{
+			Title: data.Title, Slug: data.Slug, Content: data.Content, ContentHTML: data.ContentHTML,
+			Status: data.Status, PublishedAt: data.PublishedAt, ScheduledAt: data.ScheduledAt, UserID: userID,
+			CreatedAt: data.CreatedAt, UpdatedAt: data.UpdatedAt,
+		}
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		st.posts[data.ID] = post.ID
+		if data.CoverMediaID != nil {
+			st.covers[post.ID] = *data.CoverMediaID
+		}
+
+	case models.ExportTypePostTag:
+		var data models.ExportPostTag
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		postID, tagID := st.posts[data.PostID], st.tags[data.TagID]
+		if postID == 0 || tagID == 0 {
+			return errors.New("post or tag not found in archive")
+		}
+		return tx.Exec("INSERT INTO post_tags (post_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			postID, tagID).Error
+
+	case models.ExportTypePostSlug:
+		var data models.ExportPostSlug
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		postID := st.posts[data.PostID]
+		if postID == 0 {
+			return errors.New("post not found in archive")
+		}
+		return tx.Create(&models.PostSlugHistory{PostID: postID, Slug: data.Slug, CreatedAt: data.CreatedAt}).Error
+
+	case models.ExportTypePostRevision:
+		var data models.ExportPostRevision
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		postID, userID := st.posts[data.PostID], st.users[data.UserID]
+		if postID == 0 || userID == 0 {
+			return errors.New("revision post or editor not found in archive")
+		}
+		return tx.Create(&models.PostRevision{
+			PostID: postID, Version: data.Version, Title: data.Title, Content: data.Content,
+			Note: data.Note, UserID: userID, CreatedAt: data.CreatedAt,
+		}).Error
+
+	case models.ExportTypeMedia:
+		var data models.ExportMedia
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		userID := st.users[data.UserID]
+		if userID == 0 {
+			return errors.New("media owner not found in archive")
+		}
+		media := models.Media{
+			UserID: userID, Key: data.Key, URL: data.URL, Filename: data.Filename, MimeType: data.MimeType,
+			Size: data.Size, Width: data.Width, Height: data.Height, Checksum: data.Checksum,
+			DerivativeStatus: data.DerivativeStatus, CreatedAt: data.CreatedAt, UpdatedAt: data.UpdatedAt,
+		}
+		if data.PostID != nil {
+			postID := st.posts[*data.PostID]
+			if postID == 0 {
+				return errors.New("media post not found in archive")
+			}
+			media.PostID = &postID
+		}
+		if err := tx.Create(&media).Error; err != nil {
+			return err
+		}
+		st.media[data.ID] = media.ID
+
+	case models.ExportTypeDerivative:
+		var data models.ExportDerivative
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		mediaID := st.media[data.MediaID]
+		if mediaID == 0 {
+			return errors.New("derivative media not found in archive")
+		}
+		return tx.Create(&models.MediaDerivative{
+			MediaID: mediaID, Variant: data.Variant, Format: data.Format, Key: data.Key, URL: data.URL,
+			Width: data.Width, Height: data.Height, Size: data.Size,
+		}).Error
+
+	case models.ExportTypeComment:
+		var data models.ExportComment
+		if err := json.Unmarshal(record.Data, &data); err != nil {
+			return err
+		}
+		postID, userID := st.posts[data.PostID], st.users[data.UserID]
+		if postID == 0 || userID == 0 {
+			return errors.New("comment post or author not found in archive")
+		}
+		comment := models.Comment{
+			PostID: postID, UserID: userID, Content: data.Content, ContentHTML: data.ContentHTML,
+			Depth: data.Depth, ReplyCount: data.ReplyCount, IsDeleted: data.IsDeleted, Status: data.Status,
+			CreatedAt: data.CreatedAt, UpdatedAt: data.UpdatedAt,
+		}
+		if data.ParentID != nil {
+			parentID := st.comments[*data.ParentID]
+			if parentID == 0 {
+				return errors.New("parent comment not found in archive")
+			}
+			comment.ParentID = &parentID
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		st.comments[data.ID] = comment.ID
+
+	default:
+		return errors.New("unknown record type")
+	}
+	return nil
+}
+
+// restoreCovers 设置文章封面，媒体记录在文章之后恢复，因此在全部记录恢复后统一处理
+func (st *restoreState) restoreCovers(tx *gorm.DB) error {
+	for postID, archiveMediaID := range st.covers {
+		mediaID := st.media[archiveMediaID]
+		if mediaID == 0 {
+			return fmt.Errorf("cover media %d of post %d not found in archive", archiveMediaID, postID)
+		}
+		if err := tx.Model(&models.Post{}).Where("id = ?", postID).
+			UpdateColumn("cover_media_id", mediaID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// randomPasswordHash 生成随机密码的哈希，用于无法登录、需重置密码的账户
+func randomPasswordHash() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	return string(hash), err
+}
@@ -1,17 +1,29 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"keep_learning_blog/config"
 	"keep_learning_blog/db"
 	"keep_learning_blog/models"
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/markdown"
+	"keep_learning_blog/utils/spam"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CommentService 评论服务结构体
-type CommentService struct{}
+type CommentService struct {
+	SpamFilter *spam.Filter // 垃圾评论检查，为空时不检查
+}
 
 // CreateComment 创建评论 (insert)
-func (s *CommentService) CreateComment(content string, postID, userID uint) (*models.Comment, error) {
+// parentID 不为空时作为回复创建，超出最大嵌套深度的回复挂在最深一层的父评论下
+// moderationMode 为评论审核模式，需要审核的评论以待审核状态创建
+// honeypot 为蜜罐字段的值；被判定为垃圾内容时拒绝，可疑内容转入人工审核
+func (s *CommentService) CreateComment(content string, postID, userID uint, parentID *uint, moderationMode, honeypot string) (*models.Comment, error) {
 	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
 		"postID": postID,
 		"userID": userID,
@@ -26,6 +38,23 @@ func (s *CommentService) CreateComment(content string, postID, userID uint) (*mo
 		return nil, errors.New("content cannot be longer than 1000 characters")
 	}
 
+	// 垃圾评论检查
+	spamResult := spam.Result{Verdict: spam.Ham}
+	if s.SpamFilter != nil {
+		spamResult, _ = s.SpamFilter.Check(context.Background(), &spam.Content{
+			Kind:     spam.KindComment,
+			Text:     content,
+			Honeypot: honeypot,
+		})
+		if spamResult.Verdict == spam.Spam {
+			log.WithFields(logger.Fields(map[string]interface{}{
+				"checker": spamResult.Checker,
+				"reason":  spamResult.Reason,
+			})).Warn("Comment rejected as spam")
+			return nil, errors.New("comment rejected as spam")
+		}
+	}
+
 	// 开始事务
 	tx := db.DB.Begin()
 	defer func() {
@@ -34,18 +63,79 @@ func (s *CommentService) CreateComment(content string, postID, userID uint) (*mo
 		}
 	}()
 
-	// 检查文章是否存在
+	// 检查文章是否存在，草稿及定时发布的文章仅作者本人可评论，归档文章仍可评论
 	var post models.Post
-	if err := tx.First(&post, postID).Error; err != nil {
+	visible := postVisibilityScope(userID, false, models.PostStatusPublished, models.PostStatusArchived)
+	if err := tx.Scopes(visible).Select("id").First(&post, postID).Error; err != nil {
 		tx.Rollback()
 		return nil, errors.New("post not found")
 	}
 
+	// 渲染 Markdown
+	contentHTML, err := markdown.Render(content)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 根据审核模式确定评论状态
+	status, err := initialCommentStatus(tx, userID, moderationMode)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 可疑评论及可疑用户的评论转入人工审核
+	flagReason := ""
+	if spamResult.Verdict == spam.Suspicious {
+		flagReason = spamResult.Checker + ": " + spamResult.Reason
+	} else {
+		var author models.User
+		if err := tx.Select("id", "flagged").First(&author, userID).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if author.Flagged {
+			flagReason = "author flagged at registration"
+		}
+	}
+	if flagReason != "" {
+		status = models.CommentStatusPending
+	}
+
 	// 创建评论
 	comment := &models.Comment{
-		Content: content,
-		PostID:  postID,
-		UserID:  userID,
+		Content:     content,
+		ContentHTML: contentHTML,
+		PostID:      postID,
+		UserID:      userID,
+		Status:      status,
+	}
+
+	// 处理回复关系
+	if parentID != nil {
+		var parent models.Comment
+		if err := tx.Where("id = ? AND post_id = ?", *parentID, postID).First(&parent).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("parent comment not found")
+		}
+		if parent.IsDeleted {
+			tx.Rollback()
+			return nil, errors.New("cannot reply to a deleted comment")
+		}
+		if parent.Status != models.CommentStatusApproved {
+			tx.Rollback()
+			return nil, errors.New("parent comment not found")
+		}
+
+		// 达到最大深度时改为回复父评论的父评论
+		if parent.Depth >= models.MaxCommentDepth-1 && parent.ParentID != nil {
+			parentID = parent.ParentID
+			comment.Depth = parent.Depth
+		} else {
+			comment.Depth = parent.Depth + 1
+		}
+		comment.ParentID = parentID
 	}
 
 	if err := tx.Create(comment).Error; err != nil {
@@ -53,18 +143,42 @@ func (s *CommentService) CreateComment(content string, postID, userID uint) (*mo
 		return nil, err
 	}
 
+	// 记录系统转入审核的原因
+	if flagReason != "" {
+		if err := tx.Create(&models.CommentModeration{
+			CommentID:  comment.ID,
+			Action:     models.ModerationActionFlag,
+			FromStatus: status,
+			ToStatus:   status,
+			Reason:     flagReason,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// 更新父评论的回复数（仅统计已通过的回复）
+	if comment.Status == models.CommentStatusApproved {
+		if err := adjustReplyCount(tx, comment.ParentID, 1); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
 	// 加载关联的用户信息
 	if err := tx.Preload("User").First(comment, comment.ID).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	log.Info("Comment created successfully")
+	log.WithField("status", comment.Status).Info("Comment created successfully")
 	return comment, tx.Commit().Error
 }
 
 // UpdateComment 更新评论 (update)
-func (s *CommentService) UpdateComment(commentID, userID uint, content string) (*models.Comment, error) {
+// moderationMode 为 all 时，已通过的评论编辑后重新进入待审核
+// 编辑后的内容同样经过垃圾评论检查，被判定为垃圾内容时拒绝，可疑内容转入人工审核
+func (s *CommentService) UpdateComment(commentID, userID uint, content, moderationMode string) (*models.Comment, error) {
 	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
 		"commentID": commentID,
 		"userID":    userID,
@@ -78,6 +192,22 @@ func (s *CommentService) UpdateComment(commentID, userID uint, content string) (
 		return nil, errors.New("content cannot be longer than 1000 characters")
 	}
 
+	// 垃圾评论检查，避免先发布无害内容再编辑为垃圾内容
+	spamResult := spam.Result{Verdict: spam.Ham}
+	if s.SpamFilter != nil {
+		spamResult, _ = s.SpamFilter.Check(context.Background(), &spam.Content{
+			Kind: spam.KindComment,
+			Text: content,
+		})
+		if spamResult.Verdict == spam.Spam {
+			log.WithFields(logger.Fields(map[string]interface{}{
+				"checker": spamResult.Checker,
+				"reason":  spamResult.Reason,
+			})).Warn("Comment edit rejected as spam")
+			return nil, errors.New("comment rejected as spam")
+		}
+	}
+
 	// 开始事务
 	tx := db.DB.Begin()
 	defer func() {
@@ -100,21 +230,77 @@ func (s *CommentService) UpdateComment(commentID, userID uint, content string) (
 		return nil, errors.New("unauthorized to update this comment")
 	}
 
-	// 更新评论内容
+	// 已删除的评论不能编辑
+	if comment.IsDeleted {
+		tx.Rollback()
+		return nil, errors.New("comment not found")
+	}
+
+	// 渲染 Markdown
+	contentHTML, err := markdown.Render(content)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 可疑内容转入人工审核；审核所有评论时，已通过的评论编辑后重新进入待审核，避免先发布无害内容再修改绕过审核
+	// 已拒绝的评论保持拒绝状态，不因编辑重新进入审核队列
+	fromStatus := comment.Status
+	moderation := &models.CommentModeration{FromStatus: fromStatus, ToStatus: models.CommentStatusPending}
+	switch {
+	case spamResult.Verdict == spam.Suspicious:
+		moderation.Action = models.ModerationActionFlag
+		moderation.Reason = spamResult.Checker + ": " + spamResult.Reason
+	case moderationMode == config.ModerationModeAll && fromStatus == models.CommentStatusApproved:
+		moderation.Action = models.ModerationActionEdit
+		moderation.Reason = "edited after approval"
+	default:
+		moderation = nil
+	}
+	if moderation != nil && fromStatus != models.CommentStatusRejected {
+		comment.Status = models.CommentStatusPending
+	} else if moderation != nil {
+		moderation.ToStatus = fromStatus
+	}
+
+	// 更新评论内容，原内容的分类器训练随之撤销
+	previousContent, previousTrainedAs := comment.Content, comment.TrainedAs
 	comment.Content = content
+	comment.ContentHTML = contentHTML
+	comment.TrainedAs = ""
 	if err := tx.Save(&comment).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	// 记录转入审核的原因，已通过的评论不再计入父评论的回复数
+	if moderation != nil {
+		if fromStatus == models.CommentStatusApproved {
+			if err := adjustReplyCount(tx, comment.ParentID, -1); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		moderation.CommentID = comment.ID
+		if err := tx.Create(moderation).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
 	// 重新加载评论信息，包括用户信息
 	if err := tx.Preload("User").First(&comment, comment.ID).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	s.untrain(comment.ID, previousContent, previousTrainedAs)
 	log.Info("Comment updated successfully")
-	return &comment, tx.Commit().Error
+	return &comment, nil
 }
 
 // DeleteComment 删除评论 (delete)
@@ -149,9 +335,13 @@ func (s *CommentService) DeleteComment(commentID, userID uint) error {
 		tx.Rollback()
 		return errors.New("unauthorized to delete this comment")
 	}
+	if comment.IsDeleted {
+		tx.Rollback()
+		return errors.New("comment not found")
+	}
 
 	// 删除评论
-	if err := tx.Delete(&comment).Error; err != nil {
+	if err := deleteComment(tx, &comment); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -159,3 +349,308 @@ func (s *CommentService) DeleteComment(commentID, userID uint) error {
 	log.Info("Comment deleted successfully")
 	return tx.Commit().Error
 }
+
+// ListPendingComments 分页获取待审核评论 (select)
+func (s *CommentService) ListPendingComments(page, pageSize int) ([]models.Comment, int64, error) {
+	var comments []models.Comment
+	var total int64
+
+	query := db.DB.Model(&models.Comment{}).Where("status = ?", models.CommentStatusPending)
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 获取分页数据，先提交的先审核
+	offset := (page - 1) * pageSize
+	if err := query.Preload("User").
+		Offset(offset).Limit(pageSize).
+		Order("created_at ASC, id ASC").
+		Find(&comments).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return comments, total, nil
+}
+
+// ApproveComment 审核通过评论 (update)
+func (s *CommentService) ApproveComment(commentID, moderatorID uint, reason string) (*models.Comment, error) {
+	return s.moderate(commentID, moderatorID, models.ModerationActionApprove, reason)
+}
+
+// RejectComment 审核拒绝评论 (update)
+func (s *CommentService) RejectComment(commentID, moderatorID uint, reason string) (*models.Comment, error) {
+	return s.moderate(commentID, moderatorID, models.ModerationActionReject, reason)
+}
+
+// BulkApproveComments 批量审核通过待审核评论 (update)，非待审核状态的评论会被跳过
+func (s *CommentService) BulkApproveComments(commentIDs []uint, moderatorID uint, reason string) ([]uint, error) {
+	// 开始事务
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var comments []models.Comment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND status = ?", commentIDs, models.CommentStatusPending).
+		Order("id").Find(&comments).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	approved := make([]uint, 0, len(comments))
+	for i := range comments {
+		if err := applyModeration(tx, &comments[i], moderatorID, models.ModerationActionApprove, reason); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		approved = append(approved, comments[i].ID)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"moderatorID": moderatorID,
+		"count":       len(approved),
+	})).Info("Comments approved in bulk")
+
+	s.train(comments, false)
+	return approved, nil
+}
+
+// GetCommentModerations 获取评论的审核记录 (select)
+func (s *CommentService) GetCommentModerations(commentID uint) ([]models.CommentModeration, error) {
+	var moderations []models.CommentModeration
+	if err := db.DB.Where("comment_id = ?", commentID).Order("created_at ASC, id ASC").Find(&moderations).Error; err != nil {
+		return nil, err
+	}
+	return moderations, nil
+}
+
+// moderate 审核单条评论
+func (s *CommentService) moderate(commentID, moderatorID uint, action, reason string) (*models.Comment, error) {
+	// 开始事务
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 检查评论是否存在
+	var comment models.Comment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comment, commentID).Error; err != nil {
+		tx.Rollback()
+		return nil, errors.New("comment not found")
+	}
+
+	if err := applyModeration(tx, &comment, moderatorID, action, reason); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"commentID":   commentID,
+		"moderatorID": moderatorID,
+		"action":      action,
+	})).Info("Comment moderated")
+
+	s.train([]models.Comment{comment}, action == models.ModerationActionReject)
+	return &comment, nil
+}
+
+// train 使用审核结果训练垃圾评论分类器，训练失败不影响审核结果
+// 评论已按另一类别训练过时先撤销原训练，避免同一条评论被重复计数
+func (s *CommentService) train(comments []models.Comment, isSpam bool) {
+	if s.SpamFilter == nil {
+		return
+	}
+	class := models.SpamClassHam
+	if isSpam {
+		class = models.SpamClassSpam
+	}
+
+	ctx := context.Background()
+	for _, comment := range comments {
+		if comment.TrainedAs == class {
+			continue
+		}
+		log := logger.Log.WithField("commentID", comment.ID)
+		content := &spam.Content{Kind: spam.KindComment, Text: comment.Content}
+
+		if comment.TrainedAs != "" {
+			if err := s.SpamFilter.Untrain(ctx, content, comment.TrainedAs == models.SpamClassSpam); err != nil {
+				log.WithError(err).Warn("Failed to untrain spam filter")
+				continue
+			}
+		}
+		trainedAs := class
+		if err := s.SpamFilter.Train(ctx, content, isSpam); err != nil {
+			log.WithError(err).Warn("Failed to train spam filter")
+			trainedAs = ""
+		}
+		if err := db.DB.Model(&models.Comment{}).Where("id = ?", comment.ID).
+			UpdateColumn("trained_as", trainedAs).Error; err != nil {
+			log.WithError(err).Warn("Failed to record spam training")
+		}
+	}
+}
+
+// untrain 评论内容变更后撤销原内容的训练
+func (s *CommentService) untrain(commentID uint, content, trainedAs string) {
+	if s.SpamFilter == nil || trainedAs == "" {
+		return
+	}
+	if err := s.SpamFilter.Untrain(context.Background(), &spam.Content{
+		Kind: spam.KindComment,
+		Text: content,
+	}, trainedAs == models.SpamClassSpam); err != nil {
+		logger.Log.WithError(err).WithField("commentID", commentID).Warn("Failed to untrain spam filter")
+	}
+}
+
+// applyModeration 变更评论审核状态并记录审核操作
+// 待审核和已拒绝的评论可以通过，只有待审核的评论可以拒绝
+func applyModeration(tx *gorm.DB, comment *models.Comment, moderatorID uint, action, reason string) error {
+	from := comment.Status
+	var to string
+	switch action {
+	case models.ModerationActionApprove:
+		if from != models.CommentStatusPending && from != models.CommentStatusRejected {
+			return errors.New("comment is not awaiting approval")
+		}
+		to = models.CommentStatusApproved
+	case models.ModerationActionReject:
+		if from != models.CommentStatusPending {
+			return errors.New("only pending comments can be rejected")
+		}
+		to = models.CommentStatusRejected
+	default:
+		return errors.New("invalid moderation action")
+	}
+
+	if err := tx.Model(comment).Update("status", to).Error; err != nil {
+		return err
+	}
+
+	// 通过后解除作者的可疑标记
+	if to == models.CommentStatusApproved {
+		if err := tx.Model(&models.User{}).Where("id = ? AND flagged = ?", comment.UserID, true).
+			Update("flagged", false).Error; err != nil {
+			return err
+		}
+	}
+
+	// 通过后计入父评论的回复数
+	if to == models.CommentStatusApproved {
+		if err := adjustReplyCount(tx, comment.ParentID, 1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&models.CommentModeration{
+		CommentID:   comment.ID,
+		ModeratorID: moderatorID,
+		Action:      action,
+		FromStatus:  from,
+		ToStatus:    to,
+		Reason:      reason,
+	}).Error
+}
+
+// initialCommentStatus 根据审核模式确定新评论的状态
+func initialCommentStatus(tx *gorm.DB, userID uint, moderationMode string) (string, error) {
+	switch moderationMode {
+	case config.ModerationModeAll:
+		return models.CommentStatusPending, nil
+	case config.ModerationModeFirst:
+		// 已有通过的评论的用户无需再审核
+		var count int64
+		if err := tx.Model(&models.Comment{}).
+			Where("user_id = ? AND status = ?", userID, models.CommentStatusApproved).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return models.CommentStatusPending, nil
+		}
+	}
+	return models.CommentStatusApproved, nil
+}
+
+// adjustReplyCount 调整父评论的回复数
+func adjustReplyCount(tx *gorm.DB, parentID *uint, delta int) error {
+	if parentID == nil {
+		return nil
+	}
+	return tx.Model(&models.Comment{}).Where("id = ?", *parentID).
+		UpdateColumn("reply_count", gorm.Expr("GREATEST(reply_count + ?, 0)", delta)).Error
+}
+
+// commentVisibilityScope 限定可见评论：已通过的评论、自己的评论，以及审核员可见的待审核评论
+func commentVisibilityScope(viewerID uint, canModerate bool) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if canModerate {
+			return tx.Where("(comments.status IN ? OR comments.user_id = ?)",
+				[]string{models.CommentStatusApproved, models.CommentStatusPending}, viewerID)
+		}
+		if viewerID == 0 {
+			return tx.Where("comments.status = ?", models.CommentStatusApproved)
+		}
+		return tx.Where("(comments.status = ? OR comments.user_id = ?)", models.CommentStatusApproved, viewerID)
+	}
+}
+
+// deleteComment 删除评论
+// 存在回复时保留为占位评论以维持讨论结构，否则物理删除，并清理因此不再有回复的已删除父评论
+func deleteComment(tx *gorm.DB, comment *models.Comment) error {
+	// 待审核的回复同样需要保留父评论
+	var replies int64
+	if err := tx.Model(&models.Comment{}).Where("parent_id = ?", comment.ID).Count(&replies).Error; err != nil {
+		return err
+	}
+	if replies > 0 {
+		return tx.Model(comment).Updates(map[string]interface{}{
+			"is_deleted":   true,
+			"content":      models.DeletedCommentContent,
+			"content_html": "<p>" + models.DeletedCommentContent + "</p>",
+		}).Error
+	}
+
+	if err := tx.Delete(comment).Error; err != nil {
+		return err
+	}
+	if comment.ParentID == nil {
+		return nil
+	}
+
+	// 减少父评论的回复数
+	if comment.Status == models.CommentStatusApproved {
+		if err := adjustReplyCount(tx, comment.ParentID, -1); err != nil {
+			return err
+		}
+	}
+
+	// 已删除的父评论失去最后一条回复后不再需要保留
+	var parent models.Comment
+	if err := tx.First(&parent, *comment.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if parent.IsDeleted {
+		return deleteComment(tx, &parent)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"keep_learning_blog/db"
+	"keep_learning_blog/utils/logger"
+	"time"
+)
+
+// CachedDocument 订阅源、站点地图等按内容版本缓存的生成文档
+type CachedDocument struct {
+	Body         []byte    `json:"body"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// newCachedDocument 创建文档，ETag 由内容摘要生成
+func newCachedDocument(body []byte, contentType string, lastModified time.Time) *CachedDocument {
+	if lastModified.IsZero() {
+		lastModified = time.Unix(0, 0)
+	}
+	sum := sha256.Sum256(body)
+	return &CachedDocument{
+		Body:         body,
+		ContentType:  contentType,
+		ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+		LastModified: lastModified.UTC().Truncate(time.Second),
+	}
+}
+
+// getCachedDocument 读取缓存的文档，未命中时调用 build 生成并写入缓存
+// 缓存键包含内容版本号，文章变更后旧缓存自然失效；Redis 不可用时直接生成
+func getCachedDocument(ctx context.Context, key string, ttl time.Duration, build func() (*CachedDocument, error)) (*CachedDocument, error) {
+	cacheKey := ""
+	if version, err := db.GetContentVersion(ctx); err == nil {
+		cacheKey = fmt.Sprintf("%s:v%d", key, version)
+		if data, err := db.GetCache(ctx, cacheKey); err == nil && data != nil {
+			var doc CachedDocument
+			if err := json.Unmarshal(data, &doc); err == nil {
+				return &doc, nil
+			}
+		}
+	} else {
+		logger.Log.WithError(err).Warn("Failed to get content version")
+	}
+
+	doc, err := build()
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheKey != "" {
+		if data, err := json.Marshal(doc); err == nil {
+			if err := db.SetCache(ctx, cacheKey, data, ttl); err != nil {
+				logger.Log.WithField("key", cacheKey).WithError(err).Warn("Failed to cache document")
+			}
+		}
+	}
+	return doc, nil
+}
+
+// invalidateContentCache 文章、标签或作者变更后使订阅源、站点地图等派生内容的缓存失效
+func invalidateContentCache() {
+	if db.RedisClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := db.BumpContentVersion(ctx); err != nil {
+		logger.Log.WithError(err).Warn("Failed to invalidate content cache")
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/mail"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidVerificationToken 验证链接无效或已过期
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+	// ErrEmailAlreadyVerified 邮箱已验证
+	ErrEmailAlreadyVerified = errors.New("email address already verified")
+)
+
+// EmailVerificationService 邮箱验证服务结构体
+type EmailVerificationService struct {
+	Mailer       mail.Sender
+	Site         *config.SiteConfig
+	Verification *config.EmailVerificationConfig
+}
+
+// SendVerification 向用户邮箱发送带签名的验证链接
+// 签名包含用户 ID、邮箱和过期时间，邮箱变更后旧链接自动失效
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	expiresAt := time.Now().Add(s.Verification.TTL)
+	token := fmt.Sprintf("%d.%d.%s", user.ID, expiresAt.Unix(), s.sign(user.ID, user.Email, expiresAt.Unix()))
+	link := fmt.Sprintf("%s/api/verify-email?token=%s", strings.TrimRight(s.Site.URL, "/"), url.QueryEscape(token))
+
+	err := s.Mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("验证您在 %s 的邮箱地址", s.Site.Title),
+		Body: fmt.Sprintf("%s，您好：\n\n请在 %s 前打开以下链接完成邮箱验证，验证后即可发表文章和评论：\n\n%s\n\n如果您没有注册 %s，请忽略此邮件。\n",
+			user.Username, expiresAt.Format("2006-01-02 15:04"), link, s.Site.Title),
+	})
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		})).Error("Failed to send verification email")
+		return errors.New("failed to send verification email")
+	}
+
+	logger.Log.WithField("user_id", user.ID).Info("Verification email sent")
+	return nil
+}
+
+// ResendVerification 重新发送验证邮件 (select)
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, userID uint) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.SendVerification(ctx, &user)
+}
+
+// VerifyEmail 校验验证链接并标记邮箱已验证 (update)
+// 重复打开有效链接时直接返回成功
+func (s *EmailVerificationService) VerifyEmail(token string) (*models.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidVerificationToken
+	}
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+
+	// 使用当前邮箱重新计算签名
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(user.ID, user.Email, expiresAt))) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if user.EmailVerifiedAt != nil {
+		return &user, nil
+	}
+
+	now := time.Now()
+	if err := db.DB.Model(&user).UpdateColumn("email_verified_at", now).Error; err != nil {
+		return nil, err
+	}
+	user.EmailVerifiedAt = &now
+
+	logger.Log.WithField("user_id", user.ID).Info("Email verified successfully")
+	return &user, nil
+}
+
+// sign 计算验证链接签名
+func (s *EmailVerificationService) sign(userID uint, email string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, []byte(s.Verification.Secret))
+	fmt.Fprintf(mac, "email-verification:%d:%s:%d", userID, strings.ToLower(email), expiresAt)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
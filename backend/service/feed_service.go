@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"gorm.io/gorm"
+)
+
+// 订阅源格式
+const (
+	FeedFormatRSS  = "rss"
+	FeedFormatAtom = "atom"
+	FeedFormatJSON = "json"
+)
+
+// feedContentTypes 各格式的响应类型
+var feedContentTypes = map[string]string{
+	FeedFormatRSS:  "application/rss+xml; charset=utf-8",
+	FeedFormatAtom: "application/atom+xml; charset=utf-8",
+	FeedFormatJSON: "application/feed+json; charset=utf-8",
+}
+
+// feedSummaryLength 条目摘要的最大字符数
+const feedSummaryLength = 200
+
+// ErrFeedNotFound 标签或作者不存在
+var ErrFeedNotFound = errors.New("feed not found")
+
+// FeedService 订阅源服务结构体
+type FeedService struct {
+	Site *config.SiteConfig
+}
+
+// GetFeed 获取订阅源，优先读取缓存
+// scope 为 ""（全站）、"tag" 或 "author"，value 为标签名或用户名
+func (s *FeedService) GetFeed(ctx context.Context, format, scope, value string) (*CachedDocument, error) {
+	if _, ok := feedContentTypes[format]; !ok {
+		return nil, errors.New("unsupported feed format")
+	}
+
+	key := fmt.Sprintf("feed:%s:%s:%s", format, scope, url.PathEscape(value))
+	return getCachedDocument(ctx, key, s.Site.CacheTTL, func() (*CachedDocument, error) {
+		return s.buildFeed(format, scope, value)
+	})
+}
+
+// buildFeed 查询已发布文章并生成订阅源
+func (s *FeedService) buildFeed(format, scope, value string) (*CachedDocument, error) {
+	siteURL := strings.TrimRight(s.Site.URL, "/")
+	feed := &feeds.Feed{
+		Title:       s.Site.Title,
+		Link:        &feeds.Link{Href: siteURL + "/"},
+		Description: s.Site.Description,
+		Id:          siteURL + "/",
+	}
+
+	query := db.DB.Model(&models.Post{}).Where("posts.status = ?", models.PostStatusPublished)
+	switch scope {
+	case "tag":
+		var tag models.Tag
+		if err := db.DB.Where("name = ?", value).First(&tag).Error; err != nil {
+			return nil, feedLookupError(err)
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id = posts.id AND post_tags.tag_id = ?)", tag.ID)
+		feed.Title = fmt.Sprintf("%s - %s", s.Site.Title, tag.Name)
+		feed.Link = &feeds.Link{Href: siteURL + "/tags/" + url.PathEscape(tag.Name)}
+		feed.Id = feed.Link.Href
+	case "author":
+		var user models.User
+		if err := db.DB.Where("username = ?", value).First(&user).Error; err != nil {
+			return nil, feedLookupError(err)
+		}
+		query = query.Where("posts.user_id = ?", user.ID)
+		feed.Title = fmt.Sprintf("%s - %s", s.Site.Title, user.Username)
+		feed.Link = &feeds.Link{Href: siteURL + "/authors/" + url.PathEscape(user.Username)}
+		feed.Id = feed.Link.Href
+		feed.Author = &feeds.Author{Name: user.Username}
+	}
+
+	var posts []models.Post
+	if err := query.Preload("User").
+		Order("published_at DESC, id DESC").
+		Limit(s.Site.FeedSize).
+		Find(&posts).Error; err != nil {
+		return nil, err
+	}
+
+	// 订阅源的更新时间为最近一次文章变更时间
+	var lastModified time.Time
+	for _, post := range posts {
+		link := siteURL + "/posts/" + url.PathEscape(post.Slug)
+		published := post.CreatedAt
+		if post.PublishedAt != nil {
+			published = *post.PublishedAt
+		}
+		if post.UpdatedAt.After(lastModified) {
+			lastModified = post.UpdatedAt
+		}
+
+		feed.Add(&feeds.Item{
+			Title:       post.Title,
+			Link:        &feeds.Link{Href: link},
+			Id:          link,
+			Author:      &feeds.Author{Name: post.User.Username},
+			Description: feedSummary(post.ContentHTML),
+			Content:     post.ContentHTML,
+			Created:     published,
+			Updated:     post.UpdatedAt,
+		})
+	}
+	feed.Updated = lastModified
+
+	var body string
+	var err error
+	switch format {
+	case FeedFormatAtom:
+		body, err = feed.ToAtom()
+	case FeedFormatJSON:
+		body, err = feed.ToJSON()
+	default:
+		body, err = feed.ToRss()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newCachedDocument([]byte(body), feedContentTypes[format], lastModified), nil
+}
+
+// feedLookupError 将标签或作者不存在转换为 ErrFeedNotFound
+func feedLookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrFeedNotFound
+	}
+	return err
+}
+
+// feedSummary 从渲染后的 HTML 生成纯文本摘要
+func feedSummary(contentHTML string) string {
+	text := strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(contentHTML, " "))), " ")
+	runes := []rune(text)
+	if len(runes) <= feedSummaryLength {
+		return text
+	}
+	return string(runes[:feedSummaryLength]) + "…"
+}
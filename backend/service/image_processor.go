@@ -0,0 +1,228 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/storage"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/gen2brain/webp"
+	"gorm.io/gorm/clause"
+)
+
+// processingTimeout 处理中状态超过该时长视为实例异常退出，重新处理
+const processingTimeout = 10 * time.Minute
+
+// ImageProcessor 图片衍生图生成器
+// 使用固定数量的协程处理队列中的图片，队列已满或服务重启时遗漏的图片由定期扫描补处理
+type ImageProcessor struct {
+	config  *config.ImageConfig
+	storage storage.Backend
+	jobs    chan uint
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewImageProcessor 创建图片衍生图生成器
+func NewImageProcessor(cfg *config.Config, backend storage.Backend) *ImageProcessor {
+	return &ImageProcessor{
+		config:  &cfg.Image,
+		storage: backend,
+		jobs:    make(chan uint, cfg.Image.QueueSize),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start 启动处理协程和定期扫描
+func (p *ImageProcessor) Start() {
+	for i := 0; i < p.config.Workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case id := <-p.jobs:
+					p.process(id)
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.config.SweepInterval)
+		defer ticker.Stop()
+
+		p.sweep()
+		for {
+			select {
+			case <-ticker.C:
+				p.sweep()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+
+	logger.Log.WithField("workers", p.config.Workers).Info("Image processor started")
+}
+
+// Stop 停止处理并等待当前图片处理完成，未处理的图片在下次启动时补处理
+func (p *ImageProcessor) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+	logger.Log.Info("Image processor stopped")
+}
+
+// Enqueue 将图片加入处理队列，队列已满时不阻塞
+func (p *ImageProcessor) Enqueue(mediaID uint) {
+	select {
+	case p.jobs <- mediaID:
+	default:
+		logger.Log.WithField("mediaID", mediaID).Warn("Image queue full, deferring to sweep")
+	}
+}
+
+// sweep 将等待处理及处理超时的图片加入队列
+func (p *ImageProcessor) sweep() {
+	var ids []uint
+	if err := db.DB.Model(&models.Media{}).
+		Where("derivative_status = ? OR (derivative_status = ? AND updated_at < ?)",
+			models.DerivativeStatusPending, models.DerivativeStatusProcessing, time.Now().Add(-processingTimeout)).
+		Order("id").Limit(p.config.QueueSize).Pluck("id", &ids).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to find pending images")
+		return
+	}
+	for _, id := range ids {
+		p.Enqueue(id)
+	}
+}
+
+// process 生成单张图片的所有衍生图
+func (p *ImageProcessor) process(mediaID uint) {
+	log := logger.Log.WithField("mediaID", mediaID)
+
+	// 抢占处理权，避免多个协程或实例重复处理
+	result := db.DB.Model(&models.Media{}).
+		Where("id = ? AND (derivative_status = ? OR (derivative_status = ? AND updated_at < ?))", mediaID,
+			models.DerivativeStatusPending, models.DerivativeStatusProcessing, time.Now().Add(-processingTimeout)).
+		Update("derivative_status", models.DerivativeStatusProcessing)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	status := models.DerivativeStatusReady
+	if err := p.generate(mediaID); err != nil {
+		log.WithError(err).Error("Failed to generate image derivatives")
+		status = models.DerivativeStatusFailed
+	}
+
+	if err := db.DB.Model(&models.Media{}).Where("id = ?", mediaID).
+		Update("derivative_status", status).Error; err != nil {
+		log.WithError(err).Error("Failed to update derivative status")
+	}
+}
+
+// generate 读取原图并按配置的宽度生成 JPEG 和 WebP 衍生图
+func (p *ImageProcessor) generate(mediaID uint) error {
+	var item models.Media
+	if err := db.DB.First(&item, mediaID).Error; err != nil {
+		return err
+	}
+	if item.Width*item.Height > p.config.MaxPixels {
+		return errors.New("image too large")
+	}
+
+	ctx := context.Background()
+	reader, err := p.storage.Get(ctx, item.Key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	img, err := imaging.Decode(reader)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(item.Key, item.Key[strings.LastIndex(item.Key, "."):])
+	lastWidth := 0
+	for _, variant := range p.config.Variants {
+		// 原图较窄时不放大，宽度相同的规格只生成一次
+		width := min(variant.Width, img.Bounds().Dx())
+		if width == lastWidth {
+			continue
+		}
+		lastWidth = width
+
+		resized := imaging.Resize(img, width, 0, imaging.Lanczos)
+		for _, format := range []string{"webp", "jpeg"} {
+			derivative, err := p.store(ctx, &item, resized, base, variant.Name, format)
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", variant.Name, format, err)
+			}
+			if err := db.DB.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "media_id"}, {Name: "variant"}, {Name: "format"}},
+				DoUpdates: clause.AssignmentColumns([]string{"key", "url", "width", "height", "size"}),
+			}).Create(derivative).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// store 编码并保存衍生图，文件名为原图文件名加规格后缀
+// 衍生图与原图保存在同一存储后端的相邻位置：本地存储时即原图所在目录；使用 S3 时保存在同一存储桶中，
+// 不单独写入本地磁盘，否则同一图片分散在两处，多实例部署时其他实例无法访问。衍生图大小计入用户配额
+func (p *ImageProcessor) store(ctx context.Context, item *models.Media, img image.Image, base, variant, format string) (*models.MediaDerivative, error) {
+	var buf bytes.Buffer
+	var ext, contentType string
+	switch format {
+	case "webp":
+		ext, contentType = ".webp", "image/webp"
+		if err := webp.Encode(&buf, img, webp.Options{Quality: p.config.WebPQuality}); err != nil {
+			return nil, err
+		}
+	default:
+		// JPEG 不支持透明度，透明区域以白色填充
+		ext, contentType = ".jpg", "image/jpeg"
+		flattened := imaging.Overlay(imaging.New(img.Bounds().Dx(), img.Bounds().Dy(), color.White), img, image.Pt(0, 0), 1)
+		if err := jpeg.Encode(&buf, flattened, &jpeg.Options{Quality: p.config.JPEGQuality}); err != nil {
+			return nil, err
+		}
+	}
+
+	key := fmt.Sprintf("%s_%s%s", base, variant, ext)
+	size := int64(buf.Len())
+	if err := p.storage.Put(ctx, key, &buf, size, contentType); err != nil {
+		return nil, err
+	}
+
+	return &models.MediaDerivative{
+		MediaID: item.ID,
+		Variant: variant,
+		Format:  format,
+		Key:     key,
+		URL:     p.storage.URL(key),
+		Width:   img.Bounds().Dx(),
+		Height:  img.Bounds().Dy(),
+		Size:    size,
+	}, nil
+}
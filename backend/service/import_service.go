@@ -0,0 +1,373 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/archive"
+	"keep_learning_blog/utils/frontmatter"
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/markdown"
+	"keep_learning_blog/utils/sanitizer"
+	"keep_learning_blog/utils/slug"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun 试运行时用于回滚事务
+var errDryRun = errors.New("dry run")
+
+// jekyllFilenamePattern Jekyll 文章文件名，如 2020-01-02-hello-world.md
+var jekyllFilenamePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+// importedPost 从 Markdown 文件解析出的待导入文章
+type importedPost struct {
+	Title   string
+	Slug    string
+	Content string
+	Tags    []string
+	Date    *time.Time
+	Draft   bool
+	HTML    bool // Content 为 HTML（如 WordPress 正文）时直接过滤，不再按 Markdown 渲染
+}
+
+// ImportService 内容导入服务结构体
+type ImportService struct {
+	Import *config.ImportConfig
+}
+
+// ImportMarkdownArchive 从 Hugo / Jekyll 的 Markdown 压缩包导入文章，导入的文章作者为 userID
+// 以 slug 作为唯一键，重复导入时更新已有文章，内容未变化的文章不做修改；dryRun 为 true 时只生成报告
+func (s *ImportService) ImportMarkdownArchive(userID uint, data []byte, dryRun bool) (*models.ImportReport, error) {
+	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"userID": userID,
+		"dryRun": dryRun,
+	}))
+
+	if userID == 0 {
+		return nil, errors.New("userID cannot be empty")
+	}
+
+	files, err := archive.ReadFiles(data, isMarkdownFile, archive.Limits{
+		MaxFileSize: s.Import.MaxFileSize,
+		MaxFiles:    s.Import.MaxFiles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	report := &models.ImportReport{DryRun: dryRun, Items: []models.ImportItem{}}
+	seen := make(map[string]string, len(files))
+	for _, file := range files {
+		item := models.ImportItem{File: file.Name}
+
+		post, skipReason, err := parseMarkdownPost(file)
+		switch {
+		case err != nil:
+			item.Action, item.Message = models.ImportActionError, err.Error()
+		case skipReason != "":
+			item.Action, item.Message = models.ImportActionSkip, skipReason
+		case seen[post.Slug] != "":
+			item.Slug, item.Title = post.Slug, post.Title
+			item.Action, item.Message = models.ImportActionError, "duplicate slug, already used by "+seen[post.Slug]
+		default:
+			seen[post.Slug] = file.Name
+			item.Slug, item.Title = post.Slug, post.Title
+			item.Action, item.PostID, err = importPost(userID, post, dryRun)
+			if err != nil {
+				item.Action, item.Message = models.ImportActionError, err.Error()
+			}
+		}
+		addImportItem(report, item)
+	}
+
+	if !dryRun && report.Created+report.Updated > 0 {
+		invalidateContentCache()
+	}
+
+	log.WithFields(logger.Fields(map[string]interface{}{
+		"total":   report.Total,
+		"created": report.Created,
+		"updated": report.Updated,
+		"failed":  report.Failed,
+	})).Info("Markdown archive imported")
+	return report, nil
+}
+
+// isMarkdownFile 判断是否为 Markdown 文件
+func isMarkdownFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// parseMarkdownPost 解析 Markdown 文件，非文章内容返回跳过原因
+// Hugo 的 _index.md 等以下划线或点开头的文件视为非文章；Jekyll 的 _drafts 目录中的文件视为草稿
+func parseMarkdownPost(file archive.File) (*importedPost, string, error) {
+	base := path.Base(file.Name)
+	if strings.HasPrefix(base, "_") || strings.HasPrefix(base, ".") {
+		return nil, "not a post", nil
+	}
+
+	matter, body, err := frontmatter.Parse(file.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	if !utf8.Valid(body) {
+		return nil, "", errors.New("content is not valid UTF-8")
+	}
+
+	post := &importedPost{
+		Title:   matter.Title,
+		Content: strings.TrimSpace(string(body)),
+		Date:    matter.Date,
+		Draft:   matter.Draft,
+	}
+	if post.Title == "" {
+		return nil, "", errors.New("title is missing in front matter")
+	}
+	if len(post.Title) > 200 {
+		return nil, "", errors.New("title cannot be longer than 200 characters")
+	}
+	if post.Content == "" {
+		return nil, "", errors.New("content is empty")
+	}
+
+	// slug 优先取 front matter，其次取文件名；Hugo 的 page bundle（目录/index.md）取目录名
+	name := strings.TrimSuffix(base, path.Ext(base))
+	if strings.EqualFold(name, "index") && path.Dir(file.Name) != "." {
+		name = path.Base(path.Dir(file.Name))
+	}
+	if m := jekyllFilenamePattern.FindStringSubmatch(name); m != nil {
+		name = m[2]
+		if post.Date == nil {
+			if date, err := time.ParseInLocation("2006-01-02", m[1], time.Local); err == nil {
+				post.Date = &date
+			}
+		}
+	}
+	if matter.Slug != "" {
+		name = matter.Slug
+	}
+	post.Slug = slug.Make(name)
+
+	for _, dir := range strings.Split(path.Dir(file.Name), "/") {
+		if dir == "_drafts" {
+			post.Draft = true
+		}
+	}
+
+	for _, tag := range matter.Tags {
+		if len(tag) > 50 {
+			return nil, "", fmt.Errorf("tag %q must be less than 50 characters", tag)
+		}
+	}
+	post.Tags = matter.Tags
+	return post, "", nil
+}
+
+// importPost 在事务中创建或更新文章，试运行时回滚事务
+func importPost(userID uint, imported *importedPost, dryRun bool) (action string, postID uint, err error) {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var post models.Post
+		found, err := findPostBySlug(tx, imported.Slug, &post)
+		if err != nil {
+			return err
+		}
+
+		// 标题不能与其他文章重复
+		var count int64
+		if err := tx.Model(&models.Post{}).Where("title = ? AND id <> ?", imported.Title, post.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("title already exists")
+		}
+
+		if found {
+			action, err = updateImportedPost(tx, &post, userID, imported)
+		} else {
+			action, err = createImportedPost(tx, &post, userID, imported)
+		}
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			// 试运行时新建的文章会被回滚，不返回其 ID
+			if found {
+				postID = post.ID
+			}
+			return errDryRun
+		}
+		postID = post.ID
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	return action, postID, err
+}
+
+// findPostBySlug 按 slug 查找文章，包括 slug 已变更的文章
+func findPostBySlug(tx *gorm.DB, postSlug string, post *models.Post) (bool, error) {
+	err := tx.Where("slug = ?", postSlug).First(post).Error
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	var history models.PostSlugHistory
+	if err := tx.Where("slug = ?", postSlug).First(&history).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := tx.First(post, history.PostID).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// createImportedPost 创建导入的文章，发布时间取 front matter 中的日期
+func createImportedPost(tx *gorm.DB, post *models.Post, userID uint, imported *importedPost) (string, error) {
+	contentHTML, err := imported.render()
+	if err != nil {
+		return "", err
+	}
+
+	*post = models.Post{
+		Title:       imported.Title,
+		Slug:        imported.Slug,
+		Content:     imported.Content,
+		ContentHTML: contentHTML,
+		UserID:      userID,
+	}
+	if imported.Date != nil {
+		post.CreatedAt = *imported.Date
+	}
+	applyImportedStatus(post, imported)
+
+	if err := tx.Create(post).Error; err != nil {
+		return "", err
+	}
+	if err := createPostRevision(tx, post, userID, "imported"); err != nil {
+		return "", err
+	}
+	if err := appendPostTags(tx, post, imported.Tags); err != nil {
+		return "", err
+	}
+	return models.ImportActionCreate, nil
+}
+
+// render 生成文章的 content_html，HTML 正文只做分段和过滤，Content 保留原文
+func (p *importedPost) render() (string, error) {
+	if p.HTML {
+		return sanitizer.SanitizeHTML(autoParagraph(p.Content)), nil
+	}
+	return markdown.Render(p.Content)
+}
+
+// updateImportedPost 更新已导入的文章，标题、内容、状态和标签均未变化时不做修改
+// 导入以 slug 为唯一键，因此标题变化时不会像编辑文章那样更新 slug
+func updateImportedPost(tx *gorm.DB, post *models.Post, userID uint, imported *importedPost) (string, error) {
+	var tagNames []string
+	if err := tx.Model(&models.Tag{}).
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Where("post_tags.post_id = ?", post.ID).
+		Order("tags.name").
+		Pluck("tags.name", &tagNames).Error; err != nil {
+		return "", err
+	}
+	wantTags := append([]string(nil), imported.Tags...)
+	sort.Strings(wantTags)
+
+	status, publishedAt, scheduledAt := post.Status, post.PublishedAt, post.ScheduledAt
+	contentChanged := post.Title != imported.Title || post.Content != imported.Content
+	applyImportedStatus(post, imported)
+	statusChanged := post.Status != status || !sameTime(post.PublishedAt, publishedAt) || !sameTime(post.ScheduledAt, scheduledAt)
+	if !contentChanged && !statusChanged && strings.Join(tagNames, "\x00") == strings.Join(wantTags, "\x00") {
+		return models.ImportActionUnchanged, nil
+	}
+
+	if contentChanged {
+		if err := savePostContent(tx, post, imported.Title, imported.Content, userID, "imported"); err != nil {
+			return "", err
+		}
+	} else if err := tx.Save(post).Error; err != nil {
+		return "", err
+	}
+
+	if err := tx.Model(post).Association("Tags").Clear(); err != nil {
+		return "", err
+	}
+	if err := appendPostTags(tx, post, imported.Tags); err != nil {
+		return "", err
+	}
+	return models.ImportActionUpdate, nil
+}
+
+// applyImportedStatus 根据 front matter 设置文章状态
+// 草稿保存为草稿，日期在未来的文章进入定时发布，其余文章以该日期作为发布时间
+func applyImportedStatus(post *models.Post, imported *importedPost) {
+	now := time.Now()
+	switch {
+	case imported.Draft:
+		post.Status = models.PostStatusDraft
+		post.PublishedAt = nil
+		post.ScheduledAt = nil
+	case imported.Date != nil && imported.Date.After(now):
+		post.Status = models.PostStatusScheduled
+		post.PublishedAt = nil
+		post.ScheduledAt = imported.Date
+	default:
+		post.Status = models.PostStatusPublished
+		post.ScheduledAt = nil
+		switch {
+		case imported.Date != nil:
+			post.PublishedAt = imported.Date
+		case post.PublishedAt == nil:
+			post.PublishedAt = &now
+		}
+	}
+}
+
+// sameTime 比较两个可能为空的时间
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// addImportItem 记录条目结果并更新统计
+func addImportItem(report *models.ImportReport, item models.ImportItem) {
+	report.Total++
+	switch item.Action {
+	case models.ImportActionCreate:
+		report.Created++
+	case models.ImportActionUpdate:
+		report.Updated++
+	case models.ImportActionUnchanged:
+		report.Unchanged++
+	case models.ImportActionSkip:
+		report.Skipped++
+	case models.ImportActionError:
+		report.Failed++
+	}
+	report.Items = append(report.Items, item)
+}
@@ -0,0 +1,451 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/media"
+	"keep_learning_blog/utils/storage"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MediaService 媒体文件服务结构体
+type MediaService struct {
+	Storage   storage.Backend
+	Upload    *config.UploadConfig
+	Image     *config.ImageConfig
+	Processor *ImageProcessor // 图片衍生图生成器，为空时仅由定期扫描处理
+}
+
+// UploadMedia 上传媒体文件 (insert)
+// 文件类型按内容识别，图片去除元数据后保存；postID 不为空时关联到当前用户的文章
+func (s *MediaService) UploadMedia(ctx context.Context, userID uint, postID *uint, filename string, data []byte) (*models.Media, error) {
+	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"userID":   userID,
+		"filename": filename,
+		"size":     len(data),
+	}))
+
+	// 验证数据合法性
+	if userID == 0 || len(data) == 0 {
+		return nil, errors.New("userID and file cannot be empty")
+	}
+	if int64(len(data)) > s.Upload.MaxFileSize {
+		return nil, fmt.Errorf("file cannot be larger than %d bytes", s.Upload.MaxFileSize)
+	}
+
+	// 按文件内容识别类型，不信任扩展名和请求头
+	mimeType, ext := media.Detect(data)
+	if ext == "" || !slices.Contains(s.Upload.AllowedTypes, mimeType) {
+		log.WithField("mimeType", mimeType).Warn("Rejected upload of unsupported type")
+		return nil, fmt.Errorf("unsupported file type %s", mimeType)
+	}
+
+	// 去除图片元数据并获取尺寸
+	item := &models.Media{
+		UserID:   userID,
+		PostID:   postID,
+		Filename: cleanFilename(filename),
+		MimeType: mimeType,
+	}
+	if media.IsImage(mimeType) {
+		stripped, err := media.StripMetadata(data, mimeType)
+		if err != nil {
+			log.WithError(err).Warn("Failed to process image")
+			return nil, errors.New("invalid image file")
+		}
+		data = stripped
+		if item.Width, item.Height, err = media.Dimensions(data); err != nil {
+			return nil, errors.New("invalid image file")
+		}
+		if item.Width*item.Height > s.Image.MaxPixels {
+			return nil, errors.New("image dimensions too large")
+		}
+		item.DerivativeStatus = models.DerivativeStatusPending
+	}
+
+	checksum := sha256.Sum256(data)
+	item.Checksum = hex.EncodeToString(checksum[:])
+	item.Size = int64(len(data))
+	item.Key = fmt.Sprintf("media/%d/%s/%s%s", userID, time.Now().Format("2006/01"), uuid.New().String(), ext)
+	item.URL = s.Storage.URL(item.Key)
+
+	// 开始事务
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 同一用户的上传串行执行，保证配额检查准确
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('media_quota'), ?)", userID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 检查用户配额
+	used, err := usedStorage(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if used+item.Size > s.Upload.UserQuota {
+		tx.Rollback()
+		return nil, fmt.Errorf("storage quota exceeded: %d of %d bytes used", used, s.Upload.UserQuota)
+	}
+
+	// 检查关联的文章
+	if postID != nil {
+		if err := checkMediaPost(tx, *postID, userID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// 保存文件
+	if err := s.Storage.Put(ctx, item.Key, bytes.NewReader(data), item.Size, mimeType); err != nil {
+		log.WithError(err).Error("Failed to store media file")
+		tx.Rollback()
+		return nil, errors.New("failed to store file")
+	}
+
+	if err := tx.Create(item).Error; err != nil {
+		tx.Rollback()
+		s.removeObject(item.Key)
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		s.removeObject(item.Key)
+		return nil, err
+	}
+
+	// 异步生成衍生图
+	if item.DerivativeStatus == models.DerivativeStatusPending && s.Processor != nil {
+		s.Processor.Enqueue(item.ID)
+	}
+
+	log.WithField("mediaID", item.ID).Info("Media uploaded successfully")
+	return item, nil
+}
+
+// GetUserMedia 分页获取用户上传的媒体文件 (select)
+func (s *MediaService) GetUserMedia(userID uint, page, pageSize int) ([]models.Media, int64, int64, error) {
+	var items []models.Media
+	var total, used int64
+
+	query := db.DB.Model(&models.Media{}).Where("user_id = ?", userID)
+
+	// 获取总数及已用空间
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	used, err := usedStorage(db.DB, userID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// 获取分页数据
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Derivatives").
+		Offset(offset).Limit(pageSize).
+		Order("created_at DESC").
+		Find(&items).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	attachMediaImages(items)
+	return items, total, used, nil
+}
+
+// AttachMedia 将媒体文件关联到文章 (update)，postID 为空时取消关联
+func (s *MediaService) AttachMedia(id, userID uint, postID *uint) (*models.Media, error) {
+	var item models.Media
+	if err := db.DB.First(&item, id).Error; err != nil {
+		return nil, errors.New("media not found")
+	}
+	if item.UserID != userID {
+		return nil, errors.New("unauthorized to update this media")
+	}
+
+	if postID != nil {
+		if err := checkMediaPost(db.DB, *postID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.DB.Model(&item).Update("post_id", postID).Error; err != nil {
+		return nil, err
+	}
+	item.PostID = postID
+	return &item, nil
+}
+
+// DeleteMedia 删除媒体文件 (delete)
+func (s *MediaService) DeleteMedia(ctx context.Context, id, userID uint) error {
+	var item models.Media
+	if err := db.DB.Preload("Derivatives").First(&item, id).Error; err != nil {
+		return errors.New("media not found")
+	}
+	if item.UserID != userID {
+		return errors.New("unauthorized to delete this media")
+	}
+
+	// 取消作为文章封面的引用
+	if err := db.DB.Model(&models.Post{}).Where("cover_media_id = ?", item.ID).
+		UpdateColumn("cover_media_id", nil).Error; err != nil {
+		return err
+	}
+
+	if err := db.DB.Delete(&item).Error; err != nil {
+		return err
+	}
+
+	// 数据库记录已删除，文件删除失败只记录日志
+	keys := []string{item.Key}
+	for _, derivative := range item.Derivatives {
+		keys = append(keys, derivative.Key)
+	}
+	for _, key := range keys {
+		if err := s.Storage.Delete(ctx, key); err != nil {
+			logger.Log.WithError(err).WithField("key", key).Warn("Failed to delete media file")
+		}
+	}
+	return nil
+}
+
+// removeObject 删除已保存但未能写入数据库的文件
+func (s *MediaService) removeObject(key string) {
+	if err := s.Storage.Delete(context.Background(), key); err != nil {
+		logger.Log.WithError(err).WithField("key", key).Warn("Failed to remove orphaned media file")
+	}
+}
+
+// usedStorage 统计用户已用的存储空间，包括原图和生成的衍生图
+func usedStorage(tx *gorm.DB, userID uint) (int64, error) {
+	var original, derivatives int64
+	if err := tx.Model(&models.Media{}).Where("user_id = ?", userID).
+		Select("COALESCE(SUM(size), 0)").Scan(&original).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.MediaDerivative{}).
+		Joins("JOIN media ON media.id = media_derivatives.media_id").
+		Where("media.user_id = ?", userID).
+		Select("COALESCE(SUM(media_derivatives.size), 0)").Scan(&derivatives).Error; err != nil {
+		return 0, err
+	}
+	return original + derivatives, nil
+}
+
+// checkMediaPost 检查文章存在且属于当前用户
+func checkMediaPost(tx *gorm.DB, postID, userID uint) error {
+	var post models.Post
+	if err := tx.Select("id", "user_id").First(&post, postID).Error; err != nil {
+		return errors.New("post not found")
+	}
+	if post.UserID != userID {
+		return errors.New("unauthorized to attach media to this post")
+	}
+	return nil
+}
+
+// cleanFilename 去除原始文件名中的路径，并限制长度
+func cleanFilename(filename string) string {
+	name := filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	runes := []rune(strings.ToValidUTF8(name, ""))
+	for len(string(runes)) > 255 {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+// checkCoverMedia 检查封面图片存在、属于当前用户且为图片
+func checkCoverMedia(tx *gorm.DB, mediaID *uint, userID uint) error {
+	if mediaID == nil {
+		return nil
+	}
+
+	var item models.Media
+	if err := tx.Select("id", "user_id", "mime_type").First(&item, *mediaID).Error; err != nil {
+		return errors.New("cover media not found")
+	}
+	if item.UserID != userID {
+		return errors.New("unauthorized to use this media as cover")
+	}
+	if !media.IsImage(item.MimeType) {
+		return errors.New("cover media must be an image")
+	}
+	return nil
+}
+
+// linkCoverMedia 将尚未关联文章的封面图片关联到文章
+func linkCoverMedia(tx *gorm.DB, post *models.Post) error {
+	if post.CoverMediaID == nil {
+		return nil
+	}
+	return tx.Model(&models.Media{}).Where("id = ? AND post_id IS NULL", *post.CoverMediaID).
+		Update("post_id", post.ID).Error
+}
+
+// attachPostImages 为文章填充封面图片的衍生图
+// withInline 为 true 时同时返回正文图片的衍生图，并为正文 HTML 中的图片添加 srcset
+// 查询失败只记录日志，不影响文章本身的返回
+func attachPostImages(posts []*models.Post, withInline bool) {
+	if len(posts) == 0 {
+		return
+	}
+
+	var mediaIDs, postIDs []uint
+	for _, post := range posts {
+		if post.CoverMediaID != nil {
+			mediaIDs = append(mediaIDs, *post.CoverMediaID)
+		}
+		postIDs = append(postIDs, post.ID)
+	}
+
+	query := db.DB.Preload("Derivatives")
+	switch {
+	case withInline && len(mediaIDs) > 0:
+		query = query.Where("id IN ? OR post_id IN ?", mediaIDs, postIDs)
+	case withInline:
+		query = query.Where("post_id IN ?", postIDs)
+	case len(mediaIDs) > 0:
+		query = query.Where("id IN ?", mediaIDs)
+	default:
+		return
+	}
+
+	var items []models.Media
+	if err := query.Where("derivative_status <> ''").Order("id").Find(&items).Error; err != nil {
+		logger.Log.WithError(err).Warn("Failed to load post images")
+		return
+	}
+
+	byID := make(map[uint]*models.ResponsiveImage, len(items))
+	byPost := make(map[uint][]*models.ResponsiveImage)
+	for i := range items {
+		image := responsiveImage(&items[i])
+		byID[items[i].ID] = image
+		if items[i].PostID != nil {
+			byPost[*items[i].PostID] = append(byPost[*items[i].PostID], image)
+		}
+	}
+
+	for _, post := range posts {
+		if post.CoverMediaID != nil {
+			post.CoverImage = byID[*post.CoverMediaID]
+		}
+		if !withInline {
+			continue
+		}
+
+		byURL := make(map[string]*models.ResponsiveImage)
+		for _, image := range byPost[post.ID] {
+			post.Images = append(post.Images, *image)
+			byURL[image.Original] = image
+		}
+		post.ContentHTML = addImageSrcset(post.ContentHTML, byURL)
+	}
+}
+
+// attachMediaImages 为媒体文件填充衍生图
+func attachMediaImages(items []models.Media) {
+	for i := range items {
+		if len(items[i].Derivatives) > 0 {
+			items[i].Responsive = responsiveImage(&items[i])
+		}
+	}
+}
+
+// responsiveImage 根据衍生图构建响应式图片结构，默认显示中等尺寸的 JPEG，尚未生成衍生图时使用原图
+func responsiveImage(item *models.Media) *models.ResponsiveImage {
+	image := &models.ResponsiveImage{
+		MediaID:  item.ID,
+		Src:      item.URL,
+		Width:    item.Width,
+		Height:   item.Height,
+		Sources:  []models.ImageSource{},
+		Original: item.URL,
+	}
+
+	derivatives := append([]models.MediaDerivative(nil), item.Derivatives...)
+	sort.Slice(derivatives, func(i, j int) bool { return derivatives[i].Width < derivatives[j].Width })
+
+	// WebP 在前，浏览器按顺序选择第一个支持的格式
+	for _, format := range []string{"webp", "jpeg"} {
+		var candidates []string
+		for _, derivative := range derivatives {
+			if derivative.Format != format {
+				continue
+			}
+			candidates = append(candidates, fmt.Sprintf("%s %dw", derivative.URL, derivative.Width))
+			if format == "jpeg" && (image.Src == item.URL || derivative.Variant == "medium") {
+				image.Src = derivative.URL
+				image.Width = derivative.Width
+				image.Height = derivative.Height
+			}
+		}
+		if len(candidates) > 0 {
+			image.Sources = append(image.Sources, models.ImageSource{
+				Type:   "image/" + format,
+				Srcset: strings.Join(candidates, ", "),
+			})
+		}
+	}
+	return image
+}
+
+// imgTagPattern 匹配正文 HTML 中的图片标签及其 src 属性
+var imgTagPattern = regexp.MustCompile(`<img\s[^>]*?src="([^"]*)"[^>]*>`)
+
+// imageSizes 正文图片的 sizes 属性，正文最大宽度约为 800px
+const imageSizes = "(max-width: 800px) 100vw, 800px"
+
+// addImageSrcset 为正文中引用已生成衍生图的图片添加 JPEG srcset，避免直接加载原图
+func addImageSrcset(content string, images map[string]*models.ResponsiveImage) string {
+	if len(images) == 0 {
+		return content
+	}
+
+	return imgTagPattern.ReplaceAllStringFunc(content, func(tag string) string {
+		match := imgTagPattern.FindStringSubmatch(tag)
+		image, ok := images[html.UnescapeString(match[1])]
+		if !ok || strings.Contains(tag, "srcset=") {
+			return tag
+		}
+
+		var srcset string
+		for _, source := range image.Sources {
+			if source.Type == "image/jpeg" {
+				srcset = source.Srcset
+			}
+		}
+		if srcset == "" {
+			return tag
+		}
+
+		replaced := strings.Replace(tag, `src="`+match[1]+`"`, fmt.Sprintf(`src="%s" srcset="%s" sizes="%s" loading="lazy"`,
+			html.EscapeString(image.Src), html.EscapeString(srcset), imageSizes), 1)
+		return replaced
+	})
+}
@@ -0,0 +1,444 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"io"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"strings"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/totp"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidMFAToken 两步验证令牌无效、已使用或已过期
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+	// ErrInvalidMFACode 验证码或恢复码错误
+	ErrInvalidMFACode = errors.New("invalid verification code")
+	// ErrTooManyMFAAttempts 验证码错误次数过多，两步验证令牌已失效
+	ErrTooManyMFAAttempts = errors.New("too many verification attempts, please login again")
+	// ErrMFAAlreadyEnabled 已启用两步验证
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	// ErrMFANotEnabled 未启用两步验证
+	ErrMFANotEnabled = errors.New("two-factor authentication not enabled")
+	// ErrMFAEnrollmentNotFound 没有待确认的绑定或绑定已过期
+	ErrMFAEnrollmentNotFound = errors.New("no pending two-factor enrollment, please start again")
+	// ErrMFARequiredByRole 用户的角色要求两步验证，不能停用
+	ErrMFARequiredByRole = errors.New("two-factor authentication is required by your role")
+)
+
+// recoveryCodeEncoding 恢复码使用小写 Base32 字符，避免易混淆的 0/1/8/9
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// MFAService 两步验证服务结构体
+type MFAService struct {
+	MFA           *config.MFAConfig
+	EncryptionKey string // TOTP 密钥的加密密钥
+}
+
+// LoginRequirement 返回用户是否已启用两步验证，以及其角色是否要求两步验证 (select)
+func (s *MFAService) LoginRequirement(user *models.User) (enabled, required bool, err error) {
+	required, err = s.requiredByRole(user.ID)
+	if err != nil {
+		return false, false, errors.New("failed to check two-factor requirement")
+	}
+	return user.TOTPEnabledAt != nil, required, nil
+}
+
+// CreateChallenge 密码验证通过后创建两步验证令牌，令牌只以哈希形式保存在 Redis 中
+func (s *MFAService) CreateChallenge(ctx context.Context, userID uint) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	if err := db.SaveMFAChallenge(ctx, hashResetToken(token), userID, s.MFA.ChallengeTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// BeginChallengeEnrollment 登录时角色要求两步验证而用户尚未启用，凭两步验证令牌生成待确认的密钥 (select)
+func (s *MFAService) BeginChallengeEnrollment(ctx context.Context, token string) (*models.TOTPEnrollment, error) {
+	userID, err := db.GetMFAChallengeUser(ctx, hashResetToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, ErrInvalidMFAToken
+	}
+	return s.BeginEnrollment(ctx, userID)
+}
+
+// VerifyChallenge 校验两步验证令牌和验证码，通过后令牌失效并返回用户 (update)
+// 用户尚未启用两步验证时，验证码用于确认登录过程中的绑定，同时返回新生成的恢复码
+func (s *MFAService) VerifyChallenge(ctx context.Context, token, code string) (*models.User, []string, error) {
+	tokenHash := hashResetToken(token)
+	userID, attempts, err := db.AttemptMFAChallenge(ctx, tokenHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userID == 0 {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	if attempts > int64(s.MFA.MaxAttempts) {
+		if _, err := db.DeleteMFAChallenge(ctx, tokenHash); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrTooManyMFAAttempts
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidMFAToken
+		}
+		return nil, nil, err
+	}
+
+	var recoveryCodes []string
+	if user.TOTPEnabledAt != nil {
+		if err := s.verifyCode(ctx, &user, code, true); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if recoveryCodes, err = s.ConfirmEnrollment(ctx, user.ID, code); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 令牌只能兑换一次
+	deleted, err := db.DeleteMFAChallenge(ctx, tokenHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !deleted {
+		return nil, nil, ErrInvalidMFAToken
+	}
+	return &user, recoveryCodes, nil
+}
+
+// GetStatus 获取用户的两步验证状态 (select)
+func (s *MFAService) GetStatus(userID uint) (*models.MFAStatus, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	required, err := s.requiredByRole(userID)
+	if err != nil {
+		return nil, errors.New("failed to check two-factor requirement")
+	}
+
+	var remaining int64
+	if err := db.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).Count(&remaining).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.MFAStatus{
+		Enabled:                user.TOTPEnabledAt != nil,
+		EnabledAt:              user.TOTPEnabledAt,
+		Required:               required,
+		RecoveryCodesRemaining: remaining,
+	}, nil
+}
+
+// BeginEnrollment 生成新的 TOTP 密钥，用户使用验证器应用扫码并提交验证码确认后才会启用 (select)
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID uint) (*models.TOTPEnrollment, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SetTOTPEnrollment(ctx, userID, sealed, s.MFA.EnrollmentTTL); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:    secret,
+		URI:       totp.ProvisioningURI(s.MFA.Issuer, user.Username, secret),
+		ExpiresAt: time.Now().Add(s.MFA.EnrollmentTTL),
+	}, nil
+}
+
+// ConfirmEnrollment 使用验证码确认待绑定的密钥并启用两步验证，返回新生成的恢复码 (update)
+// 恢复码原文只在此时返回一次，数据库中只保存哈希值
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	sealed, err := db.GetTOTPEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sealed == "" {
+		return nil, ErrMFAEnrollmentNotFound
+	}
+	secret, err := s.openSecret(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTOTP(ctx, userID, secret, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := generateRecoveryCodes(s.MFA.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ? AND totp_enabled_at IS NULL", userID).
+			Updates(map[string]interface{}{
+				"totp_secret":     sealed,
+				"totp_enabled_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAAlreadyEnabled
+		}
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.DeleteTOTPEnrollment(ctx, userID); err != nil {
+		logger.Log.WithError(err).Warn("Failed to delete TOTP enrollment")
+	}
+
+	logger.Log.WithField("user_id", userID).Info("Two-factor authentication enabled")
+	return codes, nil
+}
+
+// Disable 停用两步验证并删除恢复码，需要提交验证码或恢复码 (update)
+func (s *MFAService) Disable(ctx context.Context, userID uint, code string) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return errors.New("user not found")
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	required, err := s.requiredByRole(userID)
+	if err != nil {
+		return errors.New("failed to check two-factor requirement")
+	}
+	if required {
+		return ErrMFARequiredByRole
+	}
+
+	if err := s.verifyCode(ctx, &user, code, true); err != nil {
+		return err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("Two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部失效，需要提交验证器应用中的验证码 (update)
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.verifyCode(ctx, &user, code, false); err != nil {
+		return nil, err
+	}
+
+	codes, err := generateRecoveryCodes(s.MFA.RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	}); err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("Recovery codes regenerated")
+	return codes, nil
+}
+
+// requiredByRole 检查用户是否拥有要求两步验证的角色
+func (s *MFAService) requiredByRole(userID uint) (bool, error) {
+	var count int64
+	err := db.DB.Model(&models.Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.require_mfa = ?", userID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// verifyCode 校验用户提交的 TOTP 验证码，allowRecovery 为 true 时也接受未使用的恢复码
+func (s *MFAService) verifyCode(ctx context.Context, user *models.User, code string, allowRecovery bool) error {
+	secret, err := s.openSecret(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkTOTP(ctx, user.ID, secret, code)
+	if err == nil || !errors.Is(err, ErrInvalidMFACode) || !allowRecovery {
+		return err
+	}
+
+	used, err := useRecoveryCode(user.ID, code)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	logger.Log.WithField("user_id", user.ID).Warn("Recovery code used")
+	return nil
+}
+
+// checkTOTP 校验 TOTP 验证码，同一时间步的验证码只能使用一次
+func (s *MFAService) checkTOTP(ctx context.Context, userID uint, secret, code string) error {
+	step, ok := totp.Validate(secret, code, time.Now(), s.MFA.Skew)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// 标记保留到验证码在允许的时钟偏差内都不再有效为止
+	window := time.Duration(2*s.MFA.Skew+1) * totp.Period * time.Second
+	fresh, err := db.MarkTOTPStepUsed(ctx, userID, step, window)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// generateRecoveryCodes 生成指定数量的恢复码，格式为 xxxxx-xxxxx
+func generateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := recoveryCodeEncoding.EncodeToString(raw)[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes 删除用户的所有恢复码并保存新恢复码的哈希值
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	records := make([]models.RecoveryCode, len(codes))
+	for i, code := range codes {
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+	return tx.Create(&records).Error
+}
+
+// useRecoveryCode 使用恢复码，恢复码不存在或已使用时返回 false
+func useRecoveryCode(userID uint, code string) (bool, error) {
+	result := db.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		UpdateColumn("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// hashRecoveryCode 计算恢复码的哈希值，忽略大小写、空格和分隔符
+func hashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+	return hashResetToken(normalized)
+}
+
+// sealSecret 使用 AES-GCM 加密 TOTP 密钥，数据库和 Redis 中不保存密钥原文
+func (s *MFAService) sealSecret(secret string) (string, error) {
+	gcm, err := s.cipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// openSecret 解密 TOTP 密钥
+func (s *MFAService) openSecret(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := s.cipher()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	secret, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// cipher 根据加密密钥创建 AES-GCM
+func (s *MFAService) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher([]byte(s.EncryptionKey))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/utils/totp"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestCheckTOTPReplay 同一时间步的验证码只能使用一次，其他时间步的验证码不受影响
+func TestCheckTOTPReplay(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	service := &MFAService{MFA: &config.MFAConfig{Skew: 1}}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// 临近时间步结束时等到下一时间步，避免测试期间时间步变化
+	now := time.Now()
+	if remaining := time.Unix((totp.Step(now)+1)*totp.Period, 0).Sub(now); remaining < 2*time.Second {
+		time.Sleep(remaining)
+	}
+	current := totp.Step(time.Now())
+
+	code, err := totp.Code(secret, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.checkTOTP(ctx, 1, secret, code); err != nil {
+		t.Fatalf("first use rejected: %v", err)
+	}
+	if err := service.checkTOTP(ctx, 1, secret, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed code: err = %v, want ErrInvalidMFACode", err)
+	}
+
+	// 其他用户使用相同时间步的验证码不受影响
+	if err := service.checkTOTP(ctx, 2, secret, code); err != nil {
+		t.Fatalf("other user rejected: %v", err)
+	}
+
+	// 时钟偏差范围内上一时间步的验证码仍可使用一次
+	previous, err := totp.Code(secret, current-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.checkTOTP(ctx, 1, secret, previous); err != nil {
+		t.Fatalf("previous step rejected: %v", err)
+	}
+	if err := service.checkTOTP(ctx, 1, secret, previous); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("replayed previous step: err = %v, want ErrInvalidMFACode", err)
+	}
+
+	// 标记保留到验证码在时钟偏差范围内失效为止
+	if ttl := mr.TTL(fmt.Sprintf("%s1:%d", db.TOTPUsedStepPrefix, current)); ttl != 3*totp.Period*time.Second {
+		t.Fatalf("used step TTL = %v, want %v", ttl, 3*totp.Period*time.Second)
+	}
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"strings"
+	"sync"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/slug"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOIDCProviderNotFound 未配置的身份提供方
+	ErrOIDCProviderNotFound = errors.New("identity provider not found")
+	// ErrOIDCProviderUnavailable 身份提供方发现失败
+	ErrOIDCProviderUnavailable = errors.New("identity provider unavailable")
+	// ErrInvalidOIDCState 授权请求的 state 无效、已使用或已过期
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrOIDCEmailRequired 身份提供方未返回邮箱，无法创建用户
+	ErrOIDCEmailRequired = errors.New("identity provider did not return an email address")
+	// ErrOIDCEmailTaken 邮箱已被未关联的本地用户使用
+	ErrOIDCEmailTaken = errors.New("email already registered, please login with password")
+	// ErrOIDCAuthorizationFailed 身份提供方拒绝授权或令牌校验失败
+	ErrOIDCAuthorizationFailed = errors.New("identity provider authorization failed")
+	// ErrInvalidOIDCLoginCode 登录兑换码无效、已使用或已过期
+	ErrInvalidOIDCLoginCode = errors.New("invalid or expired login code")
+)
+
+// maxUsernameLength 自动创建用户时用户名的最大长度（不含去重后缀）
+const maxUsernameLength = 56
+
+// OIDCService OpenID Connect 登录服务结构体
+// 使用授权码模式并启用 PKCE，ID Token 通过身份提供方公布的 JWKS 校验
+type OIDCService struct {
+	OIDC *config.OIDCConfig
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider // 已完成发现的身份提供方
+	discovery singleflight.Group       // 合并同一身份提供方的并发发现请求
+}
+
+// oidcProvider 完成发现的身份提供方
+type oidcProvider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcState 授权请求期间保存在 Redis 中的数据
+type oidcState struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"` // PKCE code_verifier
+	Nonce        string `json:"nonce"`
+}
+
+// oidcClaims ID Token 中使用的声明
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Nonce             string `json:"nonce"`
+}
+
+// NewOIDCService 创建 OpenID Connect 登录服务
+func NewOIDCService(cfg *config.OIDCConfig) *OIDCService {
+	return &OIDCService{
+		OIDC:      cfg,
+		providers: make(map[string]*oidcProvider),
+	}
+}
+
+// AuthCodeURL 生成跳转到身份提供方的授权地址，state、nonce 及 PKCE code_verifier 保存在 Redis 中
+// 返回的 binding 需写入发起登录的浏览器的 Cookie，Redis 中只保存其哈希值，回调时校验以防止登录 CSRF
+func (s *OIDCService) AuthCodeURL(ctx context.Context, providerName string) (authURL, binding string, err error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+	if binding, err = randomToken(); err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	data, err := json.Marshal(oidcState{Provider: providerName, CodeVerifier: verifier, Nonce: nonce})
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.SetOIDCState(ctx, state, hashResetToken(binding), data, s.OIDC.StateTTL); err != nil {
+		return "", "", err
+	}
+
+	return provider.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), binding, nil
+}
+
+// Callback 处理身份提供方的授权回调：兑换授权码、校验 ID Token，并返回关联的用户 (insert/update)
+// binding 为发起登录时写入浏览器的 Cookie，与 state 不匹配时拒绝且不消耗 state
+// 按提供方和 subject 查找已关联的用户；开启 LinkVerifiedEmail 时按已验证邮箱关联现有用户（linked 为 true），否则以默认角色自动创建用户
+func (s *OIDCService) Callback(ctx context.Context, providerName, state, binding, code string) (user *models.User, linked bool, err error) {
+	if binding == "" {
+		return nil, false, ErrInvalidOIDCState
+	}
+	data, err := db.TakeOIDCState(ctx, state, hashResetToken(binding))
+	if err != nil {
+		return nil, false, err
+	}
+	if data == nil {
+		return nil, false, ErrInvalidOIDCState
+	}
+	var saved oidcState
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Provider != providerName {
+		return nil, false, ErrInvalidOIDCState
+	}
+
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	log := logger.Log.WithField("provider", providerName)
+
+	// 兑换授权码
+	token, err := provider.oauth2.Exchange(ctx, code, oauth2.VerifierOption(saved.CodeVerifier))
+	if err != nil {
+		log.WithError(err).Warn("Failed to exchange authorization code")
+		return nil, false, ErrOIDCAuthorizationFailed
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		log.Warn("Token response does not contain id_token")
+		return nil, false, ErrOIDCAuthorizationFailed
+	}
+
+	// 校验 ID Token 的签名、签发者、受众、有效期及 nonce
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		log.WithError(err).Warn("Failed to verify id token")
+		return nil, false, ErrOIDCAuthorizationFailed
+	}
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, false, ErrOIDCAuthorizationFailed
+	}
+	if claims.Nonce != saved.Nonce {
+		log.Warn("ID token nonce mismatch")
+		return nil, false, ErrOIDCAuthorizationFailed
+	}
+
+	user, linked, err = s.resolveUser(providerName, idToken.Subject, &claims)
+	if err != nil {
+		return nil, false, err
+	}
+
+	log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id": user.ID,
+		"subject": idToken.Subject,
+	})).Info("User authenticated with identity provider")
+	return user, linked, nil
+}
+
+// resolveUser 查找或创建外部身份关联的用户，按邮箱关联现有用户时 linked 为 true
+func (s *OIDCService) resolveUser(providerName, subject string, claims *oidcClaims) (*models.User, bool, error) {
+	var user models.User
+	linked := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// 已关联的外部身份
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", providerName, subject).First(&identity).Error
+		if err == nil {
+			if err := tx.Model(&identity).Updates(map[string]interface{}{
+				"email":         claims.Email,
+				"last_login_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.First(&user, identity.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email == "" {
+			return ErrOIDCEmailRequired
+		}
+
+		// 身份提供方已验证邮箱时关联邮箱相同的现有用户
+		err = gorm.ErrRecordNotFound
+		if s.OIDC.LinkVerifiedEmail && claims.EmailVerified {
+			err = tx.Where("LOWER(email) = LOWER(?)", claims.Email).First(&user).Error
+		}
+		switch {
+		case err == nil:
+			linked = true
+			// 邮箱已由身份提供方验证
+			if user.EmailVerifiedAt == nil {
+				if err := tx.Model(&user).UpdateColumn("email_verified_at", now).Error; err != nil {
+					return err
+				}
+				user.EmailVerifiedAt = &now
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := provisionUser(tx, &user, claims); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    providerName,
+			Subject:     subject,
+			Email:       claims.Email,
+			LastLoginAt: now,
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if linked {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"provider": providerName,
+			"subject":  subject,
+			"user_id":  user.ID,
+		})).Warn("Identity provider account linked to existing user by verified email")
+	}
+	return &user, linked, nil
+}
+
+// CreateLoginCode 为完成回调的用户签发一次性登录兑换码，前端通过兑换接口换取令牌
+// 兑换码只以哈希形式保存在 Redis 中
+func (s *OIDCService) CreateLoginCode(ctx context.Context, userID uint) (string, error) {
+	code, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	if err := db.SetOIDCLoginCode(ctx, hashResetToken(code), userID, s.OIDC.LoginCodeTTL); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeLoginCode 兑换一次性登录兑换码，返回对应的用户 (select)
+func (s *OIDCService) ExchangeLoginCode(ctx context.Context, code string) (*models.User, error) {
+	userID, err := db.TakeOIDCLoginCode(ctx, hashResetToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, ErrInvalidOIDCLoginCode
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidOIDCLoginCode
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// provisionUser 以默认角色创建外部身份对应的用户，用户名取自身份提供方，重复时追加后缀
+// 用户没有可用的本地密码，可通过找回密码流程设置
+func provisionUser(tx *gorm.DB, user *models.User, claims *oidcClaims) error {
+	var exists int64
+	if err := tx.Model(&models.User{}).Where("LOWER(email) = LOWER(?)", claims.Email).Count(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrOIDCEmailTaken
+	}
+
+	base := claims.PreferredUsername
+	if base == "" {
+		base = claims.Name
+	}
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	if runes := []rune(strings.TrimSpace(base)); len(runes) > maxUsernameLength {
+		base = string(runes[:maxUsernameLength])
+	} else {
+		base = string(runes)
+	}
+	username, err := slug.Unique(base, func(candidate string) (bool, error) {
+		var count int64
+		err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error
+		return count > 0, err
+	})
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := randomPasswordHash()
+	if err != nil {
+		return err
+	}
+
+	*user = models.User{
+		Username: username,
+		Password: passwordHash,
+		Email:    claims.Email,
+	}
+	if claims.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+
+	// 为新用户分配默认角色
+	var defaultRole models.Role
+	if err := tx.Where("is_default = ?", true).First(&defaultRole).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(user).Association("Roles").Append(&defaultRole); err != nil {
+		return err
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	})).Info("User provisioned from identity provider")
+	return nil
+}
+
+// provider 获取身份提供方，首次使用时通过发现文档初始化，发现失败时下次请求重试
+// 发现请求不持有服务锁，一个身份提供方不可达时不会阻塞其他身份提供方的登录
+func (s *OIDCService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	s.mu.Lock()
+	provider, ok := s.providers[name]
+	s.mu.Unlock()
+	if ok {
+		return provider, nil
+	}
+
+	result, err, _ := s.discovery.Do(name, func() (interface{}, error) {
+		return s.discover(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*oidcProvider), nil
+}
+
+// discover 通过发现文档初始化身份提供方，仅缓存成功的结果
+func (s *OIDCService) discover(ctx context.Context, name string) (*oidcProvider, error) {
+	s.mu.Lock()
+	provider, ok := s.providers[name]
+	s.mu.Unlock()
+	if ok {
+		return provider, nil
+	}
+
+	var cfg *config.OIDCProviderConfig
+	for i := range s.OIDC.Providers {
+		if s.OIDC.Providers[i].Name == name {
+			cfg = &s.OIDC.Providers[i]
+			break
+		}
+	}
+	if cfg == nil {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	// 发现请求不随单个 HTTP 请求取消，避免客户端断开导致发现失败
+	discovered, err := oidc.NewProvider(context.WithoutCancel(ctx), cfg.Issuer)
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"provider": name,
+			"issuer":   cfg.Issuer,
+			"error":    err.Error(),
+		})).Error("Failed to discover identity provider")
+		return nil, ErrOIDCProviderUnavailable
+	}
+
+	provider = &oidcProvider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier: discovered.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}
+	s.mu.Lock()
+	s.providers[name] = provider
+	s.mu.Unlock()
+	return provider, nil
+}
+
+// randomToken 生成随机的 URL 安全字符串
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
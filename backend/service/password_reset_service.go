@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/mail"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken 重置令牌无效、已使用或已过期
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService 找回密码服务结构体
+type PasswordResetService struct {
+	Mailer        mail.Sender
+	Site          *config.SiteConfig
+	PasswordReset *config.PasswordResetConfig
+	RateLimit     *config.RateLimitConfig
+}
+
+// forgotPasswordTimeout 后台发送重置邮件的超时时间
+const forgotPasswordTimeout = time.Minute
+
+// ForgotPassword 受理找回密码请求，查询用户和发送邮件在后台进行 (select)
+// 接口对已注册和未注册的邮箱返回相同的响应且耗时相同，避免泄露注册信息；同一邮箱超过限流次数的请求直接忽略
+func (s *PasswordResetService) ForgotPassword(email string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), forgotPasswordTimeout)
+		defer cancel()
+		if err := s.sendResetLink(ctx, strings.ToLower(strings.TrimSpace(email))); err != nil {
+			logger.Log.WithError(err).Error("Failed to process forgot password request")
+		}
+	}()
+}
+
+// sendResetLink 向邮箱对应的用户发送密码重置链接
+// 令牌只以哈希形式保存在 Redis 中
+func (s *PasswordResetService) sendResetLink(ctx context.Context, email string) error {
+	emailHash := hashResetToken(email)
+	allowed, err := db.AllowPasswordResetEmail(ctx, emailHash, s.RateLimit.PasswordResetEmailLimit, s.RateLimit.PasswordResetEmailDuration)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		logger.Log.WithField("email_hash", emailHash).Warn("Password reset email rate limit exceeded")
+		return nil
+	}
+
+	var user models.User
+	if err := db.DB.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Log.WithField("email_hash", emailHash).Info("Password reset requested for unknown email")
+			return nil
+		}
+		return err
+	}
+
+	// 令牌记录签发时的令牌版本号，任意一次重置成功后其余未使用的令牌随之失效
+	version, err := db.GetTokenVersion(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	if err := db.SetPasswordResetToken(ctx, hashResetToken(token), fmt.Sprintf("%d:%d", user.ID, version), s.PasswordReset.TTL); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s?token=%s", s.PasswordReset.URL, url.QueryEscape(token))
+	err = s.Mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("重置您在 %s 的密码", s.Site.Title),
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置 %s 账号密码的请求。请在 %d 分钟内打开以下链接设置新密码，链接只能使用一次：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会被修改。\n",
+			user.Username, s.Site.Title, int(s.PasswordReset.TTL.Minutes()), link),
+	})
+	if err != nil {
+		logger.Log.WithFields(logger.Fields(map[string]interface{}{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		})).Error("Failed to send password reset email")
+		return err
+	}
+
+	logger.Log.WithField("user_id", user.ID).Info("Password reset email sent")
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码 (update)
+// 令牌使用后立即删除；重置成功后递增用户令牌版本号并删除所有会话，使该用户所有访问令牌和刷新令牌失效，个人访问令牌一并撤销
+// 能收到重置邮件说明用户拥有该邮箱，未验证的邮箱同时标记为已验证
+func (s *PasswordResetService) ResetPassword(ctx context.Context, token, password string) error {
+	if password == "" {
+		return errors.New("password cannot be empty")
+	}
+	if len(password) > 64 {
+		return errors.New("password cannot be longer than 64 characters")
+	}
+
+	value, err := db.TakePasswordResetToken(ctx, hashResetToken(token))
+	if err != nil {
+		return err
+	}
+	userID, version, ok := parseResetTokenValue(value)
+	if !ok {
+		return ErrInvalidResetToken
+	}
+	currentVersion, err := db.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if version != currentVersion {
+		return ErrInvalidResetToken
+	}
+
+	// 加密密码
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	result := db.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password":          string(hashedPassword),
+		"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidResetToken
+	}
+
+	if err := db.IncrTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+	if err := db.DeleteUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	// 个人访问令牌同时撤销
+	if err := db.DB.Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+		return err
+	}
+
+	logger.Log.WithField("user_id", userID).Info("Password reset successfully")
+	return nil
+}
+
+// hashResetToken 计算重置令牌的哈希值，Redis 中不保存令牌原文
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseResetTokenValue 解析 Redis 中保存的 "用户ID:令牌版本号"
+func parseResetTokenValue(value string) (uint, int64, bool) {
+	id, version, found := strings.Cut(value, ":")
+	if !found {
+		return 0, 0, false
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	tokenVersion, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(userID), tokenVersion, true
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"strings"
+	"time"
+
+	"keep_learning_blog/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPersonalAccessTokenNotFound 令牌不存在或不属于当前用户
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	// ErrInvalidPersonalAccessToken 令牌无效或已过期
+	ErrInvalidPersonalAccessToken = errors.New("invalid or expired personal access token")
+	// ErrInvalidTokenScopes 令牌范围包含用户未拥有的权限
+	ErrInvalidTokenScopes = errors.New("scopes must be a subset of your permissions")
+	// ErrInvalidTokenExpiry 过期时间无效
+	ErrInvalidTokenExpiry = errors.New("invalid token expiry")
+	// ErrTooManyPersonalAccessTokens 令牌数量达到上限
+	ErrTooManyPersonalAccessTokens = errors.New("too many personal access tokens")
+)
+
+const (
+	// PersonalAccessTokenPrefix 个人访问令牌的固定前缀，用于与 JWT 区分及密钥扫描工具识别
+	PersonalAccessTokenPrefix = "klb_pat_"
+	// tokenDisplayLength 保存用于展示的令牌开头字符数
+	tokenDisplayLength = 12
+	// lastUsedInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+	lastUsedInterval = time.Minute
+)
+
+// PersonalAccessTokenService 个人访问令牌服务结构体
+type PersonalAccessTokenService struct {
+	PersonalAccessToken *config.PersonalAccessTokenConfig
+}
+
+// IsPersonalAccessToken 判断令牌是否为个人访问令牌
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// CreateToken 为用户创建个人访问令牌，返回令牌记录和令牌原文，原文只在创建时返回一次 (insert)
+// 令牌范围必须是用户当前拥有的权限编码的子集
+func (s *PersonalAccessTokenService) CreateToken(userID uint, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	// 检查过期时间
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", ErrInvalidTokenExpiry
+	}
+	if maxTTL := s.PersonalAccessToken.MaxTTL; maxTTL > 0 {
+		if expiresAt == nil || expiresAt.After(now.Add(maxTTL)) {
+			return nil, "", ErrInvalidTokenExpiry
+		}
+	}
+
+	// 检查令牌范围
+	userPermissions, err := (&UserService{}).GetUserPermissions(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	owned := make(map[string]models.Permission, len(userPermissions))
+	for _, permission := range userPermissions {
+		owned[permission.Code] = permission
+	}
+	permissions := make([]models.Permission, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, code := range scopes {
+		permission, ok := owned[code]
+		if !ok {
+			return nil, "", ErrInvalidTokenScopes
+		}
+		if !seen[code] {
+			seen[code] = true
+			permissions = append(permissions, permission)
+		}
+	}
+
+	random, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := PersonalAccessTokenPrefix + random
+
+	token := models.PersonalAccessToken{
+		UserID:      userID,
+		Name:        name,
+		TokenHash:   hashResetToken(raw),
+		TokenPrefix: raw[:tokenDisplayLength],
+		ExpiresAt:   expiresAt,
+		Permissions: permissions,
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// 检查令牌数量
+		if s.PersonalAccessToken.MaxPerUser > 0 {
+			var count int64
+			if err := tx.Model(&models.PersonalAccessToken{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= int64(s.PersonalAccessToken.MaxPerUser) {
+				return ErrTooManyPersonalAccessTokens
+			}
+		}
+
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	token.Scopes = scopeCodes(token.Permissions)
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":  userID,
+		"token_id": token.ID,
+		"scopes":   token.Scopes,
+	})).Info("Personal access token created")
+	return &token, raw, nil
+}
+
+// ListTokens 获取用户的所有个人访问令牌 (select)
+func (s *PersonalAccessTokenService) ListTokens(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	if err := db.DB.Preload("Permissions").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, errors.New("failed to get personal access tokens")
+	}
+
+	for i := range tokens {
+		tokens[i].Scopes = scopeCodes(tokens[i].Permissions)
+	}
+	return tokens, nil
+}
+
+// RevokeToken 撤销用户的指定个人访问令牌 (delete)
+func (s *PersonalAccessTokenService) RevokeToken(userID, tokenID uint) error {
+	result := db.DB.Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{ID: tokenID})
+	if result.Error != nil {
+		return errors.New("failed to revoke personal access token")
+	}
+	if result.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":  userID,
+		"token_id": tokenID,
+	})).Info("Personal access token revoked")
+	return nil
+}
+
+// Authenticate 校验个人访问令牌，返回令牌记录（含所属用户和令牌范围）
+func (s *PersonalAccessTokenService) Authenticate(raw string) (*models.PersonalAccessToken, error) {
+	if !IsPersonalAccessToken(raw) {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+
+	var token models.PersonalAccessToken
+	err := db.DB.Preload("User").Preload("Permissions").
+		Where("token_hash = ?", hashResetToken(raw)).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+	token.Scopes = scopeCodes(token.Permissions)
+
+	// 更新最近使用时间
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedInterval {
+		if err := db.DB.Model(&token).UpdateColumn("last_used_at", now).Error; err != nil {
+			logger.Log.WithError(err).Warn("Failed to update personal access token last used")
+		}
+	}
+
+	return &token, nil
+}
+
+// scopeCodes 返回权限编码列表
+func scopeCodes(permissions []models.Permission) []string {
+	codes := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		codes = append(codes, permission.Code)
+	}
+	return codes
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/utils/logger"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostScheduler 文章定时发布调度器
+// 多实例部署时通过 Redis 锁保证同一时刻只有一个实例执行发布
+type PostScheduler struct {
+	config      *config.SchedulerConfig
+	postService PostService
+	instanceID  string
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewPostScheduler 创建文章定时发布调度器
+func NewPostScheduler(cfg *config.Config) *PostScheduler {
+	return &PostScheduler{
+		config:     &cfg.Scheduler,
+		instanceID: uuid.New().String(),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start 启动调度器，启动时立即执行一次以补发停机期间错过的文章
+func (s *PostScheduler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		s.runOnce()
+		for {
+			select {
+			case <-ticker.C:
+				s.runOnce()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	logger.Log.WithField("interval", s.config.Interval.String()).Info("Post scheduler started")
+}
+
+// Stop 停止调度器并等待当前任务结束
+func (s *PostScheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+	logger.Log.Info("Post scheduler stopped")
+}
+
+// runOnce 获取锁后发布所有到期文章
+func (s *PostScheduler) runOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.LockTTL)
+	defer cancel()
+
+	// 获取分布式锁，未获取到说明其他实例正在执行
+	locked, err := db.AcquireLock(ctx, s.config.LockKey, s.instanceID, s.config.LockTTL)
+	if err != nil || !locked {
+		return
+	}
+	defer func() {
+		if err := db.ReleaseLock(context.Background(), s.config.LockKey, s.instanceID); err != nil {
+			logger.Log.WithError(err).Warn("Failed to release scheduler lock")
+		}
+	}()
+
+	count, err := s.postService.PublishDuePosts(time.Now())
+	if err != nil {
+		return
+	}
+	if count > 0 {
+		logger.Log.WithField("count", count).Info("Scheduled posts published")
+	}
+}
@@ -3,16 +3,30 @@ package service
 import (
 	"errors"
 	"fmt"
+	"html"
 	"keep_learning_blog/db"
 	"keep_learning_blog/models"
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/markdown"
+	"keep_learning_blog/utils/slug"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PostService 文章服务结构体
 type PostService struct{}
 
 // CreatePost 创建文章 (insert)
-func (s *PostService) CreatePost(title, content string, userID uint, tagNames []string) (*models.Post, error) {
+// status 为空时直接发布（与未引入草稿前的接口行为一致），保存草稿需传 draft；publishAt 不为空时文章进入定时发布状态，到期后由调度器发布
+// coverMediaID 为封面图片，需为当前用户上传的图片
+func (s *PostService) CreatePost(title, content string, userID uint, tagNames []string, status string, publishAt *time.Time, coverMediaID *uint) (*models.Post, error) {
 	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
 		"title":    title,
 		"userID":   userID,
@@ -27,6 +41,19 @@ func (s *PostService) CreatePost(title, content string, userID uint, tagNames []
 	if len(title) > 200 {
 		return nil, errors.New("title cannot be longer than 200 characters")
 	}
+	// 未指定状态时直接发布，与引入草稿之前的行为及数据库列默认值保持一致
+	if status == "" {
+		status = models.PostStatusPublished
+	}
+	if status != models.PostStatusDraft && status != models.PostStatusPublished {
+		return nil, errors.New("status must be draft or published")
+	}
+	if publishAt != nil {
+		if !publishAt.After(time.Now()) {
+			return nil, errors.New("publish time must be in the future")
+		}
+		status = models.PostStatusScheduled
+	}
 
 	// 开始事务
 	tx := db.DB.Begin()
@@ -43,11 +70,42 @@ func (s *PostService) CreatePost(title, content string, userID uint, tagNames []
 		return nil, errors.New("title already exists")
 	}
 
+	// 渲染 Markdown
+	contentHTML, err := markdown.Render(content)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 根据标题生成唯一 slug
+	postSlug, err := slug.Unique(slug.Make(title), db.PostSlugTaken(tx, 0))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 检查封面图片
+	if err := checkCoverMedia(tx, coverMediaID, userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// 创建文章
 	post := &models.Post{
-		Title:   title,
-		Content: content,
-		UserID:  userID,
+		Title:        title,
+		Slug:         postSlug,
+		Content:      content,
+		ContentHTML:  contentHTML,
+		Status:       status,
+		CoverMediaID: coverMediaID,
+		UserID:       userID,
+	}
+	switch status {
+	case models.PostStatusPublished:
+		now := time.Now()
+		post.PublishedAt = &now
+	case models.PostStatusScheduled:
+		post.ScheduledAt = publishAt
 	}
 
 	if err := tx.Create(post).Error; err != nil {
@@ -56,21 +114,23 @@ func (s *PostService) CreatePost(title, content string, userID uint, tagNames []
 		return nil, err
 	}
 
+	// 记录初始修订版本
+	if err := createPostRevision(tx, post, userID, "created"); err != nil {
+		log.WithError(err).Error("Failed to create post revision")
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 未关联文章的封面图片关联到新文章
+	if err := linkCoverMedia(tx, post); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// 处理标签
-	if len(tagNames) > 0 {
-		for _, tagName := range tagNames {
-			var tag models.Tag
-			// 查找或创建标签
-			if err := tx.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
-				tx.Rollback()
-				return nil, err
-			}
-			// 关联标签到文章
-			if err := tx.Model(post).Association("Tags").Append(&tag); err != nil {
-				tx.Rollback()
-				return nil, err
-			}
-		}
+	if err := appendPostTags(tx, post, tagNames); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	// 重新加载文章信息，包括关联的标签
@@ -79,43 +139,105 @@ func (s *PostService) CreatePost(title, content string, userID uint, tagNames []
 		return nil, err
 	}
 
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	invalidateContentCache()
+
 	log.Info("Post created successfully")
-	return post, tx.Commit().Error
+	attachPostImages([]*models.Post{post}, false)
+	return post, nil
 }
 
 // GetPost 获取单个文章 (select)
-func (s *PostService) GetPost(id uint) (*models.Post, error) {
+// viewerID 为当前登录用户ID（未登录为0），canViewDrafts 表示是否拥有查看他人草稿的权限
+func (s *PostService) GetPost(id, viewerID uint, canViewDrafts bool) (*models.Post, error) {
 	var post models.Post
-	if err := db.DB.Preload("Tags").Preload("User").First(&post, id).Error; err != nil {
+	if err := db.DB.Scopes(postVisibilityScope(viewerID, canViewDrafts, models.PostStatusPublished, models.PostStatusArchived)).
+		Preload("Tags").Preload("User").First(&post, id).Error; err != nil {
 		return nil, err
 	}
+	attachPostImages([]*models.Post{&post}, true)
 	return &post, nil
 }
 
+// GetPostBySlug 根据 slug 获取文章 (select)
+// 当 slug 为已修改标题文章的历史 slug 时，返回的 redirectSlug 为文章当前的 slug
+func (s *PostService) GetPostBySlug(postSlug string, viewerID uint, canViewDrafts bool) (post *models.Post, redirectSlug string, err error) {
+	visible := postVisibilityScope(viewerID, canViewDrafts, models.PostStatusPublished, models.PostStatusArchived)
+
+	// 优先匹配当前 slug
+	var current models.Post
+	err = db.DB.Scopes(visible).Preload("Tags").Preload("User").Where("slug = ?", postSlug).First(&current).Error
+	if err == nil {
+		attachPostImages([]*models.Post{&current}, true)
+		return &current, "", nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+
+	// 查找历史 slug
+	var history models.PostSlugHistory
+	if err := db.DB.Where("slug = ?", postSlug).First(&history).Error; err != nil {
+		return nil, "", err
+	}
+
+	var target models.Post
+	if err := db.DB.Scopes(visible).Select("id", "slug").First(&target, history.PostID).Error; err != nil {
+		return nil, "", err
+	}
+
+	return nil, target.Slug, nil
+}
+
 // GetAllPosts 获取文章列表 (select)
-func (s *PostService) GetAllPosts(page, pageSize int) ([]models.Post, int64, error) {
+// status 为空时仅返回已发布文章；草稿和归档文章仅返回作者本人的，拥有权限时返回全部
+func (s *PostService) GetAllPosts(page, pageSize int, status string, viewerID uint, canViewDrafts bool) ([]models.Post, int64, error) {
 	var posts []models.Post
 	var total int64
 
+	if status == "" {
+		status = models.PostStatusPublished
+	}
+	if status != models.PostStatusDraft && status != models.PostStatusScheduled &&
+		status != models.PostStatusPublished && status != models.PostStatusArchived {
+		return nil, 0, errors.New("invalid post status")
+	}
+
+	query := db.DB.Model(&models.Post{}).Where("status = ?", status)
+	if status != models.PostStatusPublished {
+		// 未登录用户无法查看非公开文章
+		if viewerID == 0 && !canViewDrafts {
+			return []models.Post{}, 0, nil
+		}
+		if !canViewDrafts {
+			query = query.Where("user_id = ?", viewerID)
+		}
+	}
+
 	// 获取总数
-	if err := db.DB.Model(&models.Post{}).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// 获取分页数据
 	offset := (page - 1) * pageSize
-	if err := db.DB.Preload("Tags").Preload("User").
+	if err := query.Preload("Tags").Preload("User").
 		Offset(offset).Limit(pageSize).
 		Order("created_at DESC").
 		Find(&posts).Error; err != nil {
 		return nil, 0, err
 	}
 
+	attachPostImages(postPointers(posts), false)
 	return posts, total, nil
 }
 
 // UpdatePost 更新文章 (update)
-func (s *PostService) UpdatePost(id uint, userID uint, title, content string, tagNames []string) (*models.Post, error) {
+// publishAt 不为空时将文章改为定时发布，到达发布时间前对读者隐藏
+// coverMediaID 为空时移除封面
+func (s *PostService) UpdatePost(id uint, userID uint, title, content string, tagNames []string, publishAt *time.Time, coverMediaID *uint) (*models.Post, error) {
 	// 验证数据合法性
 	if id == 0 || userID == 0 || title == "" || content == "" {
 		return nil, errors.New("id, userID, title and content cannot be empty")
@@ -123,6 +245,9 @@ func (s *PostService) UpdatePost(id uint, userID uint, title, content string, ta
 	if len(title) > 200 {
 		return nil, errors.New("title cannot be longer than 200 characters")
 	}
+	if publishAt != nil && !publishAt.After(time.Now()) {
+		return nil, errors.New("publish time must be in the future")
+	}
 
 	// 开始事务
 	tx := db.DB.Begin()
@@ -132,9 +257,9 @@ func (s *PostService) UpdatePost(id uint, userID uint, title, content string, ta
 		}
 	}()
 
-	// 检查文章是否存在
+	// 检查文章是否存在（加行锁，保证修订版本号连续）
 	var post models.Post
-	if err := tx.First(&post, id).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, id).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
@@ -156,10 +281,26 @@ func (s *PostService) UpdatePost(id uint, userID uint, title, content string, ta
 		}
 	}
 
-	// 更新文章基本信息
-	post.Title = title
-	post.Content = content
-	if err := tx.Save(&post).Error; err != nil {
+	// 检查封面图片
+	if err := checkCoverMedia(tx, coverMediaID, userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	post.CoverMediaID = coverMediaID
+
+	// 更新文章基本信息并记录修订版本
+	if publishAt != nil {
+		post.Status = models.PostStatusScheduled
+		post.ScheduledAt = publishAt
+		post.PublishedAt = nil
+	}
+	if err := updatePostContent(tx, &post, title, content, userID, ""); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 未关联文章的封面图片关联到本文
+	if err := linkCoverMedia(tx, &post); err != nil {
 		tx.Rollback()
 		return nil, err
 	}
@@ -171,18 +312,9 @@ func (s *PostService) UpdatePost(id uint, userID uint, title, content string, ta
 	}
 
 	// 添加新标签
-	if len(tagNames) > 0 {
-		for _, tagName := range tagNames {
-			var tag models.Tag
-			if err := tx.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
-				tx.Rollback()
-				return nil, err
-			}
-			if err := tx.Model(&post).Association("Tags").Append(&tag); err != nil {
-				tx.Rollback()
-				return nil, err
-			}
-		}
+	if err := appendPostTags(tx, &post, tagNames); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	// 重新加载文章信息
@@ -191,7 +323,13 @@ func (s *PostService) UpdatePost(id uint, userID uint, title, content string, ta
 		return nil, err
 	}
 
-	return &post, tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	invalidateContentCache()
+
+	attachPostImages([]*models.Post{&post}, true)
+	return &post, nil
 }
 
 // DeletePost 删除文章 (delete)
@@ -234,74 +372,681 @@ func (s *PostService) DeletePost(id uint, userID uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	invalidateContentCache()
+	return nil
 }
 
-// GetPostComments 获取文章的所有评论 (select)
-func (s *PostService) GetPostComments(postID uint) ([]models.Comment, int64, error) {
-	var comments []models.Comment
-	var total int64
+// GetPostComments 分页获取文章的顶层评论 (select)，子评论通过 GetCommentReplies 按需加载
+// 待审核评论仅作者及审核员可见
+func (s *PostService) GetPostComments(postID, viewerID uint, canViewDrafts, canModerate bool, page, pageSize int) ([]models.Comment, int64, error) {
+	// 文章不可见时其评论同样不可见
+	if _, err := s.GetPost(postID, viewerID, canViewDrafts); err != nil {
+		return nil, 0, errors.New("post not found")
+	}
+
+	query := db.DB.Scopes(commentVisibilityScope(viewerID, canModerate)).
+		Where("post_id = ? AND parent_id IS NULL", postID)
+	return listComments(query, page, pageSize)
+}
+
+// GetCommentReplies 分页获取评论的直接回复 (select)
+func (s *PostService) GetCommentReplies(postID, commentID, viewerID uint, canViewDrafts, canModerate bool, page, pageSize int) ([]models.Comment, int64, error) {
+	// 文章不可见时其评论同样不可见
+	if _, err := s.GetPost(postID, viewerID, canViewDrafts); err != nil {
+		return nil, 0, errors.New("post not found")
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.Comment{}).Scopes(commentVisibilityScope(viewerID, canModerate)).
+		Where("id = ? AND post_id = ?", commentID, postID).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, errors.New("comment not found")
+	}
+
+	query := db.DB.Scopes(commentVisibilityScope(viewerID, canModerate)).Where("parent_id = ?", commentID)
+	return listComments(query, page, pageSize)
+}
 
-	// 获取评论
-	if err := db.DB.Where("post_id = ?", postID).Find(&comments).Error; err != nil {
+// GetPostCommentTree 获取文章的完整评论树 (select)，total 为评论总数
+func (s *PostService) GetPostCommentTree(postID, viewerID uint, canViewDrafts, canModerate bool) ([]models.Comment, int64, error) {
+	// 文章不可见时其评论同样不可见
+	if _, err := s.GetPost(postID, viewerID, canViewDrafts); err != nil {
+		return nil, 0, errors.New("post not found")
+	}
+
+	var comments []models.Comment
+	if err := db.DB.Scopes(commentVisibilityScope(viewerID, canModerate)).
+		Where("post_id = ?", postID).Order("created_at ASC, id ASC").Find(&comments).Error; err != nil {
 		return nil, 0, errors.New("failed to get post comments")
 	}
 
-	// 获取评论总数
-	if err := db.DB.Model(&models.Comment{}).Where("post_id = ?", postID).Count(&total).Error; err != nil {
+	// 按父评论分组后自顶向下组装，父评论不可见的回复一并隐藏
+	children := make(map[uint][]models.Comment)
+	var roots []models.Comment
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		} else {
+			children[*comment.ParentID] = append(children[*comment.ParentID], comment)
+		}
+	}
+
+	var attach func(nodes []models.Comment) []models.Comment
+	attach = func(nodes []models.Comment) []models.Comment {
+		for i := range nodes {
+			nodes[i].Replies = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	tree := attach(roots)
+	if tree == nil {
+		tree = []models.Comment{}
+	}
+	return tree, countTree(tree), nil
+}
+
+// countTree 统计评论树中的评论数
+func countTree(nodes []models.Comment) int64 {
+	var total int64
+	for _, node := range nodes {
+		total += 1 + countTree(node.Replies)
+	}
+	return total
+}
+
+// listComments 按时间正序分页获取评论
+func listComments(query *gorm.DB, page, pageSize int) ([]models.Comment, int64, error) {
+	var comments []models.Comment
+	var total int64
+
+	// 获取总数
+	if err := query.Model(&models.Comment{}).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	// 获取分页数据
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).
+		Order("created_at ASC, id ASC").
+		Find(&comments).Error; err != nil {
+		return nil, 0, errors.New("failed to get post comments")
+	}
+
 	return comments, total, nil
 }
 
-/*
-// SearchPosts 搜索文章 (select)
-func (s *PostService) SearchPosts(query string, tags []string, startTime, endTime *time.Time, page, pageSize int) ([]models.Post, int64, error) {
-	var posts []models.Post
-	var total int64
+// PublishPost 立即发布文章 (update)，定时发布的文章会取消定时
+func (s *PostService) PublishPost(id, userID uint) (*models.Post, error) {
+	return s.changePostStatus(id, userID, models.PostStatusPublished,
+		[]string{models.PostStatusDraft, models.PostStatusScheduled, models.PostStatusArchived})
+}
 
-	db := db.DB.Model(&models.Post{})
+// UnpublishPost 撤回已发布或定时发布的文章为草稿 (update)
+func (s *PostService) UnpublishPost(id, userID uint) (*models.Post, error) {
+	return s.changePostStatus(id, userID, models.PostStatusDraft,
+		[]string{models.PostStatusPublished, models.PostStatusScheduled})
+}
+
+// ArchivePost 归档文章 (update)
+func (s *PostService) ArchivePost(id, userID uint) (*models.Post, error) {
+	return s.changePostStatus(id, userID, models.PostStatusArchived,
+		[]string{models.PostStatusDraft, models.PostStatusScheduled, models.PostStatusPublished})
+}
 
-	// 标题搜索
-	if query != "" {
-		db = db.Where("title ILIKE ?", "%"+query+"%")
+// PublishDuePosts 发布所有已到达定时发布时间的文章 (update)
+// 发布时间记为计划发布时间，服务停机期间错过的文章在恢复后一并补发
+func (s *PostService) PublishDuePosts(now time.Time) (int64, error) {
+	result := db.DB.Model(&models.Post{}).
+		Where("status = ? AND scheduled_at <= ?", models.PostStatusScheduled, now).
+		Updates(map[string]interface{}{
+			"status":       models.PostStatusPublished,
+			"published_at": gorm.Expr("scheduled_at"),
+			"scheduled_at": nil,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Error("Failed to publish scheduled posts")
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		invalidateContentCache()
 	}
 
-	// 标签搜索
-	if len(tags) > 0 {
-		db = db.Joins("JOIN post_tags ON posts.id = post_tags.post_id").
-			Joins("JOIN tags ON post_tags.tag_id = tags.id").
-			Where("tags.name IN ?", tags)
+	return result.RowsAffected, nil
+}
+
+// changePostStatus 修改文章状态，仅允许文章作者从 from 中的状态切换到 to
+func (s *PostService) changePostStatus(id, userID uint, to string, from []string) (*models.Post, error) {
+	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"postID": id,
+		"userID": userID,
+		"status": to,
+	}))
+
+	// 验证数据合法性
+	if id == 0 || userID == 0 {
+		return nil, errors.New("id, userID cannot be empty")
+	}
+
+	// 开始事务
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 检查文章是否存在
+	var post models.Post
+	if err := tx.First(&post, id).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 检查是否是文章作者
+	if post.UserID != userID {
+		tx.Rollback()
+		return nil, errors.New("unauthorized to change this post status")
+	}
+
+	// 检查状态流转是否合法
+	allowed := false
+	for _, status := range from {
+		if post.Status == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		tx.Rollback()
+		return nil, fmt.Errorf("cannot change post status from %s to %s", post.Status, to)
+	}
+
+	// 更新状态及发布时间（重新发布归档文章时保留原发布时间），并取消定时发布
+	updates := map[string]interface{}{"status": to, "scheduled_at": nil}
+	switch {
+	case to == models.PostStatusPublished && post.PublishedAt == nil:
+		updates["published_at"] = time.Now()
+	case to == models.PostStatusDraft:
+		updates["published_at"] = nil
+	}
+	if err := tx.Model(&post).Updates(updates).Error; err != nil {
+		log.WithError(err).Error("Failed to change post status")
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 重新加载文章信息
+	if err := tx.Preload("Tags").Preload("User").First(&post, post.ID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
 	}
+	invalidateContentCache()
+
+	log.Info("Post status changed successfully")
+	return &post, nil
+}
+
+// 搜索摘要高亮占位符，生成摘要后统一转义再替换为 <mark> 标签
+const (
+	markStart = "{{mark}}"
+	markStop  = "{{/mark}}"
+)
 
-	// 时间范围搜索
-	if startTime != nil {
-		db = db.Where("created_at >= ?", startTime)
+// htmlTagPattern 用于从摘要中去除 HTML 标签
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// SearchPosts 全文搜索已发布文章 (select)
+// 英文等以空格分词的关键词使用 tsvector 检索并由 ts_headline 生成摘要，
+// 中文关键词无法被 simple 分词器切分，使用 pg_trgm 索引加速的 ILIKE 匹配兜底
+func (s *PostService) SearchPosts(query string, tags []string, from, to *time.Time, page, pageSize int) ([]models.PostSearchResult, int64, error) {
+	var total int64
+
+	textTerms, cjkTerms := splitSearchTerms(query)
+	tsQuery := strings.Join(textTerms, " ")
+
+	// 构建过滤条件
+	filter := db.DB.Model(&models.Post{}).Where("posts.status = ?", models.PostStatusPublished)
+	if tsQuery != "" {
+		filter = filter.Where("posts.search_vector @@ websearch_to_tsquery('simple', ?)", tsQuery)
+	}
+	for _, term := range cjkTerms {
+		pattern := "%" + escapeLike(term) + "%"
+		filter = filter.Where("(posts.title ILIKE ? OR posts.content ILIKE ?)", pattern, pattern)
 	}
-	if endTime != nil {
-		db = db.Where("created_at <= ?", endTime)
+	if len(tags) > 0 {
+		filter = filter.Where(`EXISTS (SELECT 1 FROM post_tags JOIN tags ON tags.id = post_tags.tag_id
+			WHERE post_tags.post_id = posts.id AND tags.name IN ?)`, tags)
+	}
+	if from != nil {
+		filter = filter.Where("posts.published_at >= ?", from)
+	}
+	if to != nil {
+		filter = filter.Where("posts.published_at < ?", to.AddDate(0, 0, 1))
 	}
 
 	// 获取总数
-	if err := db.Count(&total).Error; err != nil {
+	if err := filter.Session(&gorm.Session{}).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
+	// 计算相关度和摘要
+	rankSQL := "0"
+	var rankArgs []interface{}
+	if tsQuery != "" {
+		rankSQL = "ts_rank(posts.search_vector, websearch_to_tsquery('simple', ?))"
+		rankArgs = append(rankArgs, tsQuery)
+	}
+	for _, term := range cjkTerms {
+		pattern := "%" + escapeLike(term) + "%"
+		rankSQL += " + (CASE WHEN posts.title ILIKE ? THEN 1.0 ELSE 0 END) + (CASE WHEN posts.content ILIKE ? THEN 0.5 ELSE 0 END)"
+		rankArgs = append(rankArgs, pattern, pattern)
+	}
+
+	plainContent := `regexp_replace(posts.content, '<[^>]*>', ' ', 'g')`
+	snippetSQL := plainContent
+	var snippetArgs []interface{}
+	if tsQuery != "" {
+		snippetSQL = fmt.Sprintf(`ts_headline('simple', %s, websearch_to_tsquery('simple', ?),
+			'StartSel="%s", StopSel="%s", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "')`,
+			plainContent, markStart, markStop)
+		snippetArgs = append(snippetArgs, tsQuery)
+	}
+
+	order := "posts.published_at DESC"
+	if tsQuery != "" || len(cjkTerms) > 0 {
+		order = "rank DESC, posts.published_at DESC"
+	}
+
 	// 获取分页数据
+	var hits []struct {
+		ID      uint
+		Rank    float64
+		Snippet string
+	}
 	offset := (page - 1) * pageSize
-	if err := db.Preload("Tags").
-		Preload("User").
-		Offset(offset).
-		Limit(pageSize).
-		Order("created_at DESC").
-		Find(&posts).Error; err != nil {
+	if err := filter.
+		Select(fmt.Sprintf("posts.id, (%s) AS rank, %s AS snippet", rankSQL, snippetSQL), append(rankArgs, snippetArgs...)...).
+		Order(order).Offset(offset).Limit(pageSize).
+		Scan(&hits).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to search posts")
 		return nil, 0, err
 	}
 
-	return posts, total, nil
+	// 加载文章详情
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	var posts []models.Post
+	if err := db.DB.Preload("Tags").Preload("User").Where("id IN ?", ids).Find(&posts).Error; err != nil {
+		return nil, 0, err
+	}
+	postMap := make(map[uint]models.Post, len(posts))
+	attachPostImages(postPointers(posts), false)
+	for _, post := range posts {
+		postMap[post.ID] = post
+	}
+
+	// 按相关度顺序组装结果
+	results := make([]models.PostSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		post, ok := postMap[hit.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, models.PostSearchResult{
+			Post:    post,
+			Rank:    hit.Rank,
+			Snippet: buildSearchSnippet(hit.Snippet, tsQuery != "", cjkTerms),
+		})
+	}
+
+	return results, total, nil
+}
+
+// splitSearchTerms 将搜索词拆分为可用 tsvector 检索的普通词和需要模糊匹配的中文词
+func splitSearchTerms(query string) (textTerms, cjkTerms []string) {
+	for _, term := range strings.Fields(query) {
+		if containsHan(term) {
+			cjkTerms = append(cjkTerms, term)
+		} else {
+			textTerms = append(textTerms, term)
+		}
+	}
+	return textTerms, cjkTerms
+}
+
+// containsHan 判断字符串是否包含汉字
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike 转义 LIKE 模式中的特殊字符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// buildSearchSnippet 生成搜索摘要：截取中文关键词附近的文本并高亮，最后转义 HTML 并替换高亮占位符
+func buildSearchSnippet(snippet string, fromHeadline bool, cjkTerms []string) string {
+	const radius = 60
+
+	snippet = strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(snippet, " "))), " ")
+
+	// 未经过 ts_headline 的内容需要自行截取关键词附近的片段
+	if !fromHeadline {
+		runes := []rune(snippet)
+		start := 0
+		for _, term := range cjkTerms {
+			// 在原文上不区分大小写匹配，转小写可能改变字节长度（如 "İ"），不能用小写文本的偏移截取原文
+			pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(term))
+			if loc := pattern.FindStringIndex(snippet); loc != nil {
+				start = utf8.RuneCountInString(snippet[:loc[0]]) - radius
+				break
+			}
+		}
+		if start < 0 {
+			start = 0
+		}
+		end := start + radius*2
+		if end > len(runes) {
+			end = len(runes)
+		}
+		snippet = string(runes[start:end])
+		if start > 0 {
+			snippet = "..." + snippet
+		}
+		if end < len(runes) {
+			snippet += "..."
+		}
+	}
+
+	// 高亮中文关键词
+	for _, term := range cjkTerms {
+		pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(term))
+		snippet = pattern.ReplaceAllString(snippet, markStart+"$0"+markStop)
+	}
+
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, html.EscapeString(markStart), "<mark>")
+	return strings.ReplaceAll(snippet, html.EscapeString(markStop), "</mark>")
+}
+
+// PreviewMarkdown 渲染 Markdown 内容但不保存，用于编辑时预览
+func (s *PostService) PreviewMarkdown(content string) (string, error) {
+	return markdown.Render(content)
+}
+
+// GetPostRevisions 获取文章的所有修订版本 (select)
+func (s *PostService) GetPostRevisions(postID, userID uint) ([]models.PostRevision, error) {
+	if err := checkPostAuthor(db.DB, postID, userID); err != nil {
+		return nil, err
+	}
+
+	var revisions []models.PostRevision
+	if err := db.DB.Preload("User").Where("post_id = ?", postID).
+		Order("version DESC").Find(&revisions).Error; err != nil {
+		return nil, errors.New("failed to get post revisions")
+	}
+
+	return revisions, nil
+}
+
+// DiffPostRevisions 生成两个修订版本之间的统一格式差异 (select)
+func (s *PostService) DiffPostRevisions(postID, userID uint, fromVersion, toVersion int) (string, error) {
+	if err := checkPostAuthor(db.DB, postID, userID); err != nil {
+		return "", err
+	}
+
+	from, err := getPostRevision(db.DB, postID, fromVersion)
+	if err != nil {
+		return "", err
+	}
+	to, err := getPostRevision(db.DB, postID, toVersion)
+	if err != nil {
+		return "", err
+	}
+
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(from)),
+		B:        difflib.SplitLines(revisionText(to)),
+		FromFile: fmt.Sprintf("revision %d", from.Version),
+		FromDate: from.CreatedAt.Format(time.RFC3339),
+		ToFile:   fmt.Sprintf("revision %d", to.Version),
+		ToDate:   to.CreatedAt.Format(time.RFC3339),
+		Context:  3,
+	}
+	return difflib.GetUnifiedDiffString(diff)
+}
+
+// RestorePostRevision 将文章恢复为指定修订版本的内容，并记录为新的修订版本 (update)
+func (s *PostService) RestorePostRevision(postID, userID uint, version int) (*models.Post, error) {
+	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"postID":  postID,
+		"userID":  userID,
+		"version": version,
+	}))
+
+	// 验证数据合法性
+	if postID == 0 || userID == 0 || version <= 0 {
+		return nil, errors.New("postID, userID and version cannot be empty")
+	}
+
+	// 开始事务
+	tx := db.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 检查文章是否存在
+	var post models.Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, postID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 检查是否是文章作者
+	if post.UserID != userID {
+		tx.Rollback()
+		return nil, errors.New("unauthorized to restore this post")
+	}
+
+	// 获取要恢复的修订版本
+	revision, err := getPostRevision(tx, postID, version)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 恢复内容并记录新的修订版本
+	note := fmt.Sprintf("restored from revision %d", version)
+	if err := updatePostContent(tx, &post, revision.Title, revision.Content, userID, note); err != nil {
+		log.WithError(err).Error("Failed to restore post revision")
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 重新加载文章信息
+	if err := tx.Preload("Tags").Preload("User").First(&post, post.ID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	invalidateContentCache()
+
+	log.Info("Post revision restored successfully")
+	return &post, nil
 }
 
+// updatePostContent 更新文章标题和内容，标题变化时同步更新 slug
+func updatePostContent(tx *gorm.DB, post *models.Post, title, content string, editorID uint, note string) error {
+	if title != post.Title {
+		if err := changePostSlug(tx, post, title); err != nil {
+			return err
+		}
+	}
+	return savePostContent(tx, post, title, content, editorID, note)
+}
+
+// savePostContent 保存文章标题和内容，并在同一事务中记录修订版本，不修改 slug
+// 对于尚无修订记录的历史文章，会先将修改前的内容保存为第一个版本
+func savePostContent(tx *gorm.DB, post *models.Post, title, content string, editorID uint, note string) error {
+	var count int64
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		if err := createPostRevision(tx, post, post.UserID, "initial version"); err != nil {
+			return err
+		}
+	}
+
+	contentHTML, err := markdown.Render(content)
+	if err != nil {
+		return err
+	}
+
+	post.Title = title
+	post.Content = content
+	post.ContentHTML = contentHTML
+	if err := tx.Save(post).Error; err != nil {
+		return err
+	}
+
+	return createPostRevision(tx, post, editorID, note)
+}
+
+// appendPostTags 查找或创建标签并关联到文章
+func appendPostTags(tx *gorm.DB, post *models.Post, tagNames []string) error {
+	for _, tagName := range tagNames {
+		var tag models.Tag
+		// 查找或创建标签
+		if err := tx.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
+			return err
+		}
+		// 关联标签到文章
+		if err := tx.Model(post).Association("Tags").Append(&tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changePostSlug 根据新标题更新文章 slug，并将旧 slug 记入历史以便重定向
+func changePostSlug(tx *gorm.DB, post *models.Post, title string) error {
+	newSlug, err := slug.Unique(slug.Make(title), db.PostSlugTaken(tx, post.ID))
+	if err != nil {
+		return err
+	}
+	if newSlug == post.Slug {
+		return nil
+	}
+
+	// 新 slug 曾是本文的历史 slug 时（如恢复旧标题），将其从历史中移除
+	if err := tx.Where("post_id = ? AND slug = ?", post.ID, newSlug).Delete(&models.PostSlugHistory{}).Error; err != nil {
+		return err
+	}
+
+	// 记录旧 slug
+	if post.Slug != "" {
+		if err := tx.Create(&models.PostSlugHistory{PostID: post.ID, Slug: post.Slug}).Error; err != nil {
+			return err
+		}
+	}
+
+	post.Slug = newSlug
+	return nil
+}
+
+// createPostRevision 以文章当前内容创建新的修订版本
+func createPostRevision(tx *gorm.DB, post *models.Post, editorID uint, note string) error {
+	var latest int
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	revision := models.PostRevision{
+		PostID:  post.ID,
+		Version: latest + 1,
+		Title:   post.Title,
+		Content: post.Content,
+		Note:    note,
+		UserID:  editorID,
+	}
+	return tx.Create(&revision).Error
+}
+
+// getPostRevision 获取文章的指定修订版本
+func getPostRevision(tx *gorm.DB, postID uint, version int) (*models.PostRevision, error) {
+	var revision models.PostRevision
+	if err := tx.Where("post_id = ? AND version = ?", postID, version).First(&revision).Error; err != nil {
+		return nil, fmt.Errorf("revision %d not found", version)
+	}
+	return &revision, nil
+}
+
+// checkPostAuthor 检查文章是否存在且当前用户为文章作者
+func checkPostAuthor(tx *gorm.DB, postID, userID uint) error {
+	var post models.Post
+	if err := tx.Select("id", "user_id").First(&post, postID).Error; err != nil {
+		return errors.New("post not found")
+	}
+	if post.UserID != userID {
+		return errors.New("unauthorized to access this post")
+	}
+	return nil
+}
+
+// revisionText 将修订版本转换为用于比较差异的文本
+func revisionText(revision *models.PostRevision) string {
+	return "# " + revision.Title + "\n\n" + revision.Content + "\n"
+}
+
+// postVisibilityScope 文章可见性范围
+// publicStatuses 中的状态对所有人可见，其余状态仅作者本人或拥有权限的角色可见
+func postVisibilityScope(viewerID uint, canViewDrafts bool, publicStatuses ...string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if canViewDrafts {
+			return tx
+		}
+		if viewerID == 0 {
+			return tx.Where("posts.status IN ?", publicStatuses)
+		}
+		return tx.Where("(posts.status IN ? OR posts.user_id = ?)", publicStatuses, viewerID)
+	}
+}
+
+// postPointers 返回文章切片中各元素的指针，便于批量填充附加信息
+func postPointers(posts []models.Post) []*models.Post {
+	pointers := make([]*models.Post, len(posts))
+	for i := range posts {
+		pointers[i] = &posts[i]
+	}
+	return pointers
+}
+
+/*
 // GetPostTags 获取文章标签 (select)
 func (s *PostService) GetPostTags(postID uint) ([]models.Tag, error) {
 	var tags []models.Tag
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestBuildSearchSnippetNonASCII 转小写会改变字节长度的字符出现在关键词之前时，片段仍以关键词为中心截取
+func TestBuildSearchSnippetNonASCII(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		term    string
+	}{
+		{"dotted capital I", strings.Repeat("İstanbul ", 30) + "关键词 结尾", "关键词"},
+		{"kelvin sign", strings.Repeat("K", 200) + "搜索内容", "搜索"},
+		{"case insensitive latin", strings.Repeat("Ärger ", 40) + "GoLang 中文", "golang"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			snippet := buildSearchSnippet(tc.content, false, []string{tc.term})
+			if !utf8.ValidString(snippet) {
+				t.Fatalf("snippet is not valid UTF-8: %q", snippet)
+			}
+			if !strings.Contains(strings.ToLower(snippet), "<mark>"+strings.ToLower(tc.term)+"</mark>") {
+				t.Fatalf("snippet does not highlight %q: %q", tc.term, snippet)
+			}
+			if !strings.HasPrefix(snippet, "...") {
+				t.Fatalf("snippet should be truncated around the term: %q", snippet)
+			}
+		})
+	}
+}
@@ -15,7 +15,7 @@ import (
 type RoleService struct{}
 
 // CreateRole 创建角色 (insert)
-func (s *RoleService) CreateRole(name, code, description string, permissionIDs []uint, isDefault *bool) (*models.Role, error) {
+func (s *RoleService) CreateRole(name, code, description string, permissionIDs []uint, isDefault, requireMFA *bool) (*models.Role, error) {
 	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
 		"name":          name,
 		"code":          code,
@@ -62,6 +62,9 @@ func (s *RoleService) CreateRole(name, code, description string, permissionIDs [
 	if isDefault != nil {
 		role.IsDefault = *isDefault
 	}
+	if requireMFA != nil {
+		role.RequireMFA = *requireMFA
+	}
 
 	if err := tx.Create(role).Error; err != nil {
 		log.WithError(err).Error("Failed to create role")
@@ -98,7 +101,7 @@ func (s *RoleService) GetAllRoles() ([]models.Role, error) {
 }
 
 // UpdateRole 更新角色 (update)
-func (s *RoleService) UpdateRole(id uint, name, code, description string, isDefault *bool) (*models.Role, error) {
+func (s *RoleService) UpdateRole(id uint, name, code, description string, isDefault, requireMFA *bool) (*models.Role, error) {
 	// 验证数据合法性
 	if id == 0 || name == "" || code == "" {
 		return nil, errors.New("invalid input parameters")
@@ -137,6 +140,9 @@ func (s *RoleService) UpdateRole(id uint, name, code, description string, isDefa
 	if isDefault != nil {
 		role.IsDefault = *isDefault
 	}
+	if requireMFA != nil {
+		role.RequireMFA = *requireMFA
+	}
 
 	if err := tx.Save(&role).Error; err != nil {
 		tx.Rollback()
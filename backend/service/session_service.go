@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"sort"
+
+	"keep_learning_blog/utils/logger"
+)
+
+// ErrSessionNotFound 会话不存在或不属于当前用户
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService 登录会话服务结构体
+type SessionService struct{}
+
+// ListSessions 获取用户的所有登录会话，按最近活动时间倒序排列并标记当前会话 (select)
+func (s *SessionService) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]models.Session, error) {
+	sessions, err := db.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, errors.New("failed to get sessions")
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
+
+// RevokeSession 撤销用户的指定会话，会话的访问令牌和刷新令牌同时失效 (delete)
+func (s *SessionService) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	found, err := db.DeleteSession(ctx, userID, sessionID)
+	if err != nil {
+		return errors.New("failed to revoke session")
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"user_id":    userID,
+		"session_id": sessionID,
+	})).Info("Session revoked")
+	return nil
+}
+
+// RevokeAllSessions 撤销用户的所有会话，即在所有设备上退出登录 (delete)
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID uint) error {
+	if err := db.DeleteUserSessions(ctx, userID); err != nil {
+		return errors.New("failed to revoke sessions")
+	}
+	return nil
+}
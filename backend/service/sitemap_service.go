@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"keep_learning_blog/config"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sitemapXMLNS 站点地图协议命名空间
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// sitemapContentType 站点地图响应类型
+const sitemapContentType = "application/xml; charset=utf-8"
+
+// ErrSitemapNotFound 站点地图分页不存在
+var ErrSitemapNotFound = errors.New("sitemap not found")
+
+// sitemapEntriesSQL 站点地图条目：首页、已发布文章、有已发布文章的标签页和作者页
+// kind 与 key 共同确定稳定的排序，便于按固定大小分页
+var sitemapEntriesSQL = `
+	SELECT 0 AS kind, '' AS key, MAX(updated_at) AS last_mod FROM posts WHERE status = @status
+	UNION ALL
+	SELECT 1 AS kind, slug AS key, updated_at AS last_mod FROM posts WHERE status = @status
+	UNION ALL
+	SELECT 2 AS kind, tags.name AS key, MAX(posts.updated_at) AS last_mod
+	FROM tags
+	JOIN post_tags ON post_tags.tag_id = tags.id
+	JOIN posts ON posts.id = post_tags.post_id AND posts.status = @status
+	GROUP BY tags.name
+	UNION ALL
+	SELECT 3 AS kind, users.username AS key, MAX(posts.updated_at) AS last_mod
+	FROM users
+	JOIN posts ON posts.user_id = users.id AND posts.status = @status
+	GROUP BY users.username`
+
+// sitemapEntry 站点地图条目
+type sitemapEntry struct {
+	Kind    int
+	Key     string
+	LastMod *time.Time
+}
+
+// sitemapURL 站点地图中的 <url> 或 <sitemap> 节点
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// sitemapURLSet 站点地图文件
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// sitemapIndex 站点地图索引文件
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// SitemapService 站点地图服务结构体
+type SitemapService struct {
+	Site *config.SiteConfig
+}
+
+// GetSitemap 获取 /sitemap.xml
+// 条目数未超过上限时直接返回站点地图，否则返回指向各分页的站点地图索引
+func (s *SitemapService) GetSitemap(ctx context.Context) (*CachedDocument, error) {
+	return getCachedDocument(ctx, "sitemap:root", s.Site.CacheTTL, func() (*CachedDocument, error) {
+		var total int64
+		if err := db.DB.Raw("SELECT COUNT(*) FROM ("+sitemapEntriesSQL+") AS entries",
+			map[string]interface{}{"status": models.PostStatusPublished}).Scan(&total).Error; err != nil {
+			return nil, err
+		}
+
+		if total <= int64(s.Site.SitemapURLLimit) {
+			return s.buildURLSet(0)
+		}
+		return s.buildIndex()
+	})
+}
+
+// GetSitemapPage 获取站点地图索引中的第 page 个分页，page 从 1 开始
+func (s *SitemapService) GetSitemapPage(ctx context.Context, page int) (*CachedDocument, error) {
+	if page < 1 {
+		return nil, ErrSitemapNotFound
+	}
+	return getCachedDocument(ctx, fmt.Sprintf("sitemap:page:%d", page), s.Site.CacheTTL, func() (*CachedDocument, error) {
+		doc, err := s.buildURLSet(page)
+		if err == nil && doc == nil {
+			return nil, ErrSitemapNotFound
+		}
+		return doc, err
+	})
+}
+
+// buildURLSet 生成站点地图文件，page 为 0 时包含全部条目
+// 分页不存在时返回 nil
+func (s *SitemapService) buildURLSet(page int) (*CachedDocument, error) {
+	query := "SELECT kind, key, last_mod FROM (" + sitemapEntriesSQL + ") AS entries ORDER BY kind, key"
+	params := map[string]interface{}{"status": models.PostStatusPublished}
+	if page > 0 {
+		query += " LIMIT @limit OFFSET @offset"
+		params["limit"] = s.Site.SitemapURLLimit
+		params["offset"] = (page - 1) * s.Site.SitemapURLLimit
+	}
+
+	var entries []sitemapEntry
+	if err := db.DB.Raw(query, params).Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	if page > 0 && len(entries) == 0 {
+		return nil, nil
+	}
+
+	set := sitemapURLSet{Xmlns: sitemapXMLNS, URLs: make([]sitemapURL, 0, len(entries))}
+	var lastModified time.Time
+	for _, entry := range entries {
+		set.URLs = append(set.URLs, sitemapURL{Loc: s.entryURL(entry), LastMod: formatLastMod(entry.LastMod)})
+		if entry.LastMod != nil && entry.LastMod.After(lastModified) {
+			lastModified = *entry.LastMod
+		}
+	}
+
+	body, err := marshalSitemap(set)
+	if err != nil {
+		return nil, err
+	}
+	return newCachedDocument(body, sitemapContentType, lastModified), nil
+}
+
+// buildIndex 生成站点地图索引，每个分页的 lastmod 为其条目中最近的修改时间
+func (s *SitemapService) buildIndex() (*CachedDocument, error) {
+	var pages []struct {
+		Page    int
+		LastMod *time.Time
+	}
+	if err := db.DB.Raw(`
+		SELECT page, MAX(last_mod) AS last_mod FROM (
+			SELECT (ROW_NUMBER() OVER (ORDER BY kind, key) - 1) / @limit + 1 AS page, last_mod
+			FROM (`+sitemapEntriesSQL+`) AS entries
+		) AS numbered
+		GROUP BY page ORDER BY page`,
+		map[string]interface{}{"status": models.PostStatusPublished, "limit": s.Site.SitemapURLLimit}).
+		Scan(&pages).Error; err != nil {
+		return nil, err
+	}
+
+	siteURL := strings.TrimRight(s.Site.URL, "/")
+	index := sitemapIndex{Xmlns: sitemapXMLNS, Sitemaps: make([]sitemapURL, 0, len(pages))}
+	var lastModified time.Time
+	for _, page := range pages {
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{
+			Loc:     fmt.Sprintf("%s/sitemaps/%d.xml", siteURL, page.Page),
+			LastMod: formatLastMod(page.LastMod),
+		})
+		if page.LastMod != nil && page.LastMod.After(lastModified) {
+			lastModified = *page.LastMod
+		}
+	}
+
+	body, err := marshalSitemap(index)
+	if err != nil {
+		return nil, err
+	}
+	return newCachedDocument(body, sitemapContentType, lastModified), nil
+}
+
+// entryURL 返回条目对应页面的绝对地址
+func (s *SitemapService) entryURL(entry sitemapEntry) string {
+	siteURL := strings.TrimRight(s.Site.URL, "/")
+	switch entry.Kind {
+	case 1:
+		return siteURL + "/posts/" + url.PathEscape(entry.Key)
+	case 2:
+		return siteURL + "/tags/" + url.PathEscape(entry.Key)
+	case 3:
+		return siteURL + "/authors/" + url.PathEscape(entry.Key)
+	default:
+		return siteURL + "/"
+	}
+}
+
+// GetRobots 根据配置生成 robots.txt
+func (s *SitemapService) GetRobots() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("User-agent: *\n")
+	if s.Site.Robots.DisallowAll {
+		buf.WriteString("Disallow: /\n")
+		return buf.Bytes()
+	}
+
+	for _, path := range s.Site.Robots.Allow {
+		fmt.Fprintf(&buf, "Allow: %s\n", path)
+	}
+	for _, path := range s.Site.Robots.Disallow {
+		fmt.Fprintf(&buf, "Disallow: %s\n", path)
+	}
+	if len(s.Site.Robots.Allow) == 0 && len(s.Site.Robots.Disallow) == 0 {
+		buf.WriteString("Disallow:\n")
+	}
+	fmt.Fprintf(&buf, "\nSitemap: %s/sitemap.xml\n", strings.TrimRight(s.Site.URL, "/"))
+	return buf.Bytes()
+}
+
+// marshalSitemap 序列化站点地图并添加 XML 声明
+func marshalSitemap(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// formatLastMod 格式化为 W3C Datetime
+func formatLastMod(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/spam"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpamTokenStore 贝叶斯垃圾内容分类器的词频存储，保存在 PostgreSQL 中
+type SpamTokenStore struct{}
+
+// Documents 获取垃圾和正常样本总数
+func (s *SpamTokenStore) Documents(ctx context.Context) (spamDocs, hamDocs int64, err error) {
+	var stats []models.SpamClassStat
+	if err := db.DB.WithContext(ctx).Find(&stats).Error; err != nil {
+		return 0, 0, err
+	}
+	for _, stat := range stats {
+		switch stat.Class {
+		case models.SpamClassSpam:
+			spamDocs = stat.Documents
+		case models.SpamClassHam:
+			hamDocs = stat.Documents
+		}
+	}
+	return spamDocs, hamDocs, nil
+}
+
+// Counts 获取已出现过的词的词频
+func (s *SpamTokenStore) Counts(ctx context.Context, tokens []string) ([]spam.TokenCount, error) {
+	var rows []models.SpamToken
+	if err := db.DB.WithContext(ctx).Where("token IN ?", tokens).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make([]spam.TokenCount, 0, len(rows))
+	for _, row := range rows {
+		counts = append(counts, spam.TokenCount{Token: row.Token, SpamCount: row.SpamCount, HamCount: row.HamCount})
+	}
+	return counts, nil
+}
+
+// Add 将样本计入或移出指定类别，计数不会低于 0
+func (s *SpamTokenStore) Add(ctx context.Context, tokens []string, isSpam bool, delta int64) error {
+	class := models.SpamClassHam
+	var spamCount, hamCount int64 = 0, delta
+	if isSpam {
+		class = models.SpamClassSpam
+		spamCount, hamCount = delta, 0
+	}
+
+	rows := make([]models.SpamToken, 0, len(tokens))
+	for _, token := range tokens {
+		rows = append(rows, models.SpamToken{Token: token, SpamCount: max(spamCount, 0), HamCount: max(hamCount, 0)})
+	}
+
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "token"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"spam_count": gorm.Expr("GREATEST(spam_tokens.spam_count + ?, 0)", spamCount),
+				"ham_count":  gorm.Expr("GREATEST(spam_tokens.ham_count + ?, 0)", hamCount),
+			}),
+		}).Create(&rows).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "class"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"documents": gorm.Expr("GREATEST(spam_class_stats.documents + ?, 0)", delta),
+			}),
+		}).Create(&models.SpamClassStat{Class: class, Documents: max(delta, 0)}).Error
+	})
+}
@@ -103,7 +103,11 @@ func (s *TagService) UpdateTag(id uint, name string) (*models.Tag, error) {
 		return nil, err
 	}
 
-	return &existingTag, tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	invalidateContentCache()
+	return &existingTag, nil
 }
 
 // DeleteTag 删除标签 (delete)
@@ -134,5 +138,9 @@ func (s *TagService) DeleteTag(id uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	invalidateContentCache()
+	return nil
 }
@@ -7,17 +7,22 @@ import (
 	"keep_learning_blog/db"
 	"keep_learning_blog/models"
 	"log"
+	"time"
 
 	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/spam"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // UserService 用户服务结构体
-type UserService struct{}
+type UserService struct {
+	SpamFilter *spam.Filter // 垃圾注册检查，为空时不检查
+}
 
 // Register 注册用户 (insert)
-func (s *UserService) Register(username, password, email string) (*models.User, error) {
+// honeypot 为蜜罐字段的值；被判定为垃圾注册时拒绝，可疑注册的用户发表的评论需人工审核
+func (s *UserService) Register(username, password, email, honeypot string) (*models.User, error) {
 	log := logger.Log.WithFields(logger.Fields(map[string]interface{}{
 		"username": username,
 		"email":    email,
@@ -31,6 +36,26 @@ func (s *UserService) Register(username, password, email string) (*models.User,
 		return nil, errors.New("username, password and email cannot be longer than 64 and 128 characters")
 	}
 
+	// 垃圾注册检查
+	flagged := false
+	if s.SpamFilter != nil {
+		result, _ := s.SpamFilter.Check(context.Background(), &spam.Content{
+			Kind:     spam.KindRegistration,
+			Text:     username + " " + email,
+			Honeypot: honeypot,
+		})
+		switch result.Verdict {
+		case spam.Spam:
+			log.WithFields(logger.Fields(map[string]interface{}{
+				"checker": result.Checker,
+				"reason":  result.Reason,
+			})).Warn("Registration rejected as spam")
+			return nil, errors.New("registration rejected")
+		case spam.Suspicious:
+			flagged = true
+		}
+	}
+
 	// 开始事务
 	tx := db.DB.Begin()
 	defer func() {
@@ -57,6 +82,7 @@ func (s *UserService) Register(username, password, email string) (*models.User,
 		Username: username,
 		Password: string(hashedPassword),
 		Email:    email,
+		Flagged:  flagged,
 	}
 
 	if err := tx.Create(&user).Error; err != nil {
@@ -120,11 +146,13 @@ func (s *UserService) CreateUser(username, password, email string, rolesID uint)
 		return nil, err
 	}
 
-	// 创建新用户
+	// 创建新用户，管理员创建的用户无需验证邮箱
+	verifiedAt := time.Now()
 	user := models.User{
-		Username: username,
-		Password: string(hashedPassword),
-		Email:    email,
+		Username:        username,
+		Password:        string(hashedPassword),
+		Email:           email,
+		EmailVerifiedAt: &verifiedAt,
 	}
 
 	if err := tx.Create(&user).Error; err != nil {
@@ -163,6 +191,17 @@ func (s *UserService) Login(username, password string) (*models.User, error) {
 	return &user, nil
 }
 
+// IsEmailVerified 检查用户是否已验证邮箱 (select)
+func (s *UserService) IsEmailVerified(userID uint) (bool, error) {
+	var count int64
+	if err := db.DB.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NOT NULL", userID).Count(&count).Error; err != nil {
+		return false, errors.New("failed to check email verification")
+	}
+
+	return count > 0, nil
+}
+
 // GetUser 获取用户及其角色和权限信息 (select)
 func (s *UserService) GetUser(id uint) (*models.User, error) {
 	var user models.User
@@ -231,7 +270,11 @@ func (s *UserService) UpdateUser(id uint, username, password, email string) (*mo
 		return nil, err
 	}
 
-	return &user, tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	invalidateContentCache()
+	return &user, nil
 }
 
 // DeleteUser 删除用户 (delete)
@@ -255,7 +298,16 @@ func (s *UserService) DeleteUser(id uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// 删除用户的登录会话，仅记录日志
+	if err := db.DeleteUserSessions(context.Background(), id); err != nil {
+		log.Printf("Failed to delete user sessions: %v", err)
+	}
+	invalidateContentCache()
+	return nil
 }
 
 // UpdateUserRoles 修改用户角色 (update)
@@ -315,7 +367,7 @@ func (s *UserService) GetUserPosts(userID uint) ([]models.Post, error) {
 // GetUserComments 获取用户发表的评论 (select)
 func (s *UserService) GetUserComments(userID uint) ([]models.Comment, error) {
 	var comments []models.Comment
-	if err := db.DB.Where("user_id = ?", userID).Find(&comments).Error; err != nil {
+	if err := db.DB.Where("user_id = ? AND is_deleted = ?", userID, false).Find(&comments).Error; err != nil {
 		return nil, errors.New("failed to get user comments")
 	}
 
@@ -0,0 +1,621 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"keep_learning_blog/db"
+	"keep_learning_blog/models"
+	"keep_learning_blog/utils/logger"
+	"keep_learning_blog/utils/sanitizer"
+	"keep_learning_blog/utils/slug"
+	"keep_learning_blog/utils/wxr"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// wordPressUploadPattern 指向 wp-content/uploads 的地址，捕获 uploads 下的相对路径
+var wordPressUploadPattern = regexp.MustCompile(`(?:https?:)?(?://[^/\s"'<>]+)?/wp-content/uploads/([^\s"'<>()?#]+)`)
+
+// wordPressDefaultCategory WordPress 的默认分类，导入时忽略
+const wordPressDefaultCategory = "uncategorized"
+
+// WordPressImporter WordPress WXR 导入器
+// 流式读取导出文件，逐条导入作者、标签、文章、附件和评论，单条失败不影响其他条目
+// 每次导入使用一个新实例，实例内记录 WordPress ID 与本地 ID 的映射
+type WordPressImporter struct {
+	Media         *MediaService
+	UploadsDir    string // 与导出文件一同提供的 wp-content/uploads 目录，为空时不复制附件
+	DefaultUserID uint   // 作者缺少邮箱时文章归属的用户
+
+	authors   map[string]uint          // 作者登录名 -> 用户ID
+	authorIDs map[string]uint          // WordPress 用户ID -> 用户ID
+	users     map[string]uint          // 邮箱 -> 用户ID
+	posts     map[string]*models.Post  // WordPress 文章ID -> 文章
+	uploads   map[string]*models.Media // uploads 相对路径 -> 已复制的媒体文件
+	report    *models.ImportReport
+}
+
+// NewWordPressImporter 创建 WordPress 导入器
+func NewWordPressImporter(mediaService *MediaService, uploadsDir string, defaultUserID uint) *WordPressImporter {
+	return &WordPressImporter{
+		Media:         mediaService,
+		UploadsDir:    uploadsDir,
+		DefaultUserID: defaultUserID,
+		authors:       make(map[string]uint),
+		authorIDs:     make(map[string]uint),
+		users:         make(map[string]uint),
+		posts:         make(map[string]*models.Post),
+		uploads:       make(map[string]*models.Media),
+		report:        &models.ImportReport{Items: []models.ImportItem{}},
+	}
+}
+
+// Import 导入 WXR 文件，返回逐条导入报告
+// 仅在 XML 格式错误无法继续读取时返回错误，此时报告包含已处理的条目
+func (s *WordPressImporter) Import(ctx context.Context, r io.Reader) (*models.ImportReport, error) {
+	if s.DefaultUserID == 0 {
+		return s.report, errors.New("default user cannot be empty")
+	}
+
+	reader := wxr.NewReader(r)
+	var readErr error
+	for {
+		value, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("invalid WXR file: %w", err)
+			break
+		}
+
+		switch v := value.(type) {
+		case *wxr.Author:
+			s.importAuthor(v)
+		case *wxr.Category:
+			s.importTag(v.Nicename, v.Name)
+		case *wxr.Tag:
+			s.importTag(v.Slug, v.Name)
+		case *wxr.Item:
+			switch v.Type {
+			case "post":
+				s.importPost(ctx, v)
+			case "attachment":
+				s.importAttachment(ctx, v)
+			default:
+				s.add(models.ImportItem{
+					Kind:     v.Type,
+					SourceID: v.ID,
+					Title:    v.Title,
+					Action:   models.ImportActionSkip,
+					Message:  "unsupported post type",
+				})
+			}
+		}
+	}
+
+	if s.report.Created+s.report.Updated > 0 {
+		invalidateContentCache()
+	}
+
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"total":   s.report.Total,
+		"created": s.report.Created,
+		"failed":  s.report.Failed,
+	})).Info("WordPress export imported")
+	return s.report, readErr
+}
+
+// importAuthor 按邮箱将作者映射到已有用户，不存在时创建新用户
+func (s *WordPressImporter) importAuthor(author *wxr.Author) {
+	item := models.ImportItem{Kind: models.ImportKindUser, SourceID: author.Login, Title: author.DisplayName}
+
+	email := strings.ToLower(strings.TrimSpace(author.Email))
+	if email == "" {
+		s.authors[author.Login] = s.DefaultUserID
+		s.authorIDs[author.ID] = s.DefaultUserID
+		item.Action, item.Message = models.ImportActionSkip, "author has no email, posts are assigned to the default user"
+		s.add(item)
+		return
+	}
+
+	userID, created, err := s.findOrCreateUser(author.Login, email)
+	if err != nil {
+		s.authors[author.Login] = s.DefaultUserID
+		s.authorIDs[author.ID] = s.DefaultUserID
+		item.Action, item.Message = models.ImportActionError, err.Error()
+		s.add(item)
+		return
+	}
+
+	s.authors[author.Login] = userID
+	s.authorIDs[author.ID] = userID
+	item.Action = models.ImportActionUnchanged
+	item.Message = "mapped to existing user by email"
+	if created {
+		item.Action, item.Message = models.ImportActionCreate, ""
+	}
+	s.add(item)
+}
+
+// findOrCreateUser 按邮箱查找用户，不存在时以默认角色创建，密码为随机值，需通过找回密码设置
+func (s *WordPressImporter) findOrCreateUser(name, email string) (uint, bool, error) {
+	if userID, ok := s.users[email]; ok {
+		return userID, false, nil
+	}
+
+	var user models.User
+	err := db.DB.Where("LOWER(email) = ?", email).First(&user).Error
+	if err == nil {
+		s.users[email] = user.ID
+		return user.ID, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, err
+	}
+	if len(email) > 128 {
+		return 0, false, errors.New("email cannot be longer than 128 characters")
+	}
+
+	// 用户名取登录名或邮箱前缀，已被占用时追加数字后缀
+	base := strings.TrimSpace(name)
+	if base == "" {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	if runes := []rune(base); len(runes) > 56 {
+		base = string(runes[:56])
+	}
+
+	hashedPassword, err := randomPasswordHash()
+	if err != nil {
+		return 0, false, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		username, err := slug.Unique(base, func(candidate string) (bool, error) {
+			var count int64
+			err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error
+			return count > 0, err
+		})
+		if err != nil {
+			return err
+		}
+
+		user = models.User{Username: username, Password: hashedPassword, Email: email}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		// 为新用户分配默认角色
+		var defaultRole models.Role
+		if err := tx.Where("is_default = ?", true).First(&defaultRole).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).Association("Roles").Append(&defaultRole)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	s.users[email] = user.ID
+	return user.ID, true, nil
+}
+
+// importTag 创建分类或标签定义，两者均导入为标签
+func (s *WordPressImporter) importTag(nicename, name string) {
+	if nicename == wordPressDefaultCategory {
+		return
+	}
+
+	item := models.ImportItem{Kind: models.ImportKindTag, SourceID: nicename, Title: name}
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		item.Action, item.Message = models.ImportActionError, "tag name is empty"
+	case len(name) > 50:
+		item.Action, item.Message = models.ImportActionError, "tag name must be less than 50 characters"
+	default:
+		var tag models.Tag
+		result := db.DB.Where("name = ?", name).FirstOrCreate(&tag, models.Tag{Name: name})
+		switch {
+		case result.Error != nil:
+			item.Action, item.Message = models.ImportActionError, result.Error.Error()
+		case result.RowsAffected > 0:
+			item.Action = models.ImportActionCreate
+		default:
+			item.Action = models.ImportActionUnchanged
+		}
+	}
+	s.add(item)
+}
+
+// importPost 导入文章及其评论
+// 以 slug 为唯一键，文章已存在时跳过，其评论也不再导入，以便重复执行导入
+func (s *WordPressImporter) importPost(ctx context.Context, wpPost *wxr.Item) {
+	item := models.ImportItem{Kind: models.ImportKindPost, SourceID: wpPost.ID, Title: wpPost.Title}
+
+	imported, err := s.parsePost(wpPost)
+	if err != nil {
+		item.Action, item.Message = models.ImportActionError, err.Error()
+		s.add(item)
+		return
+	}
+	if imported == nil {
+		item.Action, item.Message = models.ImportActionSkip, "post status "+wpPost.Status
+		s.add(item)
+		return
+	}
+	item.Slug = imported.Slug
+
+	var existing models.Post
+	found, err := findPostBySlug(db.DB, imported.Slug, &existing)
+	if err != nil {
+		item.Action, item.Message = models.ImportActionError, err.Error()
+		s.add(item)
+		return
+	}
+	if found {
+		s.posts[wpPost.ID] = &existing
+		item.PostID = existing.ID
+		item.Action, item.Message = models.ImportActionUnchanged, "post already exists, comments are not imported again"
+		s.add(item)
+		return
+	}
+
+	// 复制正文引用的附件并替换地址
+	authorID := s.authorID(wpPost.Creator)
+	content, mediaIDs, warnings := s.copyContentUploads(ctx, imported.Content, authorID)
+	imported.Content = content
+
+	var post models.Post
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Post{}).Where("title = ?", imported.Title).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("title already exists")
+		}
+
+		if imported.Slug, err = slug.Unique(imported.Slug, db.PostSlugTaken(tx, 0)); err != nil {
+			return err
+		}
+		if _, err := createImportedPost(tx, &post, authorID, imported); err != nil {
+			return err
+		}
+
+		// 正文中的附件关联到文章
+		if len(mediaIDs) > 0 {
+			return tx.Model(&models.Media{}).
+				Where("id IN ? AND user_id = ? AND post_id IS NULL", mediaIDs, authorID).
+				Update("post_id", post.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		item.Action, item.Message = models.ImportActionError, err.Error()
+		s.add(item)
+		return
+	}
+
+	s.posts[wpPost.ID] = &post
+	item.Slug, item.PostID = post.Slug, post.ID
+	item.Action, item.Message = models.ImportActionCreate, strings.Join(warnings, "; ")
+	s.add(item)
+
+	s.importComments(&post, wpPost.Comments)
+}
+
+// parsePost 将 WordPress 文章转换为待导入文章，回收站及自动草稿返回 nil
+// 已发布和定时发布的文章保留原发布时间，待审核和私密文章导入为草稿
+func (s *WordPressImporter) parsePost(wpPost *wxr.Item) (*importedPost, error) {
+	imported := &importedPost{
+		Title:   strings.TrimSpace(wpPost.Title),
+		Content: strings.TrimSpace(wpPost.Content),
+		Date:    wxr.ParseDate(wpPost.DateGMT, wpPost.Date),
+		HTML:    true,
+	}
+
+	switch wpPost.Status {
+	case "publish", "future":
+	case "draft", "pending", "private":
+		imported.Draft = true
+	default:
+		return nil, nil
+	}
+
+	if imported.Title == "" {
+		return nil, errors.New("title is empty")
+	}
+	if len(imported.Title) > 200 {
+		return nil, errors.New("title cannot be longer than 200 characters")
+	}
+	if imported.Content == "" {
+		return nil, errors.New("content is empty")
+	}
+
+	// post_name 可能经过 URL 编码（如中文 slug），草稿可能没有 post_name
+	name := imported.Title
+	if unescaped, err := url.PathUnescape(wpPost.Name); err == nil && strings.TrimSpace(unescaped) != "" {
+		name = unescaped
+	}
+	imported.Slug = slug.Make(name)
+
+	seen := make(map[string]bool)
+	for _, term := range wpPost.Terms {
+		tagName := strings.TrimSpace(term.Name)
+		if (term.Domain != "category" && term.Domain != "post_tag") || term.Nicename == wordPressDefaultCategory ||
+			tagName == "" || len(tagName) > 50 || seen[tagName] {
+			continue
+		}
+		seen[tagName] = true
+		imported.Tags = append(imported.Tags, tagName)
+	}
+	return imported, nil
+}
+
+// importComments 导入文章评论，按 WordPress 评论ID 顺序创建以保证父评论先于回复
+// 超过最大嵌套深度的回复与站内评论一样挂在父评论的父评论下
+func (s *WordPressImporter) importComments(post *models.Post, wpComments []wxr.Comment) {
+	sort.Slice(wpComments, func(i, j int) bool {
+		a, _ := strconv.Atoi(wpComments[i].ID)
+		b, _ := strconv.Atoi(wpComments[j].ID)
+		return a < b
+	})
+
+	imported := make(map[string]*models.Comment, len(wpComments))
+	for _, wpComment := range wpComments {
+		item := models.ImportItem{Kind: models.ImportKindComment, SourceID: wpComment.ID, PostID: post.ID}
+
+		comment, err := s.importComment(post, &wpComment, imported)
+		switch {
+		case err != nil:
+			item.Action, item.Message = models.ImportActionError, err.Error()
+		case comment == nil:
+			item.Action, item.Message = models.ImportActionSkip, "pingback, trackback, spam or trashed comment"
+		default:
+			imported[wpComment.ID] = comment
+			item.Action = models.ImportActionCreate
+		}
+		s.add(item)
+	}
+}
+
+// importComment 创建单条评论，无需导入的评论返回 nil
+func (s *WordPressImporter) importComment(post *models.Post, wpComment *wxr.Comment, imported map[string]*models.Comment) (*models.Comment, error) {
+	if (wpComment.Type != "" && wpComment.Type != "comment") ||
+		wpComment.Approved == "spam" || wpComment.Approved == "trash" {
+		return nil, nil
+	}
+
+	content := strings.TrimSpace(wpComment.Content)
+	if content == "" {
+		return nil, errors.New("content is empty")
+	}
+	if len(content) > 1000 {
+		return nil, errors.New("content cannot be longer than 1000 characters")
+	}
+
+	// 站内用户按 WordPress 用户ID 映射，访客按邮箱映射或创建用户
+	userID := s.authorIDs[wpComment.UserID]
+	if userID == 0 {
+		email := strings.ToLower(strings.TrimSpace(wpComment.AuthorEmail))
+		if email == "" {
+			return nil, errors.New("comment author has no email")
+		}
+		var err error
+		if userID, _, err = s.findOrCreateUser(wpComment.Author, email); err != nil {
+			return nil, err
+		}
+	}
+
+	// WordPress 评论与正文一样以 HTML 保存
+	contentHTML := sanitizer.SanitizeHTML(autoParagraph(content))
+
+	comment := &models.Comment{
+		Content:     content,
+		ContentHTML: contentHTML,
+		PostID:      post.ID,
+		UserID:      userID,
+		Status:      models.CommentStatusPending,
+	}
+	if wpComment.Approved == "1" {
+		comment.Status = models.CommentStatusApproved
+	}
+	if date := wxr.ParseDate(wpComment.DateGMT, wpComment.Date); date != nil {
+		comment.CreatedAt = *date
+	}
+
+	if wpComment.Parent != "" && wpComment.Parent != "0" {
+		parent := imported[wpComment.Parent]
+		if parent == nil {
+			return nil, errors.New("parent comment was not imported")
+		}
+		if parent.Depth >= models.MaxCommentDepth-1 && parent.ParentID != nil {
+			comment.ParentID = parent.ParentID
+			comment.Depth = parent.Depth
+		} else {
+			comment.ParentID = &parent.ID
+			comment.Depth = parent.Depth + 1
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if comment.Status == models.CommentStatusApproved {
+			return adjustReplyCount(tx, comment.ParentID, 1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// importAttachment 复制附件到存储，父文章已导入时关联到该文章
+func (s *WordPressImporter) importAttachment(ctx context.Context, attachment *wxr.Item) {
+	item := models.ImportItem{Kind: models.ImportKindAttachment, SourceID: attachment.ID, Title: attachment.Title}
+
+	rel, ok := uploadPath(attachment.AttachmentURL)
+	if !ok {
+		item.Action, item.Message = models.ImportActionError, "attachment is not under wp-content/uploads"
+		s.add(item)
+		return
+	}
+
+	// 附件归属父文章作者，以便关联到文章
+	ownerID := s.authorID(attachment.Creator)
+	post := s.posts[attachment.Parent]
+	if post != nil {
+		ownerID = post.UserID
+	}
+
+	_, copied := s.uploads[rel]
+	mediaItem, err := s.copyUpload(ctx, rel, ownerID)
+	if err != nil {
+		item.Action, item.Message = models.ImportActionError, err.Error()
+		s.add(item)
+		return
+	}
+
+	if post != nil && mediaItem.PostID == nil && mediaItem.UserID == post.UserID {
+		if err := db.DB.Model(mediaItem).Update("post_id", post.ID).Error; err != nil {
+			item.Action, item.Message = models.ImportActionError, err.Error()
+			s.add(item)
+			return
+		}
+		mediaItem.PostID = &post.ID
+		item.PostID = post.ID
+	}
+
+	item.Action = models.ImportActionCreate
+	if copied {
+		item.Action, item.Message = models.ImportActionUnchanged, "already copied from post content"
+	}
+	s.add(item)
+}
+
+// copyContentUploads 复制正文中引用的 uploads 文件并将地址替换为本站地址
+// 复制失败的文件保留原地址，并返回失败原因
+func (s *WordPressImporter) copyContentUploads(ctx context.Context, content string, userID uint) (string, []uint, []string) {
+	var mediaIDs []uint
+	var warnings []string
+	failed := make(map[string]bool)
+
+	result := wordPressUploadPattern.ReplaceAllStringFunc(content, func(match string) string {
+		rel, ok := uploadPath(match)
+		if !ok || failed[rel] {
+			return match
+		}
+
+		mediaItem, err := s.copyUpload(ctx, rel, userID)
+		if err != nil {
+			failed[rel] = true
+			warnings = append(warnings, rel+": "+err.Error())
+			return match
+		}
+		mediaIDs = append(mediaIDs, mediaItem.ID)
+		return mediaItem.URL
+	})
+	return result, mediaIDs, warnings
+}
+
+// copyUpload 从 uploads 目录复制文件到存储，同一文件只复制一次
+// 导入的文件与上传的文件一样识别类型并去除图片元数据，衍生图由定期扫描生成
+func (s *WordPressImporter) copyUpload(ctx context.Context, rel string, userID uint) (*models.Media, error) {
+	if mediaItem := s.uploads[rel]; mediaItem != nil {
+		return mediaItem, nil
+	}
+	if s.UploadsDir == "" {
+		return nil, errors.New("uploads directory is not provided")
+	}
+
+	filename := filepath.Join(s.UploadsDir, filepath.FromSlash(rel))
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, errors.New("file not found in uploads directory")
+	}
+	if info.Size() > s.Media.Upload.MaxFileSize {
+		return nil, fmt.Errorf("file cannot be larger than %d bytes", s.Media.Upload.MaxFileSize)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaItem, err := s.Media.UploadMedia(ctx, userID, nil, path.Base(rel), data)
+	if err != nil {
+		return nil, err
+	}
+	s.uploads[rel] = mediaItem
+	return mediaItem, nil
+}
+
+// authorID 返回作者登录名对应的用户，未映射时返回默认用户
+func (s *WordPressImporter) authorID(login string) uint {
+	if userID := s.authors[login]; userID != 0 {
+		return userID
+	}
+	return s.DefaultUserID
+}
+
+// add 记录条目结果
+func (s *WordPressImporter) add(item models.ImportItem) {
+	addImportItem(s.report, item)
+}
+
+// uploadPath 从附件地址中提取 uploads 下的相对路径，拒绝跳出 uploads 目录的路径
+func uploadPath(rawURL string) (string, bool) {
+	m := wordPressUploadPattern.FindStringSubmatch(rawURL)
+	if m == nil {
+		return "", false
+	}
+	rel, err := url.PathUnescape(m[1])
+	if err != nil {
+		return "", false
+	}
+	rel = path.Clean(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || strings.HasPrefix(rel, "/") {
+		return "", false
+	}
+	return rel, true
+}
+
+// wordPressBlockPattern 以块级元素开头的段落，分段时不再包裹 <p>
+var wordPressBlockPattern = regexp.MustCompile(`(?i)^<(?:p|div|h[1-6]|ul|ol|li|blockquote|pre|table|figure|hr|!--)[\s>/]`)
+
+// blankLinePattern 分隔段落的空行
+var blankLinePattern = regexp.MustCompile(`\n\s*\n`)
+
+// autoParagraph 按 WordPress wpautop 的规则将空行分隔的文本包裹为段落
+// 经典编辑器保存的正文不含 <p>，段内换行转换为 <br>，已是块级元素的段落保持原样
+func autoParagraph(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var b strings.Builder
+	for _, block := range blankLinePattern.Split(content, -1) {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		if wordPressBlockPattern.MatchString(block) {
+			b.WriteString(block)
+		} else {
+			b.WriteString("<p>" + strings.ReplaceAll(block, "\n", "<br>\n") + "</p>")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrUnsupportedFormat 不支持的压缩包格式
+var ErrUnsupportedFormat = errors.New("unsupported archive format, expected zip, tar or tar.gz")
+
+// File 压缩包中的文件
+type File struct {
+	Name string // 压缩包内的相对路径，统一使用 / 分隔
+	Data []byte
+}
+
+// Limits 解压限制，防止压缩炸弹
+type Limits struct {
+	MaxFileSize int64 // 单个文件解压后的最大字节数
+	MaxFiles    int   // 最多读取的文件数
+}
+
+// ReadFiles 读取 zip、tar 或 tar.gz 压缩包中 match 返回 true 的普通文件
+// 格式按文件头识别；超过单文件大小限制或文件数限制时返回错误
+func ReadFiles(data []byte, match func(name string) bool, limits Limits) ([]File, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return readZip(data, match, limits)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return readTar(gz, match, limits)
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		return readTar(bytes.NewReader(data), match, limits)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// readZip 读取 zip 压缩包
+func readZip(data []byte, match func(string) bool, limits Limits) ([]File, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, entry := range reader.File {
+		name, ok := cleanName(entry.Name)
+		if !ok || entry.FileInfo().IsDir() || !entry.Mode().IsRegular() || !match(name) {
+			continue
+		}
+		if len(files) >= limits.MaxFiles {
+			return nil, fmt.Errorf("archive contains more than %d files", limits.MaxFiles)
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := readLimited(rc, name, limits.MaxFileSize)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: name, Data: content})
+	}
+	return files, nil
+}
+
+// readTar 读取 tar 压缩包
+func readTar(r io.Reader, match func(string) bool, limits Limits) ([]File, error) {
+	reader := tar.NewReader(r)
+
+	var files []File
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := cleanName(header.Name)
+		if !ok || header.Typeflag != tar.TypeReg || !match(name) {
+			continue
+		}
+		if len(files) >= limits.MaxFiles {
+			return nil, fmt.Errorf("archive contains more than %d files", limits.MaxFiles)
+		}
+
+		content, err := readLimited(reader, name, limits.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: name, Data: content})
+	}
+}
+
+// readLimited 读取文件内容，超过 maxSize 时返回错误
+func readLimited(r io.Reader, name string, maxSize int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("%s exceeds the maximum file size of %d bytes", name, maxSize)
+	}
+	return content, nil
+}
+
+// cleanName 规范化压缩包内路径，拒绝绝对路径和跳出根目录的路径
+func cleanName(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || strings.HasPrefix(name, "/") || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
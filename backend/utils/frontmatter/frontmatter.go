@@ -0,0 +1,182 @@
+package frontmatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnterminated 找到了起始分隔符但没有结束分隔符
+var ErrUnterminated = errors.New("front matter is not terminated")
+
+// Matter Hugo / Jekyll 文章的 front matter
+type Matter struct {
+	Title string
+	Date  *time.Time
+	Tags  []string
+	Slug  string
+	Draft bool
+}
+
+// dateLayouts 支持的日期格式，覆盖 Hugo（RFC 3339）和 Jekyll（YYYY-MM-DD HH:MM:SS +0800）的常见写法
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Parse 拆分 front matter 与正文，支持 YAML（---）和 TOML（+++）
+// 没有 front matter 时返回空的 Matter 和完整内容
+func Parse(data []byte) (*Matter, []byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var delimiter string
+	switch {
+	case bytes.HasPrefix(data, []byte("---\n")):
+		delimiter = "---"
+	case bytes.HasPrefix(data, []byte("+++\n")):
+		delimiter = "+++"
+	default:
+		return &Matter{}, data, nil
+	}
+
+	// 查找结束分隔符所在行
+	rest := data[len(delimiter)+1:]
+	var raw, body []byte
+	found := false
+	for offset := 0; offset <= len(rest); {
+		end := bytes.IndexByte(rest[offset:], '\n')
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if strings.TrimRight(string(line), " \t") == delimiter {
+			raw = rest[:offset]
+			if end >= 0 {
+				body = rest[offset+end+1:]
+			}
+			found = true
+			break
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+	if !found {
+		return nil, nil, ErrUnterminated
+	}
+
+	values := map[string]interface{}{}
+	var err error
+	if delimiter == "---" {
+		err = yaml.Unmarshal(raw, &values)
+	} else {
+		err = toml.Unmarshal(raw, &values)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	matter, err := decode(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return matter, body, nil
+}
+
+// decode 从 front matter 中提取需要的字段
+// Jekyll 使用 published: false 表示草稿，tags 也可以是空格分隔的字符串
+func decode(values map[string]interface{}) (*Matter, error) {
+	matter := &Matter{
+		Title: stringValue(values["title"]),
+		Slug:  stringValue(values["slug"]),
+		Tags:  stringList(values["tags"]),
+	}
+
+	if draft, ok := values["draft"].(bool); ok {
+		matter.Draft = draft
+	}
+	if published, ok := values["published"].(bool); ok && !published {
+		matter.Draft = true
+	}
+
+	if value, ok := values["date"]; ok && value != nil {
+		date, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		matter.Date = date
+	}
+	return matter, nil
+}
+
+// parseDate 解析 YAML / TOML 中的日期，兼容原生日期类型和字符串
+func parseDate(value interface{}) (*time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return &v, nil
+	case toml.LocalDateTime:
+		t := v.AsTime(time.Local)
+		return &t, nil
+	case toml.LocalDate:
+		t := v.AsTime(time.Local)
+		return &t, nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range dateLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return &t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid date: %v", value)
+}
+
+// stringValue 将标量转换为字符串
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// stringList 将列表或以空格、逗号分隔的字符串转换为去重后的字符串列表
+func stringList(value interface{}) []string {
+	var items []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			items = append(items, stringValue(item))
+		}
+	case string:
+		items = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	}
+
+	seen := make(map[string]bool, len(items))
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,251 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"keep_learning_blog/config"
+	"math/big"
+	"os"
+
+	"keep_learning_blog/utils/logger"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// minRSABits RSA 密钥的最小长度
+const minRSABits = 2048
+
+// Key 令牌签名密钥，仅用于校验的旧密钥不包含私钥
+type Key struct {
+	ID        string // kid，取公钥的 JWK 指纹（RFC 7638）
+	Algorithm string // RS256 或 EdDSA
+
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// KeySet 签名密钥集合：一个当前签名密钥和若干仅用于校验的密钥
+type KeySet struct {
+	signing *Key
+	keys    []*Key
+	byID    map[string]*Key
+}
+
+// JSONWebKey JWKS 中的公钥（RFC 7517）
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`   // RSA 模数
+	E   string `json:"e,omitempty"`   // RSA 指数
+	Crv string `json:"crv,omitempty"` // OKP 曲线
+	X   string `json:"x,omitempty"`   // OKP 公钥
+}
+
+// JSONWebKeySet 公布的公钥集合
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Load 根据配置加载签名密钥和校验密钥
+// 开发环境未配置签名密钥时生成临时的 Ed25519 密钥，重启后之前签发的令牌全部失效；其他环境未配置时返回错误
+func Load(cfg *config.JWTConfig) (*KeySet, error) {
+	set := &KeySet{byID: make(map[string]*Key)}
+
+	if cfg.SigningKeyFile == "" {
+		if !cfg.TemporaryKey {
+			return nil, errors.New("JWT_SIGNING_KEY_FILE is not set")
+		}
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signing, err := newKey(private, private.Public())
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.WithField("kid", signing.ID).
+			Warn("JWT_SIGNING_KEY_FILE is not set, using a temporary signing key, tokens will be invalid after restart")
+		set.signing = signing
+	} else {
+		signing, err := loadKeyFile(cfg.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if signing.private == nil {
+			return nil, fmt.Errorf("signing key %s must be a private key", cfg.SigningKeyFile)
+		}
+		set.signing = signing
+	}
+	set.add(set.signing)
+
+	for _, path := range cfg.VerificationKeyFiles {
+		key, err := loadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		set.add(key)
+	}
+
+	return set, nil
+}
+
+// add 加入密钥，kid 重复时忽略
+func (s *KeySet) add(key *Key) {
+	if _, ok := s.byID[key.ID]; ok {
+		return
+	}
+	s.byID[key.ID] = key
+	s.keys = append(s.keys, key)
+}
+
+// SigningKey 返回当前签名密钥
+func (s *KeySet) SigningKey() *Key {
+	return s.signing
+}
+
+// Sign 使用当前签名密钥签发令牌，头部携带 kid
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.private)
+}
+
+// Keyfunc 根据令牌头部的 kid 选择校验公钥，算法必须与密钥一致，防止算法混淆
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.byID[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+// JWKS 返回所有有效密钥的公钥，供其他服务离线校验令牌
+func (s *KeySet) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+// JWK 返回密钥的公钥部分
+func (k *Key) JWK() JSONWebKey {
+	jwk := JSONWebKey{Use: "sig", Alg: k.Algorithm, Kid: k.ID}
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+// loadKeyFile 读取 PEM 格式的密钥文件，支持 PKCS#1、PKCS#8 私钥及 PKIX 公钥
+func loadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("JWT key %s is not PEM encoded", path)
+	}
+
+	var private crypto.PrivateKey
+	var public crypto.PublicKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("JWT key %s has unsupported PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT key %s: %w", path, err)
+	}
+	if signer, ok := private.(crypto.Signer); ok {
+		public = signer.Public()
+	}
+
+	key, err := newKey(private, public)
+	if err != nil {
+		return nil, fmt.Errorf("JWT key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// newKey 根据公钥类型确定签名算法并计算 kid
+func newKey(private crypto.PrivateKey, public crypto.PublicKey) (*Key, error) {
+	key := &Key{private: private, public: public}
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+		}
+		key.Algorithm = jwt.SigningMethodRS256.Alg()
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Algorithm = jwt.SigningMethodEdDSA.Alg()
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	id, err := thumbprint(key.JWK())
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+	return key, nil
+}
+
+// thumbprint 计算 JWK 指纹（RFC 7638），只包含必需成员并按字典序排列
+func thumbprint(jwk JSONWebKey) (string, error) {
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
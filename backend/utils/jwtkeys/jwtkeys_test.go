@@ -0,0 +1,339 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"keep_learning_blog/config"
+	"keep_learning_blog/utils/logger"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+)
+
+// 测试使用的密钥，RSA 密钥生成较慢，所有测试共用
+var (
+	rsaKey     = mustRSAKey(2048)
+	ed25519Key = mustEd25519Key()
+)
+
+func mustRSAKey(bits int) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustEd25519Key() ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// writePEM 将 DER 数据以指定的 PEM 类型写入临时文件
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// pkcs8 将私钥编码为 PKCS#8
+func pkcs8(t *testing.T, key crypto.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// pkix 将公钥编码为 PKIX
+func pkix(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// TestLoadKeyFile 支持的 PEM 格式及按密钥类型确定的算法
+func TestLoadKeyFile(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		blockType   string
+		der         []byte
+		algorithm   string
+		wantPrivate bool
+		wantErr     string
+	}{
+		{"RSA PKCS#1 private key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), "RS256", true, ""},
+		{"RSA PKCS#8 private key", "PRIVATE KEY", pkcs8(t, rsaKey), "RS256", true, ""},
+		{"RSA PKCS#1 public key", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), "RS256", false, ""},
+		{"RSA PKIX public key", "PUBLIC KEY", pkix(t, &rsaKey.PublicKey), "RS256", false, ""},
+		{"Ed25519 PKCS#8 private key", "PRIVATE KEY", pkcs8(t, ed25519Key), "EdDSA", true, ""},
+		{"Ed25519 PKIX public key", "PUBLIC KEY", pkix(t, ed25519Key.Public()), "EdDSA", false, ""},
+		{"short RSA key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(mustRSAKey(1024)), "", false, "at least 2048 bits"},
+		{"ECDSA PKCS#8 key", "PRIVATE KEY", pkcs8(t, ecKey), "", false, "only RSA and Ed25519"},
+		{"unsupported PEM type", "EC PRIVATE KEY", ecDER, "", false, "unsupported PEM type"},
+		{"corrupt key", "PRIVATE KEY", []byte("not a key"), "", false, "failed to parse"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := loadKeyFile(writePEM(t, tc.blockType, tc.der))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("loadKeyFile() error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key.Algorithm != tc.algorithm {
+				t.Fatalf("algorithm = %s, want %s", key.Algorithm, tc.algorithm)
+			}
+			if (key.private != nil) != tc.wantPrivate {
+				t.Fatalf("has private key = %v, want %v", key.private != nil, tc.wantPrivate)
+			}
+		})
+	}
+
+	t.Run("not PEM", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key.pem")
+		if err := os.WriteFile(path, []byte("plain text"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadKeyFile(path); err == nil || !strings.Contains(err.Error(), "not PEM encoded") {
+			t.Fatalf("loadKeyFile() error = %v, want not PEM encoded", err)
+		}
+	})
+}
+
+// TestKeyIDMatchesAcrossFormats 同一密钥的私钥和公钥文件得到相同的 kid
+func TestKeyIDMatchesAcrossFormats(t *testing.T) {
+	private, err := loadKeyFile(writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := loadKeyFile(writePEM(t, "PUBLIC KEY", pkix(t, &rsaKey.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if private.ID != public.ID {
+		t.Fatalf("kid of private key %s differs from public key %s", private.ID, public.ID)
+	}
+}
+
+// TestThumbprint RFC 7638 第 3.1 节及 RFC 8037 附录 A.3 的 JWK 指纹示例
+func TestThumbprint(t *testing.T) {
+	cases := []struct {
+		name string
+		jwk  JSONWebKey
+		want string
+	}{
+		{
+			name: "RFC 7638 RSA",
+			jwk: JSONWebKey{
+				Kty: "RSA",
+				E:   "AQAB",
+				N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+					"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbI" +
+					"SD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			name: "RFC 8037 Ed25519",
+			jwk:  JSONWebKey{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := thumbprint(tc.jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("thumbprint = %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	// kid 由密钥计算得到，与 JWKS 中公布的公钥一致
+	key, err := newKey(ed25519Key, ed25519Key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(ed25519Key.Public().(ed25519.PublicKey))
+	want, _ := thumbprint(JSONWebKey{Kty: "OKP", Crv: "Ed25519", X: x})
+	if key.ID != want || key.JWK().X != x {
+		t.Fatalf("kid = %s, want %s", key.ID, want)
+	}
+}
+
+// TestLoad 签名密钥必须为私钥，仅开发环境允许生成临时密钥
+func TestLoad(t *testing.T) {
+	logger.Log = logrus.New()
+	logger.Log.SetOutput(io.Discard)
+
+	if _, err := Load(&config.JWTConfig{}); err == nil || !strings.Contains(err.Error(), "JWT_SIGNING_KEY_FILE is not set") {
+		t.Fatalf("Load without a signing key: err = %v", err)
+	}
+
+	set, err := Load(&config.JWTConfig{TemporaryKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.SigningKey().Algorithm != "EdDSA" || len(set.JWKS().Keys) != 1 {
+		t.Fatalf("temporary key set = %+v", set.JWKS())
+	}
+
+	public := writePEM(t, "PUBLIC KEY", pkix(t, &rsaKey.PublicKey))
+	if _, err := Load(&config.JWTConfig{SigningKeyFile: public}); err == nil || !strings.Contains(err.Error(), "must be a private key") {
+		t.Fatalf("Load with a public signing key: err = %v", err)
+	}
+}
+
+// signedToken 使用指定算法、kid 和密钥签发令牌，用于构造攻击者伪造的令牌
+func signedToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, jwt.StandardClaims{Subject: "1", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// TestKeyfunc 令牌的算法必须与 kid 对应的密钥一致，未知的 kid 被拒绝
+func TestKeyfunc(t *testing.T) {
+	set, err := Load(&config.JWTConfig{
+		SigningKeyFile:       writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		VerificationKeyFiles: []string{writePEM(t, "PUBLIC KEY", pkix(t, ed25519Key.Public()))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKID := set.SigningKey().ID
+	edKID := set.JWKS().Keys[1].Kid
+	rsaPublicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix(t, &rsaKey.PublicKey)})
+
+	valid, err := set.Sign(jwt.StandardClaims{Subject: "1", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"signed by the key set", valid, true},
+		{"EdDSA with the Ed25519 kid", signedToken(t, jwt.SigningMethodEdDSA, edKID, ed25519Key), true},
+		// 以公开的 RSA 公钥作为 HMAC 密钥伪造令牌
+		{"HS256 with the RSA kid", signedToken(t, jwt.SigningMethodHS256, rsaKID, rsaPublicPEM), false},
+		{"HS256 with the RSA public key bytes", signedToken(t, jwt.SigningMethodHS256, rsaKID, x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)), false},
+		{"EdDSA with the RSA kid", signedToken(t, jwt.SigningMethodEdDSA, rsaKID, ed25519Key), false},
+		{"RS256 with the Ed25519 kid", signedToken(t, jwt.SigningMethodRS256, edKID, rsaKey), false},
+		{"unknown kid", signedToken(t, jwt.SigningMethodRS256, "unknown", rsaKey), false},
+		{"missing kid", signedToken(t, jwt.SigningMethodRS256, "", rsaKey), false},
+		{"unlisted key", signedToken(t, jwt.SigningMethodRS256, rsaKID, mustRSAKey(2048)), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := jwt.Parse(tc.token, set.Keyfunc)
+			if valid := err == nil && token.Valid; valid != tc.valid {
+				t.Fatalf("valid = %v (err %v), want %v", valid, err, tc.valid)
+			}
+		})
+	}
+}
+
+// TestRotation 轮换后旧密钥仅用于校验，新令牌使用新密钥签发，移除旧密钥后其签发的令牌失效
+func TestRotation(t *testing.T) {
+	oldKey := writePEM(t, "PRIVATE KEY", pkcs8(t, ed25519Key))
+	oldSet, err := Load(&config.JWTConfig{SigningKeyFile: oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.StandardClaims{Subject: "1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	oldToken, err := oldSet.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	rotated, err := Load(&config.JWTConfig{
+		SigningKeyFile:       newKey,
+		VerificationKeyFiles: []string{writePEM(t, "PUBLIC KEY", pkix(t, ed25519Key.Public())), newKey},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.SigningKey().Algorithm != "RS256" {
+		t.Fatalf("signing algorithm = %s, want RS256", rotated.SigningKey().Algorithm)
+	}
+	// 与签名密钥相同的校验密钥按 kid 去重
+	if keys := rotated.JWKS().Keys; len(keys) != 2 || keys[1].Kid != oldSet.SigningKey().ID {
+		t.Fatalf("JWKS = %+v, want the signing key and the old key", keys)
+	}
+
+	if _, err := jwt.Parse(oldToken, rotated.Keyfunc); err != nil {
+		t.Fatalf("token signed by the rotated-out key rejected: %v", err)
+	}
+	newToken, err := rotated.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(newToken, rotated.Keyfunc); err != nil {
+		t.Fatalf("token signed by the new key rejected: %v", err)
+	}
+
+	retired, err := Load(&config.JWTConfig{SigningKeyFile: newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(oldToken, retired.Keyfunc); err == nil {
+		t.Fatal("token signed by a removed key accepted")
+	}
+}
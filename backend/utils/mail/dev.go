@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"keep_learning_blog/utils/logger"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Log 将邮件写入系统日志，用于开发环境
+type Log struct {
+	From *mail.Address
+}
+
+// Send 记录邮件收件人、主题和正文
+func (l *Log) Send(ctx context.Context, msg *Message) error {
+	logger.Log.WithFields(logger.Fields(map[string]interface{}{
+		"from":    l.From.String(),
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	})).Info("Mail sent to log")
+	return nil
+}
+
+// File 将邮件以 mbox 格式追加写入文件，用于开发环境
+type File struct {
+	Path string
+	From *mail.Address
+
+	mu sync.Mutex
+}
+
+// Send 追加写入一封邮件
+func (f *File) Send(ctx context.Context, msg *Message) error {
+	data, err := build(f.From, msg, false)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "From %s %s\r\n", f.From.Address, time.Now().Format(time.ANSIC)); err != nil {
+		out.Close()
+		return err
+	}
+	// 转义正文中以 "From " 开头的行，避免被误认为下一封邮件
+	data = bytes.ReplaceAll(data, []byte("\nFrom "), []byte("\n>From "))
+	if _, err := out.Write(data); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
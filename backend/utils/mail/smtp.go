@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"keep_learning_blog/config"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpTimeout 单封邮件发送的最长时间
+const smtpTimeout = 30 * time.Second
+
+// SMTP 通过 SMTP 服务器发送邮件，服务器支持时自动启用 STARTTLS
+type SMTP struct {
+	Config config.SMTPConfig
+	From   *mail.Address
+}
+
+// Send 发送一封邮件
+func (s *SMTP) Send(ctx context.Context, msg *Message) error {
+	data, err := build(s.From, msg, true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Config.Host, s.Config.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.From.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,28 @@
+package markdown
+
+import (
+	"bytes"
+	"keep_learning_blog/utils/sanitizer"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// engine CommonMark/GFM 渲染器
+// 允许原始 HTML 通过渲染，渲染结果统一经过富文本策略过滤
+var engine = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// Render 将 Markdown 渲染为经过过滤的安全 HTML
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := engine.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return sanitizer.SanitizeHTML(buf.String()), nil
+}
@@ -0,0 +1,157 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码器
+
+	"github.com/disintegration/imaging"
+	"github.com/gabriel-vasile/mimetype"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// 文件扩展名，按识别出的类型确定，不信任上传文件名
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// Detect 根据文件内容（魔数）识别 MIME 类型，返回类型及对应扩展名
+func Detect(data []byte) (mimeType, ext string) {
+	mimeType = mimetype.Detect(data).String()
+	if i := bytes.IndexByte([]byte(mimeType), ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType, extensions[mimeType]
+}
+
+// IsImage 判断是否为支持的图片类型
+func IsImage(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Dimensions 获取图片宽高
+func Dimensions(data []byte) (width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// StripMetadata 去除图片中的 EXIF 等元数据（可能包含拍摄位置、设备信息）
+// JPEG 按 EXIF 方向旋转后重新编码；PNG、WebP 直接删除元数据块，不影响画质；其他类型原样返回
+func StripMetadata(data []byte, mimeType string) ([]byte, error) {
+	switch mimeType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+// stripJPEG 重新编码 JPEG，编码结果不包含 EXIF 段
+func stripJPEG(data []byte) ([]byte, error) {
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pngSignature PNG 文件头
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks 需要删除的 PNG 元数据块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"iTXt": true,
+	"zTXt": true,
+	"tIME": true,
+}
+
+// stripPNG 删除 PNG 中的 EXIF 及文本块
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("invalid png file")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	for pos := len(pngSignature); pos < len(data); {
+		// 块结构：长度(4) + 类型(4) + 数据 + CRC(4)
+		if pos+8 > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errors.New("truncated png chunk")
+		}
+		if !pngMetadataChunks[string(data[pos+4:pos+8])] {
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	return out.Bytes(), nil
+}
+
+// stripWebP 删除 WebP 中的 EXIF 和 XMP 块，并清除 VP8X 中对应的标志位
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("invalid webp file")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[0:12])
+	for pos := 12; pos < len(data); {
+		// 块结构：类型(4) + 长度(4, 小端) + 数据 + 奇数长度时的填充字节
+		if pos+8 > len(data) {
+			return nil, errors.New("truncated webp chunk")
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			return nil, errors.New("truncated webp chunk")
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// 丢弃元数据块
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF、XMP 标志位
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	// 更新 RIFF 文件长度
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}
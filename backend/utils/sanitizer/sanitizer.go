@@ -0,0 +1,45 @@
+package sanitizer
+
+import (
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var (
+	// StrictPolicy 用于普通文本，完全转义所有HTML
+	StrictPolicy = bluemonday.StrictPolicy()
+
+	// HTMLPolicy 用于富文本，允许安全的HTML标签
+	HTMLPolicy = bluemonday.UGCPolicy()
+)
+
+func init() {
+	// 配置富文本策略
+	HTMLPolicy.AllowStandardURLs()
+	HTMLPolicy.AllowStandardAttributes()
+	// 允许常用的安全标签
+	HTMLPolicy.AllowElements("p", "br", "b", "i", "strong", "em", "ul", "ol", "li", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "code", "pre", "hr")
+	// 允许 GFM 表格和删除线
+	HTMLPolicy.AllowElements("table", "thead", "tbody", "tr", "th", "td", "del")
+	HTMLPolicy.AllowAttrs("align").Matching(bluemonday.CellAlign).OnElements("th", "td")
+	// 允许 GFM 任务列表复选框
+	HTMLPolicy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	HTMLPolicy.AllowAttrs("checked", "disabled").OnElements("input")
+	// 允许链接
+	HTMLPolicy.AllowAttrs("href").OnElements("a")
+	// 允许图片
+	HTMLPolicy.AllowAttrs("src", "alt").OnElements("img")
+	// 允许代码高亮相关属性
+	HTMLPolicy.AllowAttrs("class").OnElements("code", "pre")
+}
+
+// SanitizeHTML 用于富文本内容的过滤
+func SanitizeHTML(content string) string {
+	return HTMLPolicy.Sanitize(content)
+}
+
+// SanitizeText 用于普通文本的过滤
+func SanitizeText(content string) string {
+	return StrictPolicy.Sanitize(content)
+}
@@ -0,0 +1,81 @@
+package slug
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxLength   = 80     // slug 最大长度（不含去重后缀）
+	defaultSlug = "post" // 无法生成 slug 时的默认值
+)
+
+// pinyinArgs 汉字转拼音参数（不带声调）
+var pinyinArgs = pinyin.NewArgs()
+
+// Make 根据标题生成 URL 友好的 slug，汉字转换为拼音，去除字母上的重音符号，其余字符仅保留字母和数字
+func Make(title string) string {
+	var words []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// 跳过分解后的重音符号
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+				words = append(words, py[0])
+			}
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	// 拼接并截断到最大长度，避免截断在单词中间
+	result := ""
+	for _, w := range words {
+		next := w
+		if result != "" {
+			next = result + "-" + w
+		}
+		if len(next) > maxLength {
+			break
+		}
+		result = next
+	}
+
+	if result == "" {
+		return defaultSlug
+	}
+	return result
+}
+
+// Unique 在 base 已被占用时依次追加 -2、-3 等后缀，直到 exists 返回 false
+func Unique(base string, exists func(slug string) (bool, error)) (string, error) {
+	candidate := base
+	for i := 2; ; i++ {
+		taken, err := exists(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+}
@@ -0,0 +1,150 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxTokenLength = 64  // 超过该长度的词忽略
+	maxTokens      = 200 // 每条内容最多参与分类的词数
+)
+
+// TokenCount 词在垃圾和正常样本中出现的次数
+type TokenCount struct {
+	Token     string
+	SpamCount int64
+	HamCount  int64
+}
+
+// TokenStore 贝叶斯分类器的词频存储
+type TokenStore interface {
+	// Documents 返回垃圾和正常样本总数
+	Documents(ctx context.Context) (spamDocs, hamDocs int64, err error)
+	// Counts 返回已出现过的词的词频
+	Counts(ctx context.Context, tokens []string) ([]TokenCount, error)
+	// Add 将一条样本计入（delta 为 1）或移出（delta 为 -1）指定类别
+	Add(ctx context.Context, tokens []string, isSpam bool, delta int64) error
+}
+
+// BayesChecker 基于评论的朴素贝叶斯分类器，通过人工审核结果训练
+type BayesChecker struct {
+	Store               TokenStore
+	SpamThreshold       float64 // 垃圾概率达到该值判定为垃圾内容
+	SuspiciousThreshold float64 // 垃圾概率达到该值进入人工审核
+	MinDocuments        int64   // 两类样本均达到该数量后才给出结论
+}
+
+// Name 返回检查器名称
+func (b *BayesChecker) Name() string {
+	return "bayes"
+}
+
+// Check 计算评论为垃圾内容的概率
+func (b *BayesChecker) Check(ctx context.Context, content *Content) (Result, error) {
+	if content.Kind != KindComment {
+		return Result{Verdict: Ham}, nil
+	}
+	tokens := tokenize(content.Text)
+	if len(tokens) == 0 {
+		return Result{Verdict: Ham}, nil
+	}
+
+	// 样本不足时不做判定
+	spamDocs, hamDocs, err := b.Store.Documents(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	if spamDocs < b.MinDocuments || hamDocs < b.MinDocuments {
+		return Result{Verdict: Ham}, nil
+	}
+
+	counts, err := b.Store.Counts(ctx, tokens)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// 对数几率累加，使用拉普拉斯平滑，未出现过的词不参与计算
+	logOdds := math.Log(float64(spamDocs) / float64(hamDocs))
+	for _, count := range counts {
+		pSpam := float64(count.SpamCount+1) / float64(spamDocs+2)
+		pHam := float64(count.HamCount+1) / float64(hamDocs+2)
+		logOdds += math.Log(pSpam) - math.Log(pHam)
+	}
+	probability := 1 / (1 + math.Exp(-logOdds))
+
+	reason := fmt.Sprintf("spam probability %.3f", probability)
+	switch {
+	case probability >= b.SpamThreshold:
+		return Result{Verdict: Spam, Reason: reason}, nil
+	case probability >= b.SuspiciousThreshold:
+		return Result{Verdict: Suspicious, Reason: reason}, nil
+	}
+	return Result{Verdict: Ham, Reason: reason}, nil
+}
+
+// Train 将评论作为垃圾或正常样本计入词频
+func (b *BayesChecker) Train(ctx context.Context, content *Content, isSpam bool) error {
+	return b.add(ctx, content, isSpam, 1)
+}
+
+// Untrain 撤销之前的训练，审核结论变更时先移出原类别再重新训练
+func (b *BayesChecker) Untrain(ctx context.Context, content *Content, isSpam bool) error {
+	return b.add(ctx, content, isSpam, -1)
+}
+
+// add 调整样本的词频
+func (b *BayesChecker) add(ctx context.Context, content *Content, isSpam bool, delta int64) error {
+	if content.Kind != KindComment {
+		return nil
+	}
+	tokens := tokenize(content.Text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	return b.Store.Add(ctx, tokens, isSpam, delta)
+}
+
+// tokenize 将内容切分为去重后的小写词，汉字按单字和相邻双字切分
+func tokenize(text string) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(token string) {
+		if token == "" || len(token) > maxTokenLength || seen[token] || len(tokens) >= maxTokens {
+			return
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+
+	var word strings.Builder
+	var prevHan rune
+	flush := func() {
+		add(word.String())
+		word.Reset()
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			add(string(r))
+			if prevHan != 0 {
+				add(string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+
+	return tokens
+}
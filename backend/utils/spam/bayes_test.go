@@ -0,0 +1,203 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// memoryStore 内存中的词频存储
+type memoryStore struct {
+	spamDocs, hamDocs int64
+	counts            map[string]*TokenCount
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{counts: make(map[string]*TokenCount)}
+}
+
+func (m *memoryStore) Documents(ctx context.Context) (int64, int64, error) {
+	return m.spamDocs, m.hamDocs, nil
+}
+
+func (m *memoryStore) Counts(ctx context.Context, tokens []string) ([]TokenCount, error) {
+	var counts []TokenCount
+	for _, token := range tokens {
+		if count, ok := m.counts[token]; ok {
+			counts = append(counts, *count)
+		}
+	}
+	return counts, nil
+}
+
+func (m *memoryStore) Add(ctx context.Context, tokens []string, isSpam bool, delta int64) error {
+	if isSpam {
+		m.spamDocs += delta
+	} else {
+		m.hamDocs += delta
+	}
+	for _, token := range tokens {
+		count, ok := m.counts[token]
+		if !ok {
+			count = &TokenCount{Token: token}
+			m.counts[token] = count
+		}
+		if isSpam {
+			count.SpamCount += delta
+		} else {
+			count.HamCount += delta
+		}
+		if count.SpamCount == 0 && count.HamCount == 0 {
+			delete(m.counts, token)
+		}
+	}
+	return nil
+}
+
+// TestTokenize 英文按非字母数字切分并转小写，汉字按单字和相邻双字切分，结果去重
+func TestTokenize(t *testing.T) {
+	manyWords := make([]string, maxTokens+50)
+	for i := range manyWords {
+		manyWords[i] = fmt.Sprintf("w%d", i)
+	}
+
+	cases := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"punctuation only", "!!! ... ???", nil},
+		{"lower case and dedupe", "Hello, World! hello WORLD", []string{"hello", "world"}},
+		{"digits stay in words", "win $1000 now2", []string{"win", "1000", "now2"}},
+		{"han unigrams and bigrams", "免费领取", []string{"免", "费", "免费", "领", "费领", "取", "领取"}},
+		{"han and latin", "Go语言", []string{"go", "语", "言", "语言"}},
+		{"han separated by punctuation", "免费，领取", []string{"免", "费", "免费", "领", "取", "领取"}},
+		{"han separated by latin", "免a费", []string{"免", "a", "费"}},
+		{"overlong word", strings.Repeat("x", maxTokenLength+1) + " ok", []string{"ok"}},
+		{"longest word", strings.Repeat("x", maxTokenLength), []string{strings.Repeat("x", maxTokenLength)}},
+		{"token limit", strings.Join(manyWords, " "), manyWords[:maxTokens]},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokenize(tc.text); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("tokenize(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+// trainedChecker 使用少量样本训练的分类器
+func trainedChecker(t *testing.T) (*BayesChecker, *memoryStore) {
+	t.Helper()
+
+	store := newMemoryStore()
+	checker := &BayesChecker{Store: store, SpamThreshold: 0.9, SuspiciousThreshold: 0.6, MinDocuments: 3}
+	samples := []struct {
+		text   string
+		isSpam bool
+	}{
+		{"buy cheap pills now", true},
+		{"cheap pills online buy", true},
+		{"cheap pills discount", true},
+		{"great article thanks", false},
+		{"thanks for the great write up", false},
+		{"great explanation thanks", false},
+	}
+	for _, sample := range samples {
+		if err := checker.Train(context.Background(), &Content{Kind: KindComment, Text: sample.text}, sample.isSpam); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return checker, store
+}
+
+// TestBayesCheck 按训练样本的词频计算垃圾概率
+func TestBayesCheck(t *testing.T) {
+	checker, _ := trainedChecker(t)
+
+	cases := []struct {
+		name   string
+		text   string
+		kind   string
+		want   Verdict
+		reason string
+	}{
+		// 两个仅出现在垃圾样本中的词：几率 4 * 4，概率 16/17
+		{"spam words", "Cheap pills!", KindComment, Spam, "spam probability 0.941"},
+		// 出现在两条垃圾样本中的词：几率 3，概率 3/4
+		{"one spam word", "buy", KindComment, Suspicious, "spam probability 0.750"},
+		// 几率 1/4 * 1/4 * 1/2，概率 1/33
+		{"ham words", "thanks, great article", KindComment, Ham, "spam probability 0.030"},
+		{"unknown words", "hello world", KindComment, Ham, "spam probability 0.500"},
+		{"no tokens", "!!!", KindComment, Ham, ""},
+		{"registration is not classified", "cheap pills", KindRegistration, Ham, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := checker.Check(context.Background(), &Content{Kind: tc.kind, Text: tc.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Verdict != tc.want || result.Reason != tc.reason {
+				t.Fatalf("Check(%q) = %v %q, want %v %q", tc.text, result.Verdict, result.Reason, tc.want, tc.reason)
+			}
+		})
+	}
+}
+
+// TestBayesMinDocuments 任一类样本不足时不做判定
+func TestBayesMinDocuments(t *testing.T) {
+	checker, _ := trainedChecker(t)
+	checker.MinDocuments = 4
+
+	result, err := checker.Check(context.Background(), &Content{Kind: KindComment, Text: "cheap pills"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Ham || result.Reason != "" {
+		t.Fatalf("Check with too few documents = %v %q, want ham without a score", result.Verdict, result.Reason)
+	}
+}
+
+// TestBayesUntrain 撤销训练后词频恢复原状，改判后重新训练得到新结论
+func TestBayesUntrain(t *testing.T) {
+	checker, store := trainedChecker(t)
+	ctx := context.Background()
+	before := *store
+	before.counts = make(map[string]*TokenCount)
+	for token, count := range store.counts {
+		copied := *count
+		before.counts[token] = &copied
+	}
+
+	content := &Content{Kind: KindComment, Text: "cheap pills are great"}
+	if err := checker.Train(ctx, content, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Untrain(ctx, content, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*store, before) {
+		t.Fatalf("untrain did not restore the store:\n got %+v\nwant %+v", *store, before)
+	}
+
+	// 将原本的垃圾样本改判为正常内容
+	spamSample := &Content{Kind: KindComment, Text: "cheap pills discount"}
+	if err := checker.Untrain(ctx, spamSample, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Train(ctx, spamSample, false); err != nil {
+		t.Fatal(err)
+	}
+	if store.spamDocs != 2 || store.hamDocs != 4 {
+		t.Fatalf("documents = %d spam, %d ham, want 2 spam, 4 ham", store.spamDocs, store.hamDocs)
+	}
+	if count := store.counts["discount"]; count == nil || count.SpamCount != 0 || count.HamCount != 1 {
+		t.Fatalf("discount count = %+v, want only ham", count)
+	}
+}
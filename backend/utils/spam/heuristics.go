@@ -0,0 +1,118 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HoneypotChecker 蜜罐检查，表单中隐藏的字段被填写即判定为机器人
+type HoneypotChecker struct{}
+
+// Name 返回检查器名称
+func (h *HoneypotChecker) Name() string {
+	return "honeypot"
+}
+
+// Check 检查蜜罐字段
+func (h *HoneypotChecker) Check(ctx context.Context, content *Content) (Result, error) {
+	if strings.TrimSpace(content.Honeypot) != "" {
+		return Result{Verdict: Spam, Reason: "honeypot field filled"}, nil
+	}
+	return Result{Verdict: Ham}, nil
+}
+
+// KeywordChecker 关键词黑名单检查，不区分大小写
+type KeywordChecker struct {
+	Blocked    []string // 命中即判定为垃圾内容
+	Suspicious []string // 命中后进入人工审核
+}
+
+// Name 返回检查器名称
+func (k *KeywordChecker) Name() string {
+	return "keyword"
+}
+
+// Check 检查内容是否包含黑名单关键词
+func (k *KeywordChecker) Check(ctx context.Context, content *Content) (Result, error) {
+	text := strings.ToLower(content.Text)
+	if keyword, ok := containsAny(text, k.Blocked); ok {
+		return Result{Verdict: Spam, Reason: fmt.Sprintf("blocked keyword %q", keyword)}, nil
+	}
+	if keyword, ok := containsAny(text, k.Suspicious); ok {
+		return Result{Verdict: Suspicious, Reason: fmt.Sprintf("suspicious keyword %q", keyword)}, nil
+	}
+	return Result{Verdict: Ham}, nil
+}
+
+// containsAny 返回文本中命中的第一个关键词
+func containsAny(text string, keywords []string) (string, bool) {
+	for _, keyword := range keywords {
+		if keyword != "" && containsKeyword(text, strings.ToLower(keyword)) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
+
+// containsKeyword 按词边界匹配关键词，避免 "crypto" 命中 "cryptography"
+// 关键词首尾为汉字时中文不以空格分词，首尾不要求边界
+func containsKeyword(text, keyword string) bool {
+	first, _ := utf8.DecodeRuneInString(keyword)
+	last, _ := utf8.DecodeLastRuneInString(keyword)
+	for offset := 0; ; {
+		idx := strings.Index(text[offset:], keyword)
+		if idx < 0 {
+			return false
+		}
+		start := offset + idx
+		end := start + len(keyword)
+
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if (start == 0 || unicode.Is(unicode.Han, first) || !isWordRune(before)) &&
+			(end == len(text) || unicode.Is(unicode.Han, last) || !isWordRune(after)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+}
+
+// isWordRune 判断字符是否属于单词，汉字与字母之间不加空格，视为词边界
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) && !unicode.Is(unicode.Han, r)) || unicode.IsDigit(r) || r == '_'
+}
+
+// linkPattern 匹配 URL 及 www. 开头的链接
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>()\[\]]+`)
+
+// LinkChecker 链接数量检查
+type LinkChecker struct {
+	MaxLinks        int // 超过该数量判定为垃圾内容
+	SuspiciousLinks int // 达到该数量进入人工审核
+}
+
+// Name 返回检查器名称
+func (l *LinkChecker) Name() string {
+	return "link"
+}
+
+// Check 统计内容中的链接数量，注册信息中出现链接即判定为垃圾内容
+func (l *LinkChecker) Check(ctx context.Context, content *Content) (Result, error) {
+	count := len(linkPattern.FindAllString(content.Text, -1))
+	switch {
+	case count == 0:
+		return Result{Verdict: Ham}, nil
+	case content.Kind == KindRegistration:
+		return Result{Verdict: Spam, Reason: "link in registration data"}, nil
+	case l.MaxLinks > 0 && count > l.MaxLinks:
+		return Result{Verdict: Spam, Reason: fmt.Sprintf("%d links exceed the limit of %d", count, l.MaxLinks)}, nil
+	case l.SuspiciousLinks > 0 && count >= l.SuspiciousLinks:
+		return Result{Verdict: Suspicious, Reason: fmt.Sprintf("%d links", count)}, nil
+	}
+	return Result{Verdict: Ham}, nil
+}
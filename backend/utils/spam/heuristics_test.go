@@ -0,0 +1,124 @@
+package spam
+
+import (
+	"context"
+	"testing"
+)
+
+// TestContainsKeyword 关键词按词边界匹配，汉字关键词首尾不要求边界
+func TestContainsKeyword(t *testing.T) {
+	cases := []struct {
+		name    string
+		text    string
+		keyword string
+		want    bool
+	}{
+		{"whole text", "crypto", "crypto", true},
+		{"surrounded by spaces", "buy crypto now", "crypto", true},
+		{"surrounded by punctuation", "#crypto!", "crypto", true},
+		{"prefix of a longer word", "cryptography", "crypto", false},
+		{"suffix of a longer word", "anticrypto", "crypto", false},
+		{"followed by a digit", "crypto2", "crypto", false},
+		{"preceded by an underscore", "my_crypto", "crypto", false},
+		{"preceded by a non-ASCII letter", "cafécrypto", "crypto", false},
+		{"later occurrence on a boundary", "cryptography and crypto", "crypto", true},
+		{"multi-word keyword", "cheap viagra online", "cheap viagra", true},
+		{"Han inside a sentence", "点击免费领取礼品", "免费", true},
+		{"Han followed by letters", "免费vpn", "免费", true},
+		{"Han keyword absent", "收费服务", "免费", false},
+		{"Latin keyword between Han", "购买crypto请联系", "crypto", true},
+		{"mixed keyword with boundary", "送你免费vpn", "免费vpn", true},
+		{"mixed keyword inside a word", "免费vpnx", "免费vpn", false},
+		{"mixed keyword after a letter", "getvpn免费", "vpn免费", false},
+		{"empty text", "", "crypto", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := containsKeyword(tc.text, tc.keyword); got != tc.want {
+				t.Fatalf("containsKeyword(%q, %q) = %v, want %v", tc.text, tc.keyword, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestKeywordChecker 关键词不区分大小写，黑名单优先于可疑关键词
+func TestKeywordChecker(t *testing.T) {
+	checker := &KeywordChecker{Blocked: []string{"Casino"}, Suspicious: []string{"bitcoin", ""}}
+	cases := []struct {
+		text string
+		want Verdict
+	}{
+		{"Best CASINO bonus", Spam},
+		{"bitcoin casino", Spam},
+		{"Accepting Bitcoin", Suspicious},
+		{"casinos and bitcoins", Ham},
+		{"nice post", Ham},
+	}
+
+	for _, tc := range cases {
+		result, err := checker.Check(context.Background(), &Content{Kind: KindComment, Text: tc.text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Verdict != tc.want {
+			t.Fatalf("Check(%q) = %v, want %v", tc.text, result.Verdict, tc.want)
+		}
+	}
+}
+
+// TestLinkCount 统计 URL 及 www. 开头的链接
+func TestLinkCount(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"no links", "no links here", 0},
+		{"email address", "mail me at a@example.com", 0},
+		{"scheme without host", "http:// is a prefix", 0},
+		{"http and https", "see https://a.example and http://b.example/x?y=1", 2},
+		{"www prefix", "visit www.example.com today", 1},
+		{"upper case", "HTTPS://A.EXAMPLE", 1},
+		{"inside parentheses", "(https://a.example)", 1},
+		{"adjacent html", "https://a.example<br>https://b.example", 2},
+		{"markdown link", "[site](https://a.example/page)", 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := len(linkPattern.FindAllString(tc.text, -1)); got != tc.want {
+				t.Fatalf("link count of %q = %d, want %d", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestLinkChecker 注册信息出现链接即拒绝，评论按链接数量判定
+func TestLinkChecker(t *testing.T) {
+	checker := &LinkChecker{MaxLinks: 3, SuspiciousLinks: 2}
+	cases := []struct {
+		name    string
+		content Content
+		want    Verdict
+	}{
+		{"registration with a link", Content{Kind: KindRegistration, Text: "bob www.spam.example"}, Spam},
+		{"registration without links", Content{Kind: KindRegistration, Text: "bob bob@example.com"}, Ham},
+		{"one link", Content{Kind: KindComment, Text: "https://a.example"}, Ham},
+		{"suspicious links", Content{Kind: KindComment, Text: "https://a.example https://b.example"}, Suspicious},
+		{"at the limit", Content{Kind: KindComment, Text: "https://a.example https://b.example https://c.example"}, Suspicious},
+		{"over the limit", Content{Kind: KindComment, Text: "https://a.example https://b.example https://c.example www.d.example"}, Spam},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := checker.Check(context.Background(), &tc.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Verdict != tc.want {
+				t.Fatalf("Check(%q) = %v (%s), want %v", tc.content.Text, result.Verdict, result.Reason, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package spam
+
+import (
+	"context"
+	"keep_learning_blog/config"
+	"keep_learning_blog/utils/logger"
+)
+
+// Verdict 检查结论
+type Verdict int
+
+const (
+	Ham        Verdict = iota // 正常内容
+	Suspicious                // 可疑内容，需人工审核
+	Spam                      // 垃圾内容，直接拒绝
+)
+
+// String 返回检查结论名称
+func (v Verdict) String() string {
+	switch v {
+	case Suspicious:
+		return "suspicious"
+	case Spam:
+		return "spam"
+	default:
+		return "ham"
+	}
+}
+
+// 被检查内容的类型
+const (
+	KindComment      = "comment"
+	KindRegistration = "registration"
+)
+
+// Content 待检查的内容
+type Content struct {
+	Kind     string // 内容类型
+	Text     string // 正文，注册时为用户名和邮箱
+	Honeypot string // 蜜罐字段，正常用户不会填写
+}
+
+// Result 检查结果
+type Result struct {
+	Verdict Verdict
+	Checker string // 给出结论的检查器
+	Reason  string
+}
+
+// Checker 垃圾内容检查器
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, content *Content) (Result, error)
+}
+
+// Trainer 可根据人工审核结果训练的检查器
+type Trainer interface {
+	Train(ctx context.Context, content *Content, isSpam bool) error
+	Untrain(ctx context.Context, content *Content, isSpam bool) error
+}
+
+// Filter 依次执行多个检查器并返回最严重的结论
+type Filter struct {
+	checkers []Checker
+}
+
+// NewFilter 创建检查器组合
+func NewFilter(checkers ...Checker) *Filter {
+	return &Filter{checkers: checkers}
+}
+
+// NewFilterFromConfig 根据配置创建内置检查器组合，未启用时返回 nil
+// store 为贝叶斯分类器的词频存储
+func NewFilterFromConfig(cfg *config.SpamConfig, store TokenStore) *Filter {
+	if !cfg.Enabled {
+		return nil
+	}
+	return NewFilter(
+		&HoneypotChecker{},
+		&KeywordChecker{Blocked: cfg.BlockedKeywords, Suspicious: cfg.SuspiciousKeywords},
+		&LinkChecker{MaxLinks: cfg.MaxLinks, SuspiciousLinks: cfg.SuspiciousLinks},
+		&BayesChecker{
+			Store:               store,
+			SpamThreshold:       cfg.BayesSpamThreshold,
+			SuspiciousThreshold: cfg.BayesSuspiciousThreshold,
+			MinDocuments:        cfg.BayesMinDocuments,
+		},
+	)
+}
+
+// Name 返回检查器名称
+func (f *Filter) Name() string {
+	return "filter"
+}
+
+// Check 执行所有检查器，命中垃圾内容时立即返回
+// 单个检查器出错时记录日志并跳过，不影响正常用户提交内容
+func (f *Filter) Check(ctx context.Context, content *Content) (Result, error) {
+	result := Result{Verdict: Ham}
+	for _, checker := range f.checkers {
+		r, err := checker.Check(ctx, content)
+		if err != nil {
+			logger.Log.WithError(err).WithField("checker", checker.Name()).Warn("Spam checker failed")
+			continue
+		}
+		if r.Verdict > result.Verdict {
+			r.Checker = checker.Name()
+			result = r
+		}
+		if result.Verdict == Spam {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Train 使用人工审核结果训练所有可训练的检查器
+func (f *Filter) Train(ctx context.Context, content *Content, isSpam bool) error {
+	for _, checker := range f.checkers {
+		if trainer, ok := checker.(Trainer); ok {
+			if err := trainer.Train(ctx, content, isSpam); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Untrain 撤销所有可训练检查器之前的训练
+func (f *Filter) Untrain(ctx context.Context, content *Content, isSpam bool) error {
+	for _, checker := range f.checkers {
+		if trainer, ok := checker.(Trainer); ok {
+			if err := trainer.Untrain(ctx, content, isSpam); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
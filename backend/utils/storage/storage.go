@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"keep_learning_blog/config"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("object not found")
+
+// Backend 文件存储后端
+type Backend interface {
+	// Put 保存文件，key 为相对路径（如 media/1/2025/02/xxx.png）
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取文件，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 返回文件的公开访问地址
+	URL(key string) string
+}
+
+// New 根据配置创建存储后端
+func New(cfg *config.StorageConfig) (Backend, error) {
+	switch cfg.Backend {
+	case config.StorageBackendLocal, "":
+		return NewLocal(cfg.Local.Root, cfg.Local.BaseURL)
+	case config.StorageBackendS3:
+		return NewS3(&cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,107 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret RFC 6238 附录 B 中 SHA-1 测试向量使用的密钥 "12345678901234567890"
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+// TestCodeRFC6238 RFC 6238 附录 B 的 SHA-1 测试向量，取 8 位结果的后 6 位
+func TestCodeRFC6238(t *testing.T) {
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range cases {
+		code, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tc.want {
+			t.Fatalf("code at %d = %s, want %s", tc.unix, code, tc.want)
+		}
+	}
+}
+
+// TestCodeSecretFormat 密钥不区分大小写，忽略首尾空白，无效编码返回错误
+func TestCodeSecretFormat(t *testing.T) {
+	code, err := Code("  "+strings.ToLower(rfcSecret)+"\n", 1)
+	if err != nil || code != "287082" {
+		t.Fatalf("Code with lower case secret = %q, %v, want 287082", code, err)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Fatal("Code accepted an invalid secret")
+	}
+}
+
+// TestValidateWindow 前后 skew 个时间步内的验证码有效，并返回验证码所在的时间步
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	cases := []struct {
+		name   string
+		offset int64
+		skew   int
+		ok     bool
+	}{
+		{"current step", 0, 1, true},
+		{"previous step", -1, 1, true},
+		{"next step", 1, 1, true},
+		{"two steps behind", -2, 1, false},
+		{"two steps ahead", 2, 1, false},
+		{"previous step without skew", -1, 0, false},
+		{"two steps behind with wider skew", -2, 2, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, current+tc.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			step, ok := Validate(rfcSecret, code, now, tc.skew)
+			if ok != tc.ok {
+				t.Fatalf("Validate ok = %v, want %v", ok, tc.ok)
+			}
+			if ok && step != current+tc.offset {
+				t.Fatalf("Validate step = %d, want %d", step, current+tc.offset)
+			}
+		})
+	}
+}
+
+// TestValidateMalformed 位数不符或密钥无效时校验失败
+func TestValidateMalformed(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	cases := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"empty code", rfcSecret, ""},
+		{"eight digit code", rfcSecret, "14050471"},
+		{"wrong code", rfcSecret, "000000"},
+		{"invalid secret", "not base32!", "050471"},
+	}
+
+	for _, tc := range cases {
+		if _, ok := Validate(tc.secret, tc.code, now, 1); ok {
+			t.Fatalf("%s: Validate accepted %q", tc.name, tc.code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, " 050471 ", now, 0); !ok {
+		t.Fatal("Validate rejected a code with surrounding spaces")
+	}
+}
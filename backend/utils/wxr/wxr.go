@@ -0,0 +1,136 @@
+package wxr
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// dateLayout WXR 中 wp:post_date 等字段的日期格式
+const dateLayout = "2006-01-02 15:04:05"
+
+// Author 站点作者（wp:author）
+type Author struct {
+	ID          string `xml:"author_id"`
+	Login       string `xml:"author_login"`
+	Email       string `xml:"author_email"`
+	DisplayName string `xml:"author_display_name"`
+}
+
+// Category 分类定义（wp:category）
+type Category struct {
+	Nicename string `xml:"category_nicename"`
+	Name     string `xml:"cat_name"`
+}
+
+// Tag 标签定义（wp:tag）
+type Tag struct {
+	Slug string `xml:"tag_slug"`
+	Name string `xml:"tag_name"`
+}
+
+// Term 文章所属的分类或标签，domain 为 category 或 post_tag
+type Term struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+// Comment 文章评论（wp:comment）
+type Comment struct {
+	ID          string `xml:"comment_id"`
+	Author      string `xml:"comment_author"`
+	AuthorEmail string `xml:"comment_author_email"`
+	Date        string `xml:"comment_date"`
+	DateGMT     string `xml:"comment_date_gmt"`
+	Content     string `xml:"comment_content"`
+	Approved    string `xml:"comment_approved"` // 1 已通过，0 待审核，spam / trash
+	Type        string `xml:"comment_type"`     // 为空或 comment 表示普通评论，pingback / trackback
+	Parent      string `xml:"comment_parent"`   // 父评论ID，顶层评论为 0
+	UserID      string `xml:"comment_user_id"`
+}
+
+// Item 文章、页面或附件（item）
+type Item struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Creator       string    `xml:"creator"`                                          // 作者登录名（dc:creator）
+	Content       string    `xml:"http://purl.org/rss/1.0/modules/content/ encoded"` // 正文（content:encoded），与 excerpt:encoded 区分
+	ID            string    `xml:"post_id"`
+	Date          string    `xml:"post_date"`
+	DateGMT       string    `xml:"post_date_gmt"`
+	Name          string    `xml:"post_name"` // slug，可能经过 URL 编码
+	Status        string    `xml:"status"`    // publish / draft / pending / private / future / trash / inherit
+	Parent        string    `xml:"post_parent"`
+	Type          string    `xml:"post_type"` // post / page / attachment / nav_menu_item 等
+	AttachmentURL string    `xml:"attachment_url"`
+	Terms         []Term    `xml:"category"`
+	Comments      []Comment `xml:"comment"`
+}
+
+// Reader 流式读取 WXR 文件，每次只解码一个顶层元素，不会将整个文件读入内存
+type Reader struct {
+	decoder *xml.Decoder
+}
+
+// NewReader 创建 WXR 读取器
+func NewReader(r io.Reader) *Reader {
+	return &Reader{decoder: xml.NewDecoder(r)}
+}
+
+// Next 返回下一个 *Author、*Category、*Tag 或 *Item，读取完毕时返回 io.EOF
+func (r *Reader) Next() (interface{}, error) {
+	for {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var value interface{}
+		switch {
+		case start.Name.Local == "item":
+			value = &Item{}
+		case start.Name.Local == "author" && isWordPressNamespace(start.Name.Space):
+			value = &Author{}
+		case start.Name.Local == "category" && isWordPressNamespace(start.Name.Space):
+			value = &Category{}
+		case start.Name.Local == "tag" && isWordPressNamespace(start.Name.Space):
+			value = &Tag{}
+		case start.Name.Local == "rss" || start.Name.Local == "channel":
+			// 进入子元素继续查找
+			continue
+		default:
+			if err := r.decoder.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := r.decoder.DecodeElement(value, &start); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+// isWordPressNamespace 判断是否为 wp: 命名空间，兼容 WXR 1.0 到 1.2
+func isWordPressNamespace(space string) bool {
+	return strings.HasPrefix(space, "http://wordpress.org/export/")
+}
+
+// ParseDate 解析 WXR 日期，优先使用 GMT 时间；未设置时（0000-00-00 00:00:00）返回 nil
+func ParseDate(gmt, local string) *time.Time {
+	if t, err := time.ParseInLocation(dateLayout, strings.TrimSpace(gmt), time.UTC); err == nil {
+		return &t
+	}
+	if t, err := time.ParseInLocation(dateLayout, strings.TrimSpace(local), time.Local); err == nil {
+		return &t
+	}
+	return nil
+}